@@ -9,13 +9,19 @@ import (
 	"syscall"
 	"unsafe"
 
+	"stock-helper-svelte/backend/alert"
 	"stock-helper-svelte/backend/api"
 	"stock-helper-svelte/backend/api/types"
 	"stock-helper-svelte/backend/data"
 	"stock-helper-svelte/backend/engine"
 	"stock-helper-svelte/backend/indicators"
+	"stock-helper-svelte/backend/monitor"
+	"stock-helper-svelte/backend/portfolio"
 	"stock-helper-svelte/backend/scheduler"
+	"stock-helper-svelte/backend/screener"
 	"stock-helper-svelte/backend/strategy"
+	"stock-helper-svelte/backend/universe"
+	"stock-helper-svelte/backend/watchlist"
 
 	"github.com/tidwall/buntdb"
 )
@@ -40,6 +46,9 @@ type App struct {
 	updater         *data.Updater
 	dataManager     *data.Manager
 	scheduler       *scheduler.Scheduler
+	watchlists      *watchlist.Store
+	monitor         *monitor.Monitor
+	alerts          *alert.Service
 	db              *buntdb.DB
 }
 
@@ -104,15 +113,34 @@ func (a *App) Startup(ctx context.Context) {
 	// 初始化数据管理器
 	a.dataManager = data.NewManager(a.apiClient)
 
+	// 初始化自选股存储
+	a.watchlists = watchlist.NewStore(db)
+
 	// 初始化策略管理器
-	a.strategyManager = strategy.NewManager("filterLua", a.apiClient, ctx)
+	a.strategyManager = strategy.NewManager("filterLua", a.apiClient, db, ctx)
 
 	// 初始化数据更新器
 	a.updater = data.NewUpdater(a.apiClient, ctx)
 
+	// 初始化盘中监控
+	a.monitor = monitor.NewMonitor(ctx, a.apiClient)
+
 	// 初始化调度器
 	a.scheduler = scheduler.NewScheduler(ctx, a.dataManager)
 
+	// 初始化提醒服务, 在数据更新后和盘中监控轮询时检查提醒规则
+	a.alerts = alert.NewService(ctx, a.apiClient, db)
+	a.scheduler.OnUpdated(func() {
+		if _, err := a.alerts.Evaluate(ctx, alert.SourceScheduler); err != nil {
+			log.Printf("检查提醒规则失败: %v\n", err)
+		}
+	})
+	a.monitor.OnPoll(func(ctx context.Context, codes []string) {
+		if _, err := a.alerts.Evaluate(ctx, alert.SourceMonitor, codes...); err != nil && ctx.Err() == nil {
+			log.Printf("检查提醒规则失败: %v\n", err)
+		}
+	})
+
 	// 启动调度器
 	if err := a.scheduler.Start(); err != nil {
 		log.Printf("警告: 启动定时任务调度器失败: %v\n", err)
@@ -137,8 +165,37 @@ func (a *App) GetStrategyByID(id int) (*engine.Strategy, error) {
 	return a.strategyManager.GetStrategyByID(id)
 }
 
+// SaveScreen 保存声明式选股条件, 保存后与Lua策略一同出现在策略列表中
+func (a *App) SaveScreen(screen screener.Screen) (*engine.Strategy, error) {
+	return a.strategyManager.SaveScreen(screen)
+}
+
+// ValidateStrategy 执行前检查策略, 返回带行号的诊断信息
+func (a *App) ValidateStrategy(strategyID int) (*strategy.ValidationResult, error) {
+	return a.strategyManager.ValidateStrategy(strategyID)
+}
+
+// DebugStrategy 对单只股票试运行策略, 返回每次api调用、日志、信号和错误的跟踪记录
+func (a *App) DebugStrategy(strategyID int, code string) (*engine.DebugTrace, error) {
+	return a.DebugStrategyWithOptions(strategyID, code, strategy.ExecuteOptions{})
+}
+
+// DebugStrategyWithOptions 按指定选项(如参数覆盖值)对单只股票试运行策略
+func (a *App) DebugStrategyWithOptions(strategyID int, code string, options strategy.ExecuteOptions) (*engine.DebugTrace, error) {
+	strategy, err := a.strategyManager.GetStrategyByID(strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %v", err)
+	}
+	return a.strategyManager.DebugStrategy(strategy, code, options)
+}
+
 // ExecuteStrategy 执行策略
 func (a *App) ExecuteStrategy(strategyID int) error {
+	return a.ExecuteStrategyWithOptions(strategyID, strategy.ExecuteOptions{})
+}
+
+// ExecuteStrategyWithOptions 按指定选项(如参数覆盖值)执行策略
+func (a *App) ExecuteStrategyWithOptions(strategyID int, options strategy.ExecuteOptions) error {
 	// 获取策略信息
 	strategy, err := a.strategyManager.GetStrategyByID(strategyID)
 	if err != nil {
@@ -146,13 +203,118 @@ func (a *App) ExecuteStrategy(strategyID int) error {
 	}
 
 	// 执行策略
-	if err := a.strategyManager.ExecuteStrategy(strategy); err != nil {
+	if err := a.strategyManager.ExecuteStrategy(strategy, options); err != nil {
 		return fmt.Errorf("failed to start execution: %v", err)
 	}
 
 	return nil
 }
 
+// ExecuteStrategies 在一次遍历中批量执行多个策略, 每只股票的数据只获取一次, 各策略分别保存执行记录
+func (a *App) ExecuteStrategies(strategyIDs []int) error {
+	return a.ExecuteStrategiesInUniverse(strategyIDs, nil)
+}
+
+// ExecuteStrategiesInUniverse 在指定股票池上批量执行多个策略, u为空时使用默认股票池
+func (a *App) ExecuteStrategiesInUniverse(strategyIDs []int, u *universe.Universe) error {
+	strategies := make([]*engine.Strategy, 0, len(strategyIDs))
+	for _, id := range strategyIDs {
+		strategy, err := a.strategyManager.GetStrategyByID(id)
+		if err != nil {
+			return fmt.Errorf("failed to get strategy: %v", err)
+		}
+		strategies = append(strategies, strategy)
+	}
+
+	if err := a.strategyManager.ExecuteBatch(strategies, u); err != nil {
+		return fmt.Errorf("failed to start execution: %v", err)
+	}
+	return nil
+}
+
+// GetInterruptedRun 获取上次中断的策略执行断点, 没有中断的执行时返回nil
+func (a *App) GetInterruptedRun() (*engine.Checkpoint, error) {
+	return a.strategyManager.GetInterruptedRun()
+}
+
+// ResumeInterruptedRun 从断点继续上次中断的策略执行
+func (a *App) ResumeInterruptedRun() error {
+	if err := a.strategyManager.ResumeInterruptedRun(); err != nil {
+		return fmt.Errorf("failed to resume execution: %v", err)
+	}
+	return nil
+}
+
+// DiscardInterruptedRun 丢弃上次中断的策略执行断点
+func (a *App) DiscardInterruptedRun() error {
+	return a.strategyManager.DiscardInterruptedRun()
+}
+
+// BacktestStrategy 在历史区间内回测策略
+func (a *App) BacktestStrategy(strategyID int, config engine.BacktestConfig) (*engine.BacktestResult, error) {
+	strategy, err := a.strategyManager.GetStrategyByID(strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %v", err)
+	}
+
+	return a.strategyManager.Backtest(strategy, config)
+}
+
+// GetBacktestRecords 获取回测记录列表
+func (a *App) GetBacktestRecords() ([]engine.ExecutionRecord, error) {
+	return a.strategyManager.GetBacktestRecords()
+}
+
+// GetBacktestRecord 获取回测记录内容
+func (a *App) GetBacktestRecord(fileName string) (*engine.BacktestResult, error) {
+	return a.strategyManager.GetBacktestRecord(fileName)
+}
+
+// OptimizeStrategy 在参数空间上回测策略, 按优化目标排序各组参数
+func (a *App) OptimizeStrategy(strategyID int, config strategy.OptimizeConfig) (*strategy.OptimizeResult, error) {
+	strategy, err := a.strategyManager.GetStrategyByID(strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %v", err)
+	}
+
+	return a.strategyManager.Optimize(strategy, config)
+}
+
+// GetOptimizeRecord 获取参数优化记录内容
+func (a *App) GetOptimizeRecord(fileName string) (*strategy.OptimizeResult, error) {
+	return a.strategyManager.GetOptimizeRecord(fileName)
+}
+
+// WalkForwardStrategy 滚动前推分析策略参数, 检验参数是否过拟合
+func (a *App) WalkForwardStrategy(strategyID int, config strategy.WalkForwardConfig) (*strategy.WalkForwardResult, error) {
+	strategy, err := a.strategyManager.GetStrategyByID(strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get strategy: %v", err)
+	}
+
+	return a.strategyManager.WalkForward(strategy, config)
+}
+
+// GetWalkForwardRecord 获取滚动前推分析记录内容
+func (a *App) GetWalkForwardRecord(fileName string) (*strategy.WalkForwardResult, error) {
+	return a.strategyManager.GetWalkForwardRecord(fileName)
+}
+
+// GetDefaultPortfolioConfig 获取默认组合模拟配置
+func (a *App) GetDefaultPortfolioConfig() portfolio.Config {
+	return portfolio.DefaultConfig()
+}
+
+// SimulatePortfolio 基于执行记录或回测记录的信号进行组合模拟
+func (a *App) SimulatePortfolio(fileName string, config portfolio.Config) (*portfolio.Result, error) {
+	return a.strategyManager.SimulatePortfolio(fileName, config)
+}
+
+// GetPortfolioRecord 获取组合模拟记录内容
+func (a *App) GetPortfolioRecord(fileName string) (*portfolio.Result, error) {
+	return a.strategyManager.GetPortfolioRecord(fileName)
+}
+
 // GetDataUpdateStatus 获取数据更新状态
 func (a *App) GetDataUpdateStatus() data.UpdateStatus {
 	return a.updater.GetStatus()
@@ -191,6 +353,66 @@ func (a *App) UpdateStockData() error {
 	return a.updater.UpdateData()
 }
 
+// UpdateWatchlistData 更新自选股列表中股票的数据
+func (a *App) UpdateWatchlistData(name string) error {
+	stocks := universe.Universe{Source: universe.SourceWatchlist, Name: name}
+	return a.updater.UpdateUniverse(stocks, universe.Sources{Watchlist: a.watchlists.Codes})
+}
+
+// GetWatchlists 获取全部自选股列表
+func (a *App) GetWatchlists() ([]watchlist.Watchlist, error) {
+	return a.watchlists.List()
+}
+
+// GetWatchlist 获取自选股列表
+func (a *App) GetWatchlist(name string) (*watchlist.Watchlist, error) {
+	return a.watchlists.Get(name)
+}
+
+// CreateWatchlist 创建自选股列表
+func (a *App) CreateWatchlist(name string) (*watchlist.Watchlist, error) {
+	return a.watchlists.Create(name)
+}
+
+// RenameWatchlist 重命名自选股列表
+func (a *App) RenameWatchlist(name, newName string) (*watchlist.Watchlist, error) {
+	return a.watchlists.Rename(name, newName)
+}
+
+// DeleteWatchlist 删除自选股列表
+func (a *App) DeleteWatchlist(name string) error {
+	return a.watchlists.Delete(name)
+}
+
+// AddToWatchlist 将股票加入自选股列表
+func (a *App) AddToWatchlist(name string, entries []watchlist.Entry) (*watchlist.Watchlist, error) {
+	return a.watchlists.Add(name, entries...)
+}
+
+// RemoveFromWatchlist 从自选股列表中移除股票
+func (a *App) RemoveFromWatchlist(name string, codes []string) (*watchlist.Watchlist, error) {
+	return a.watchlists.Remove(name, codes...)
+}
+
+// ReorderWatchlist 按给定代码顺序重新排列自选股列表
+func (a *App) ReorderWatchlist(name string, codes []string) (*watchlist.Watchlist, error) {
+	return a.watchlists.Reorder(name, codes)
+}
+
+// SetWatchlistNote 设置自选股的备注
+func (a *App) SetWatchlistNote(name, code, note string) (*watchlist.Watchlist, error) {
+	return a.watchlists.SetNote(name, code, note)
+}
+
+// ImportWatchlistFromRecord 将执行记录中的买入信号导入自选股列表, 列表不存在时创建
+func (a *App) ImportWatchlistFromRecord(name, fileName string) (*watchlist.Watchlist, error) {
+	record, err := a.strategyManager.GetExecutionRecord(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return a.watchlists.Import(name, record.Signals)
+}
+
 // GetExecutionState 获取执行状态
 func (a *App) GetExecutionState() engine.ExecutionStatus {
 	if a == nil || a.strategyManager == nil {
@@ -221,6 +443,8 @@ type ExecutionResults struct {
 	Signals     []engine.StockSignal   `json:"signals"`     // 选股信号
 	TotalStocks int                    `json:"totalStocks"` // 总股票数
 	Status      engine.ExecutionStatus `json:"status"`      // 执行状态
+	Tags        []string               `json:"tags"`        // 信号中出现的全部标签
+	Failures    engine.FailureReport   `json:"failures"`    // 处理失败的股票
 }
 
 func (a *App) GetExecutionResults() ExecutionResults {
@@ -230,7 +454,37 @@ func (a *App) GetExecutionResults() ExecutionResults {
 		Signals:     signals,
 		TotalStocks: status.TotalStocks,
 		Status:      status,
+		Tags:        engine.SignalTags(signals),
+		Failures:    a.strategyManager.GetCurrentFailures(),
+	}
+}
+
+// GetBatchExecutionState 获取批量执行中各策略的状态
+func (a *App) GetBatchExecutionState() []engine.ExecutionStatus {
+	return a.strategyManager.GetBatchStatus()
+}
+
+// GetBatchExecutionResults 获取批量执行中指定策略的执行结果
+func (a *App) GetBatchExecutionResults(strategyID int) ExecutionResults {
+	results := ExecutionResults{
+		Signals:  a.strategyManager.GetBatchSignals(strategyID),
+		Failures: a.strategyManager.GetBatchFailures(strategyID),
+	}
+	for _, status := range a.strategyManager.GetBatchStatus() {
+		if status.StrategyId == strategyID {
+			results.Status = status
+			results.TotalStocks = status.TotalStocks
+		}
 	}
+	results.Tags = engine.SignalTags(results.Signals)
+	return results
+}
+
+// QueryExecutionResults 获取按条件筛选和排序后的执行结果
+func (a *App) QueryExecutionResults(query engine.SignalQuery) ExecutionResults {
+	results := a.GetExecutionResults()
+	results.Signals = engine.QuerySignals(results.Signals, query)
+	return results
 }
 
 // GetRealtimeData 获取实时交易数据
@@ -243,6 +497,76 @@ func (a *App) GetHistoricalTransactions(code string) ([]types.HistoricalTransact
 	return a.apiClient.Market.GetHistoricalTransactions(context.Background(), code)
 }
 
+// StartMonitor 开始盘中监控: 在交易时段内轮询股票池的实时行情并调用策略的on_tick钩子,
+// 未指定股票池时监控该策略最近一次执行记录中发出信号的股票
+func (a *App) StartMonitor(config monitor.Config) error {
+	strategy, err := a.strategyManager.GetStrategyByID(config.StrategyID)
+	if err != nil {
+		return fmt.Errorf("failed to get strategy: %v", err)
+	}
+	if config.Universe == nil {
+		if config.Universe, err = a.strategyManager.LatestRecordUniverse(config.StrategyID); err != nil {
+			return err
+		}
+	}
+	return a.monitor.Start(strategy, config, a.strategyManager.UniverseSources())
+}
+
+// StopMonitor 停止盘中监控
+func (a *App) StopMonitor() {
+	a.monitor.Stop()
+}
+
+// GetMonitorStatus 获取盘中监控状态
+func (a *App) GetMonitorStatus() monitor.Status {
+	return a.monitor.GetStatus()
+}
+
+// GetMonitorAlerts 获取盘中监控产生的提醒
+func (a *App) GetMonitorAlerts() []monitor.Alert {
+	return a.monitor.GetAlerts()
+}
+
+// GetAlertRules 获取提醒规则
+func (a *App) GetAlertRules() ([]alert.Rule, error) {
+	return a.alerts.Store().List()
+}
+
+// SaveAlertRule 保存提醒规则, ID为0时创建新规则; 条件语法与声明式选股条件相同, 如"price > 1800", "rsi(14) < 25"
+func (a *App) SaveAlertRule(rule alert.Rule) (*alert.Rule, error) {
+	return a.alerts.SaveRule(rule)
+}
+
+// DeleteAlertRule 删除提醒规则
+func (a *App) DeleteAlertRule(id int) error {
+	return a.alerts.Store().Delete(id)
+}
+
+// SetAlertRuleEnabled 启用或停用提醒规则
+func (a *App) SetAlertRuleEnabled(id int, enabled bool) (*alert.Rule, error) {
+	return a.alerts.Store().SetEnabled(id, enabled)
+}
+
+// ResetAlertRule 将提醒规则重新布防
+func (a *App) ResetAlertRule(id int) (*alert.Rule, error) {
+	return a.alerts.Store().Reset(id)
+}
+
+// CheckAlertRules 立即检查全部启用的提醒规则, 返回本次触发的提醒
+func (a *App) CheckAlertRules() ([]alert.Event, error) {
+	return a.alerts.Evaluate(a.ctx, alert.SourceManual)
+}
+
+// GetAlertHistory 获取提醒记录(按时间倒序), ruleID为0时返回全部规则的记录
+func (a *App) GetAlertHistory(ruleID int, limit int) ([]alert.Event, error) {
+	return a.alerts.Store().History(ruleID, limit)
+}
+
+// ClearAlertHistory 清空提醒记录
+func (a *App) ClearAlertHistory() error {
+	return a.alerts.Store().ClearHistory()
+}
+
 // beforeClose is called when the app is about to quit
 func (a *App) beforeClose(_ context.Context) {
 	// 停止调度器
@@ -250,6 +574,16 @@ func (a *App) beforeClose(_ context.Context) {
 		a.scheduler.Stop()
 	}
 
+	// 停止盘中监控
+	if a.monitor != nil {
+		a.monitor.Stop()
+	}
+
+	// 保存正在运行的选股断点, 下次启动时可继续
+	if err := a.strategyManager.SaveCheckpoint(); err != nil {
+		log.Println("保存选股断点时发生错误:", err)
+	}
+
 	// 关闭数据库
 	if a.db != nil {
 		if err := a.db.Close(); err != nil {
@@ -287,6 +621,11 @@ func (a *App) GetExecutionRecord(fileName string) (*engine.ExecutionResult, erro
 	return a.strategyManager.GetExecutionRecord(fileName)
 }
 
+// QueryExecutionRecord 获取执行记录, 信号按条件筛选和排序
+func (a *App) QueryExecutionRecord(fileName string, query engine.SignalQuery) (*engine.ExecutionResult, error) {
+	return a.strategyManager.QueryExecutionRecord(fileName, query)
+}
+
 // DeleteExecutionRecord 删除执行记录
 func (a *App) DeleteExecutionRecord(fileName string) error {
 	return a.strategyManager.DeleteExecutionRecord(fileName)
@@ -307,6 +646,61 @@ func (a *App) CalculateKDJ(prices []float64) (*indicators.KDJResult, error) {
 	return indicators.CalculateKDJ(prices, 9, 3, 3) // 使用默认参数
 }
 
+// CalculateKDJOHLCV 按最高价和最低价计算KDJ指标
+func (a *App) CalculateKDJOHLCV(klines []types.KLineData) (*indicators.KDJResult, error) {
+	return indicators.CalculateKDJOHLCV(indicators.NewOHLCV(klines), 9, 3, 3) // 使用默认参数
+}
+
+// CalculateATR 计算平均真实波幅
+func (a *App) CalculateATR(klines []types.KLineData, period int) ([]float64, error) {
+	return indicators.CalculateATR(indicators.NewOHLCV(klines), period)
+}
+
+// CalculateBollinger 计算布林带
+func (a *App) CalculateBollinger(prices []float64, period int, multiplier float64) (*indicators.BollingerResult, error) {
+	return indicators.CalculateBollinger(prices, period, multiplier)
+}
+
+// CalculateOBV 计算能量潮指标
+func (a *App) CalculateOBV(klines []types.KLineData) ([]float64, error) {
+	return indicators.CalculateOBV(indicators.NewOHLCV(klines))
+}
+
+// CalculateVWAP 计算成交量加权平均价, period<=0时从序列起点累计
+func (a *App) CalculateVWAP(klines []types.KLineData, period int) ([]float64, error) {
+	return indicators.CalculateVWAP(indicators.NewOHLCV(klines), period)
+}
+
+// CalculateCCI 计算顺势指标
+func (a *App) CalculateCCI(klines []types.KLineData, period int) ([]float64, error) {
+	return indicators.CalculateCCI(indicators.NewOHLCV(klines), period)
+}
+
+// CalculateWilliamsR 计算威廉指标
+func (a *App) CalculateWilliamsR(klines []types.KLineData, period int) ([]float64, error) {
+	return indicators.CalculateWilliamsR(indicators.NewOHLCV(klines), period)
+}
+
+// CalculateDMI 计算趋向指标
+func (a *App) CalculateDMI(klines []types.KLineData) (*indicators.DMIResult, error) {
+	return indicators.CalculateDMI(indicators.NewOHLCV(klines), 14, 6) // 使用默认参数
+}
+
+// DetectPatterns 识别K线形态, 涨停幅度按股票代码确定
+func (a *App) DetectPatterns(code string, klines []types.KLineData) ([]indicators.CandlePattern, error) {
+	return indicators.DetectPatterns(klines, &indicators.PatternOptions{LimitUpRate: indicators.LimitUpRate(code)})
+}
+
+// GetIndicatorBindings 获取Lua策略可通过api.indicator调用的指标函数
+func (a *App) GetIndicatorBindings() []engine.IndicatorBinding {
+	return engine.IndicatorBindings()
+}
+
+// GetDataBindings 获取Lua策略可通过api.market、api.company和api.financial调用的数据接口
+func (a *App) GetDataBindings() []engine.DataBinding {
+	return engine.DataBindings()
+}
+
 // AnalyzeStock AI分析股票
 func (a *App) AnalyzeStock(code string) (*api.StockAnalysis, error) {
 	if a.aiAnalysis == nil {
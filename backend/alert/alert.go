@@ -0,0 +1,389 @@
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/screener"
+
+	"github.com/tidwall/buntdb"
+)
+
+// 提醒规则和提醒记录在数据库中的键前缀
+const (
+	ruleKeyPrefix    = "alert:rule:"    // 键为前缀+规则ID
+	historyKeyPrefix = "alert:history:" // 键为前缀+触发时间(纳秒, 定长), 按键排序即按时间排序
+)
+
+// maxHistory 保留的提醒记录数, 超出时删除最早的记录
+const maxHistory = 1000
+
+// 提醒规则状态: 布防 -> 触发(提醒一次) -> 条件不再成立后冷却 -> 冷却结束重新布防
+const (
+	StateArmed     = "armed"     // 等待条件成立
+	StateTriggered = "triggered" // 条件成立并已提醒, 等待条件不再成立
+	StateCooldown  = "cooldown"  // 条件已不再成立, 冷却结束前再次成立不会提醒
+)
+
+// 提醒的触发来源
+const (
+	SourceScheduler = "scheduler" // 定时数据更新后检查
+	SourceMonitor   = "monitor"   // 盘中监控轮询时检查
+	SourceManual    = "manual"    // 手动检查
+)
+
+// Rule 提醒规则
+type Rule struct {
+	ID        int             `json:"id"`
+	Code      string          `json:"code"`
+	Name      string          `json:"name"`
+	Condition string          `json:"condition"`      // 条件, 语法与声明式选股条件相同, 如"price > 1800", "rsi(14) < 25", "main_inflow(3) > 1e8"
+	Freq      types.KLineFreq `json:"freq,omitempty"` // 指标使用的K线周期, 默认日线(后复权)
+	Cooldown  int             `json:"cooldown"`       // 冷却时间(分钟), 0表示条件不再成立后立即重新布防
+	Enabled   bool            `json:"enabled"`
+	Note      string          `json:"note"`
+
+	State       string             `json:"state"`
+	TriggeredAt time.Time          `json:"triggeredAt"`         // 最近一次提醒时间
+	ClearedAt   time.Time          `json:"clearedAt"`           // 最近一次条件不再成立的时间
+	CheckedAt   time.Time          `json:"checkedAt"`           // 最近一次检查时间
+	Values      map[string]float64 `json:"values,omitempty"`    // 最近一次检查时条件涉及的数值
+	LastError   string             `json:"lastError,omitempty"` // 最近一次检查的错误
+	CreateTime  time.Time          `json:"createTime"`
+	UpdateTime  time.Time          `json:"updateTime"` // 最近一次修改规则的时间, 检查不更新
+}
+
+// Event 提醒记录
+type Event struct {
+	RuleID    int                `json:"ruleId"`
+	Code      string             `json:"code"`
+	Name      string             `json:"name"`
+	Condition string             `json:"condition"`
+	Note      string             `json:"note"`
+	Source    string             `json:"source"` // 触发来源, 见Source常量
+	Time      time.Time          `json:"time"`
+	Values    map[string]float64 `json:"values"` // 触发时条件涉及的数值
+}
+
+// transition 按本次检查结果推进规则状态, 返回是否需要提醒
+func (r *Rule) transition(met bool, now time.Time) bool {
+	cooldown := time.Duration(r.Cooldown) * time.Minute
+	if r.State == StateCooldown && now.Sub(r.ClearedAt) >= cooldown {
+		r.State = StateArmed
+	}
+
+	switch r.State {
+	case StateTriggered:
+		if !met {
+			r.ClearedAt = now
+			r.State = StateCooldown
+			if cooldown <= 0 {
+				r.State = StateArmed
+			}
+		}
+	case StateCooldown:
+		// 冷却期内条件再次成立视为同一次穿越, 不重复提醒
+		if met {
+			r.State = StateTriggered
+		}
+	default:
+		if met {
+			r.State = StateTriggered
+			r.TriggeredAt = now
+			return true
+		}
+	}
+	return false
+}
+
+// validate 校验规则并规范化字段
+func (r *Rule) validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Code == "" {
+		return fmt.Errorf("stock code is required")
+	}
+	if r.Cooldown < 0 {
+		return fmt.Errorf("cooldown must not be negative")
+	}
+	cond, err := screener.ParseCondition(r.Condition)
+	if err != nil {
+		return fmt.Errorf("invalid condition %q: %v", r.Condition, err)
+	}
+	r.Condition = cond.Text
+	return nil
+}
+
+// Store 基于buntdb的提醒规则和提醒记录存储
+type Store struct {
+	db *buntdb.DB
+}
+
+// NewStore 创建提醒存储
+func NewStore(db *buntdb.DB) *Store {
+	return &Store{db: db}
+}
+
+// List 获取全部提醒规则, 按ID排列
+func (s *Store) List() ([]Rule, error) {
+	rules := make([]Rule, 0)
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var decodeErr error
+		err := tx.AscendKeys(ruleKeyPrefix+"*", func(key, value string) bool {
+			var rule Rule
+			if decodeErr = json.Unmarshal([]byte(value), &rule); decodeErr != nil {
+				decodeErr = fmt.Errorf("invalid alert rule %s: %v", strings.TrimPrefix(key, ruleKeyPrefix), decodeErr)
+				return false
+			}
+			rules = append(rules, rule)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return decodeErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+// Get 获取提醒规则
+func (s *Store) Get(id int) (*Rule, error) {
+	var rule *Rule
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		rule, err = loadRule(tx, id)
+		return err
+	})
+	return rule, err
+}
+
+// Save 保存提醒规则: ID为0时创建新规则, 否则更新已有规则; 股票、条件或K线周期改变时重新布防
+func (s *Store) Save(rule Rule) (*Rule, error) {
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		if rule.ID == 0 {
+			id, err := nextRuleID(tx)
+			if err != nil {
+				return err
+			}
+			rule.ID = id
+			rule.State = StateArmed
+			rule.TriggeredAt, rule.ClearedAt, rule.CheckedAt = time.Time{}, time.Time{}, time.Time{}
+			rule.Values, rule.LastError = nil, ""
+			rule.CreateTime = now
+			rule.UpdateTime = now
+			return saveRule(tx, &rule)
+		}
+
+		old, err := loadRule(tx, rule.ID)
+		if err != nil {
+			return err
+		}
+		updated := *old
+		updated.Code = rule.Code
+		updated.Name = rule.Name
+		updated.Condition = rule.Condition
+		updated.Freq = rule.Freq
+		updated.Cooldown = rule.Cooldown
+		updated.Enabled = rule.Enabled
+		updated.Note = rule.Note
+		updated.UpdateTime = now
+		if updated.Code != old.Code || updated.Condition != old.Condition || updated.Freq != old.Freq ||
+			(updated.Enabled && !old.Enabled) {
+			updated.rearm()
+		}
+		rule = updated
+		return saveRule(tx, &rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Delete 删除提醒规则, 保留其提醒记录
+func (s *Store) Delete(id int) error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(ruleKey(id))
+		if err == buntdb.ErrNotFound {
+			return fmt.Errorf("alert rule not found: %d", id)
+		}
+		return err
+	})
+}
+
+// SetEnabled 启用或停用提醒规则, 重新启用时重新布防
+func (s *Store) SetEnabled(id int, enabled bool) (*Rule, error) {
+	return s.modify(id, func(rule *Rule) {
+		if enabled && !rule.Enabled {
+			rule.rearm()
+		}
+		rule.Enabled = enabled
+	})
+}
+
+// Reset 将提醒规则重新布防, 条件成立时下次检查即提醒
+func (s *Store) Reset(id int) (*Rule, error) {
+	return s.modify(id, (*Rule).rearm)
+}
+
+// History 获取提醒记录, 按时间倒序; ruleID为0时返回全部规则的记录, limit不大于0时不限制条数
+func (s *Store) History(ruleID, limit int) ([]Event, error) {
+	events := make([]Event, 0)
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var decodeErr error
+		err := tx.DescendKeys(historyKeyPrefix+"*", func(key, value string) bool {
+			var event Event
+			if decodeErr = json.Unmarshal([]byte(value), &event); decodeErr != nil {
+				decodeErr = fmt.Errorf("invalid alert event %s: %v", strings.TrimPrefix(key, historyKeyPrefix), decodeErr)
+				return false
+			}
+			if ruleID == 0 || event.RuleID == ruleID {
+				events = append(events, event)
+			}
+			return limit <= 0 || len(events) < limit
+		})
+		if err != nil {
+			return err
+		}
+		return decodeErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ClearHistory 删除全部提醒记录
+func (s *Store) ClearHistory() error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		var keys []string
+		err := tx.AscendKeys(historyKeyPrefix+"*", func(key, value string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if _, err := tx.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// modify 在写事务中加载、修改并保存规则
+func (s *Store) modify(id int, update func(rule *Rule)) (*Rule, error) {
+	var rule *Rule
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		var err error
+		if rule, err = loadRule(tx, id); err != nil {
+			return err
+		}
+		update(rule)
+		rule.UpdateTime = time.Now()
+		return saveRule(tx, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// rearm 重新布防并清除上次检查的结果
+func (r *Rule) rearm() {
+	r.State = StateArmed
+	r.ClearedAt = time.Time{}
+	r.Values = nil
+	r.LastError = ""
+}
+
+// ruleKey 规则在数据库中的键
+func ruleKey(id int) string {
+	return ruleKeyPrefix + strconv.Itoa(id)
+}
+
+// loadRule 在事务中加载规则
+func loadRule(tx *buntdb.Tx, id int) (*Rule, error) {
+	value, err := tx.Get(ruleKey(id))
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("alert rule not found: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rule Rule
+	if err := json.Unmarshal([]byte(value), &rule); err != nil {
+		return nil, fmt.Errorf("invalid alert rule %d: %v", id, err)
+	}
+	return &rule, nil
+}
+
+// saveRule 在事务中保存规则
+func saveRule(tx *buntdb.Tx, rule *Rule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set(ruleKey(rule.ID), string(data), nil)
+	return err
+}
+
+// nextRuleID 新规则的ID, 为现有最大ID加1
+func nextRuleID(tx *buntdb.Tx) (int, error) {
+	maxID := 0
+	err := tx.AscendKeys(ruleKeyPrefix+"*", func(key, value string) bool {
+		if id, err := strconv.Atoi(strings.TrimPrefix(key, ruleKeyPrefix)); err == nil {
+			maxID = max(maxID, id)
+		}
+		return true
+	})
+	return maxID + 1, err
+}
+
+// recordEvent 在事务中保存提醒记录
+func recordEvent(tx *buntdb.Tx, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%020d:%d", historyKeyPrefix, event.Time.UnixNano(), event.RuleID)
+	_, _, err = tx.Set(key, string(data), nil)
+	return err
+}
+
+// trimHistory 在事务中删除超出maxHistory的最早提醒记录
+func trimHistory(tx *buntdb.Tx) error {
+	var keys []string
+	err := tx.AscendKeys(historyKeyPrefix+"*", func(key, value string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if err != nil || len(keys) <= maxHistory {
+		return err
+	}
+	for _, key := range keys[:len(keys)-maxHistory] {
+		if _, err := tx.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/screener"
+
+	"github.com/tidwall/buntdb"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// checkConcurrency 检查提醒规则的并发数
+const checkConcurrency = 8
+
+// Service 提醒服务: 检查启用的提醒规则, 推进规则状态, 保存提醒记录并发送alert:triggered事件
+type Service struct {
+	ctx       context.Context
+	apiClient *api.Client
+	store     *Store
+	mutex     sync.Mutex // 同一时间只运行一次检查
+}
+
+// check 单条规则的检查结果
+type check struct {
+	rule   Rule
+	met    bool
+	values map[string]float64
+	err    error
+}
+
+// NewService 创建提醒服务
+func NewService(ctx context.Context, apiClient *api.Client, db *buntdb.DB) *Service {
+	return &Service{
+		ctx:       ctx,
+		apiClient: apiClient,
+		store:     NewStore(db),
+	}
+}
+
+// Store 提醒规则和提醒记录存储
+func (s *Service) Store() *Store {
+	return s.store
+}
+
+// SaveRule 保存提醒规则, 未填写股票名称时从股票列表中查找
+func (s *Service) SaveRule(rule Rule) (*Rule, error) {
+	if rule.Name == "" && s.apiClient != nil {
+		if stocks, err := s.apiClient.Market.GetIndexList(context.Background()); err == nil {
+			for _, stock := range stocks {
+				if stock.Code == strings.TrimSpace(rule.Code) {
+					rule.Name = stock.Name
+					break
+				}
+			}
+		}
+	}
+	return s.store.Save(rule)
+}
+
+// Evaluate 检查启用的提醒规则(codes不为空时只检查这些股票的规则), 返回本次触发的提醒
+func (s *Service) Evaluate(ctx context.Context, source string, codes ...string) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rules, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		wanted[code] = true
+	}
+	var pending []Rule
+	for _, rule := range rules {
+		if rule.Enabled && (len(codes) == 0 || wanted[rule.Code]) {
+			pending = append(pending, rule)
+		}
+	}
+	if len(pending) == 0 {
+		return []Event{}, nil
+	}
+
+	checks := s.check(ctx, pending)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	events, err := s.apply(checks, source)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		log.Printf("提醒触发: %s(%s) %s\n", event.Name, event.Code, event.Condition)
+		if s.ctx != nil {
+			runtime.EventsEmit(s.ctx, "alert:triggered", event)
+		}
+	}
+	return events, nil
+}
+
+// check 并发检查各规则的条件, 结果与rules一一对应
+func (s *Service) check(ctx context.Context, rules []Rule) []check {
+	checks := make([]check, len(rules))
+	source := &clientSource{ctx: ctx, client: s.apiClient}
+	sem := make(chan struct{}, checkConcurrency)
+	var wg sync.WaitGroup
+	for i, rule := range rules {
+		checks[i].rule = rule
+		if ctx.Err() != nil {
+			checks[i].err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(c *check) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			cond, err := screener.ParseCondition(c.rule.Condition)
+			if err != nil {
+				c.err = err
+				return
+			}
+			stock := types.Index{Code: c.rule.Code, Name: c.rule.Name}
+			c.met, c.values, c.err = cond.Check(stock, source, c.rule.Freq)
+		}(&checks[i])
+	}
+	wg.Wait()
+	return checks
+}
+
+// apply 在单个写事务中推进各规则的状态并保存提醒记录; 检查期间被修改或删除的规则跳过
+func (s *Service) apply(checks []check, source string) ([]Event, error) {
+	events := make([]Event, 0)
+	now := time.Now()
+	err := s.store.db.Update(func(tx *buntdb.Tx) error {
+		for _, c := range checks {
+			rule, err := loadRule(tx, c.rule.ID)
+			if err != nil || !rule.UpdateTime.Equal(c.rule.UpdateTime) {
+				continue
+			}
+
+			rule.CheckedAt = now
+			if c.err != nil {
+				rule.LastError = c.err.Error()
+			} else {
+				rule.LastError = ""
+				rule.Values = c.values
+				if rule.transition(c.met, now) {
+					event := Event{
+						RuleID:    rule.ID,
+						Code:      rule.Code,
+						Name:      rule.Name,
+						Condition: rule.Condition,
+						Note:      rule.Note,
+						Source:    source,
+						Time:      now,
+						Values:    c.values,
+					}
+					if err := recordEvent(tx, event); err != nil {
+						return err
+					}
+					events = append(events, event)
+				}
+			}
+			if err := saveRule(tx, rule); err != nil {
+				return err
+			}
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		return trimHistory(tx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("保存提醒状态失败: %v", err)
+	}
+	return events, nil
+}
+
+// clientSource 通过API客户端获取条件需要的行情数据, 实现engine.DataSource
+type clientSource struct {
+	ctx    context.Context
+	client *api.Client
+}
+
+// KLineData 实现engine.DataSource
+func (c *clientSource) KLineData(code string, freq types.KLineFreq) ([]types.KLineData, error) {
+	return c.client.Market.GetKLineData(c.ctx, code, freq)
+}
+
+// Realtime 实现engine.DataSource
+func (c *clientSource) Realtime(code string) (*types.RealtimeData, error) {
+	return c.client.Market.GetRealtimeData(c.ctx, code)
+}
+
+// CapitalFlow 实现engine.DataSource
+func (c *clientSource) CapitalFlow(code string) ([]types.CapitalFlow, error) {
+	data, err := c.client.Market.GetCapitalFlow(c.ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return engine.SortCapitalFlow(data, ""), nil
+}
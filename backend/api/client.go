@@ -41,6 +41,8 @@ const (
 	CacheTime60Min = time.Hour
 	CacheTimeDay   = time.Hour * 24
 
+	CacheTimeRealtime = time.Second * 3 // 实时行情只短暂缓存, 盯盘轮询时需要取到最新数据
+
 	// 工作池相关常量
 	MaxWorkers = 200  // 增加工作协程数
 	MaxQueue   = 5000 // 增加队列长度
@@ -397,6 +399,11 @@ func (c *Client) getCacheTTL(endpoint string, freq types.KLineFreq) time.Duratio
 		return today16.Sub(now)
 	}
 
+	// 实时行情
+	if strings.Contains(endpoint, "hsrl/ssjy") {
+		return CacheTimeRealtime
+	}
+
 	// K线数据根据频率设置缓存时间
 	if strings.Contains(endpoint, "hszbl/fsjy") {
 		switch freq {
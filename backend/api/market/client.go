@@ -41,6 +41,22 @@ func (c *Client) GetIndexList(ctx context.Context) ([]types.Index, error) {
 	return indices, nil
 }
 
+// GetIndexConstituents 获取指数、行业或概念的成分股
+func (c *Client) GetIndexConstituents(ctx context.Context, code string) ([]types.Index, error) {
+	endpoint := fmt.Sprintf("hszg/gg/%s", code)
+	body, err := c.request(ctx, endpoint, "")
+	if err != nil {
+		return nil, fmt.Errorf("获取成分股失败: %v", err)
+	}
+
+	var stocks []types.Index
+	if err := json.Unmarshal(body, &stocks); err != nil {
+		return nil, fmt.Errorf("解析成分股失败: %v", err)
+	}
+
+	return stocks, nil
+}
+
 // GetKLineData 获取K线数据
 func (c *Client) GetKLineData(ctx context.Context, code string, freq types.KLineFreq) ([]types.KLineData, error) {
 	endpoint := fmt.Sprintf("hszbl/fsjy/%s/%s", code, freq)
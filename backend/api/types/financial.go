@@ -111,6 +111,24 @@ type StockFinancialData struct {
 	Performance   *PerformanceData   `json:"performance"`   // 业绩报表数据
 }
 
+// PublishDate 报告发布日期(yyyy-MM-dd): 取业绩报表的发布日期, 没有时按法定披露截止日估计
+// (一季报4月30日, 半年报8月31日, 三季报10月31日, 年报次年4月30日)
+func (s *StockFinancialData) PublishDate() string {
+	if s.Performance != nil && len(s.Performance.Rdate) >= 10 {
+		return s.Performance.Rdate[:10]
+	}
+	switch s.Quarter {
+	case 1:
+		return fmt.Sprintf("%04d-04-30", s.Year)
+	case 2:
+		return fmt.Sprintf("%04d-08-31", s.Year)
+	case 3:
+		return fmt.Sprintf("%04d-10-31", s.Year)
+	default:
+		return fmt.Sprintf("%04d-04-30", s.Year+1)
+	}
+}
+
 // FormatFinancialData 格式化财务数据为字符串
 func (s *StockFinancialData) FormatFinancialData() string {
 	var result strings.Builder
@@ -9,17 +9,17 @@ type Index struct {
 
 // KLineData K线数据
 type KLineData struct {
-	Time      string  `json:"d"`   // 交易时间
-	Open      float64 `json:"o"`   // 开盘价
-	High      float64 `json:"h"`   // 最高价
-	Low       float64 `json:"l"`   // 最低价
-	Close     float64 `json:"c"`   // 收盘价
-	Volume    float64 `json:"v"`   // 成交量(手)
-	Amount    float64 `json:"e"`   // 成交额(元)
-	Amplitude float64 `json:"zf"`  // 振幅(%)
-	Turnover  float64 `json:"hs"`  // 换手率(%)
-	Change    float64 `json:"zd"`  // 涨跌幅(%)
-	ChangeAmt float64 `json:"zde"` // 涨跌额(元)
+	Time      string  `json:"d"`                      // 交易时间
+	Open      float64 `json:"o"`                      // 开盘价
+	High      float64 `json:"h"`                      // 最高价
+	Low       float64 `json:"l"`                      // 最低价
+	Close     float64 `json:"c"`                      // 收盘价
+	Volume    float64 `json:"v"`                      // 成交量(手)
+	Amount    float64 `json:"e"`                      // 成交额(元)
+	Amplitude float64 `json:"zf"`                     // 振幅(%)
+	Turnover  float64 `json:"hs"`                     // 换手率(%)
+	Change    float64 `json:"zd"`                     // 涨跌幅(%)
+	ChangeAmt float64 `json:"zde" lua:"changeAmount"` // 涨跌额(元)
 }
 
 // RealtimeData 实时交易数据
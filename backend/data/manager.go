@@ -10,6 +10,7 @@ import (
 
 	"stock-helper-svelte/backend/api"
 	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/universe"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -96,38 +97,13 @@ func (m *Manager) GetStockData(code string) ([]types.KLineData, []types.Historic
 	return klineData, transData, nil
 }
 
-// filterStocks 过滤掉ST股票和退市股票
-func (m *Manager) filterStocks(stocks []types.Index) []types.Index {
-	filtered := make([]types.Index, 0, len(stocks))
-	for _, stock := range stocks {
-		// 过滤掉ST股票
-		if strings.Contains(strings.ToUpper(stock.Name), "ST") {
-			continue
-		}
-
-		// 过滤掉退市股票
-		if strings.Contains(stock.Name, "退") {
-			continue
-		}
-
-		// 过滤掉含有 "退市" 的股票
-		if strings.Contains(stock.Name, "退市") {
-			continue
-		}
-
-		// 只保留沪深主板、创业板、科创板的股票
-		if strings.HasPrefix(stock.Code, "00") || // 深证主板
-			strings.HasPrefix(stock.Code, "30") || // 创业板
-			strings.HasPrefix(stock.Code, "60") || // 上证主板
-			strings.HasPrefix(stock.Code, "68") { // 科创板
-			filtered = append(filtered, stock)
-		}
-	}
-	return filtered
+// UpdateAllStocks 更新默认股票池中所有股票的数据
+func (m *Manager) UpdateAllStocks(ctx context.Context) error {
+	return m.UpdateUniverse(ctx, universe.Default(), universe.Sources{})
 }
 
-// UpdateAllStocks 更新所有股票数据
-func (m *Manager) UpdateAllStocks(ctx context.Context) error {
+// UpdateUniverse 更新股票池(如自选股列表)中股票的数据, sources的Client由数据管理器填充
+func (m *Manager) UpdateUniverse(ctx context.Context, u universe.Universe, sources universe.Sources) error {
 	m.ctx = ctx
 	m.mutex.Lock()
 	if m.status.IsUpdating {
@@ -154,14 +130,12 @@ func (m *Manager) UpdateAllStocks(ctx context.Context) error {
 		m.emitStatus()
 	}()
 
-	// 获取股票列表
-	indices, err := m.apiClient.Market.GetIndexList(context.Background())
+	// 解析股票池
+	sources.Client = m.apiClient
+	indices, err := u.Resolve(context.Background(), sources)
 	if err != nil {
 		return fmt.Errorf("failed to get index list: %v", err)
 	}
-
-	// 过滤股票列表
-	indices = m.filterStocks(indices)
 	log.Printf("过滤后的股票数量: %d\n", len(indices))
 
 	m.mutex.Lock()
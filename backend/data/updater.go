@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/universe"
 )
 
 type Updater struct {
@@ -28,6 +29,11 @@ func (u *Updater) UpdateData() error {
 	return u.manager.UpdateAllStocks(u.ctx)
 }
 
+// UpdateUniverse 更新股票池(如自选股列表)中股票的数据
+func (u *Updater) UpdateUniverse(stocks universe.Universe, sources universe.Sources) error {
+	return u.manager.UpdateUniverse(u.ctx, stocks, sources)
+}
+
 // GetLastUpdateTime 获取最后更新时间
 func (u *Updater) GetLastUpdateTime() (time.Time, error) {
 	return u.manager.GetLastUpdateTime()
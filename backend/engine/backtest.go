@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// BacktestHorizons 前瞻收益统计周期(交易日)
+var BacktestHorizons = []int{1, 5, 10, 20}
+
+// BacktestConfig 回测配置
+type BacktestConfig struct {
+	StartDate string          `json:"startDate"` // 开始日期 yyyy-MM-dd
+	EndDate   string          `json:"endDate"`   // 结束日期 yyyy-MM-dd
+	Freq      types.KLineFreq `json:"freq"`      // 交易日历及收益计算所用的K线周期(默认日线后复权)
+
+	// ScoreEndDate 计算前瞻收益可使用的最后日期, 为空时不限制; 周期超出该日期的收益不统计,
+	// 用于滚动前推分析的样本内优化, 避免用样本外的价格评估参数
+	ScoreEndDate string `json:"scoreEndDate,omitempty"`
+
+	Params map[string]interface{} `json:"params,omitempty"` // 策略参数覆盖值, 保存的结果中为实际使用的全部参数
+}
+
+// BacktestSignal 回测信号
+type BacktestSignal struct {
+	StockSignal
+	Returns     map[int]float64 `json:"returns"`               // 各周期前瞻收益率(%), 数据不足的周期不出现
+	Drawdown    float64         `json:"drawdown"`              // 信号后持有期内最大回撤(%, 正数)
+	ExitDate    string          `json:"exitDate,omitempty"`    // 对应卖出信号日期(仅买入信号)
+	ExitReturn  float64         `json:"exitReturn"`            // 持有至卖出信号的收益率(%)
+	HoldingDays int             `json:"holdingDays,omitempty"` // 持有至卖出信号的交易日数
+}
+
+// HorizonStats 单个周期的收益统计
+type HorizonStats struct {
+	Days       int     `json:"days"`       // 持有天数
+	Count      int     `json:"count"`      // 有效信号数
+	HitRate    float64 `json:"hitRate"`    // 胜率(%)
+	MeanReturn float64 `json:"meanReturn"` // 平均收益率(%)
+	MaxReturn  float64 `json:"maxReturn"`  // 最大收益率(%)
+	MinReturn  float64 `json:"minReturn"`  // 最小收益率(%)
+	Sharpe     float64 `json:"sharpe"`     // 夏普比率(按持有天数年化, 无风险利率为0)
+}
+
+// BacktestResult 回测结果
+type BacktestResult struct {
+	StrategyID      int              `json:"strategyId"`      // 策略ID
+	StrategyName    string           `json:"strategyName"`    // 策略名称
+	Config          BacktestConfig   `json:"config"`          // 回测配置
+	Status          string           `json:"status"`          // 结束状态
+	ExecutionTime   time.Time        `json:"executionTime"`   // 执行时间
+	CompletionTime  time.Time        `json:"completionTime"`  // 完成时间
+	TotalStocks     int              `json:"totalStocks"`     // 总股票数
+	ProcessedStocks int              `json:"processedStocks"` // 已处理股票数
+	Signals         []BacktestSignal `json:"signals"`         // 带日期的信号及其前瞻收益
+	Horizons        []HorizonStats   `json:"horizons"`        // 各周期统计
+	AvgDrawdown     float64          `json:"avgDrawdown"`     // 平均回撤(%)
+	MaxDrawdown     float64          `json:"maxDrawdown"`     // 最大回撤(%)
+	ExitCount       int              `json:"exitCount"`       // 由卖出信号平仓的买入信号数
+	ExitHitRate     float64          `json:"exitHitRate"`     // 按卖出信号平仓的胜率(%)
+	MeanExitReturn  float64          `json:"meanExitReturn"`  // 按卖出信号平仓的平均收益率(%)
+
+	Failures *FailureReport `json:"failures,omitempty"` // 处理失败的股票
+}
+
+// backtestCollector 收集回测信号, 状态更新转发给外部更新器
+type backtestCollector struct {
+	StatusUpdater
+	mutex   sync.Mutex
+	signals []StockSignal
+}
+
+func (c *backtestCollector) AddSignal(signal StockSignal) {
+	c.mutex.Lock()
+	c.signals = append(c.signals, signal)
+	c.mutex.Unlock()
+}
+
+// validateBacktestConfig 验证回测配置
+func validateBacktestConfig(config *BacktestConfig) error {
+	if config.Freq == "" {
+		config.Freq = types.FREQ_DAILY_HFQ
+	}
+
+	start, err := time.Parse("2006-01-02", config.StartDate)
+	if err != nil {
+		return NewInvalidConfigError("StartDate", err)
+	}
+	end, err := time.Parse("2006-01-02", config.EndDate)
+	if err != nil {
+		return NewInvalidConfigError("EndDate", err)
+	}
+	if end.Before(start) {
+		return NewInvalidConfigError("EndDate", fmt.Errorf("end date %s is before start date %s", config.EndDate, config.StartDate))
+	}
+	if config.ScoreEndDate != "" {
+		scoreEnd, err := time.Parse("2006-01-02", config.ScoreEndDate)
+		if err != nil {
+			return NewInvalidConfigError("ScoreEndDate", err)
+		}
+		if scoreEnd.Before(end) {
+			return NewInvalidConfigError("ScoreEndDate", fmt.Errorf("score end date %s is before end date %s", config.ScoreEndDate, config.EndDate))
+		}
+	}
+
+	return nil
+}
+
+// Backtest 在历史区间内逐日回放策略, 统计信号的前瞻收益
+// cache不为空时K线数据从共享缓存读取, 用于多次回测同一区间(如参数优化)
+func (e *Engine) Backtest(strategy *Strategy, config BacktestConfig, cache *KLineCache) (*BacktestResult, error) {
+	if err := validateBacktestConfig(&config); err != nil {
+		return nil, err
+	}
+	if err := checkBacktest(strategy); err != nil {
+		return nil, NewInvalidConfigError("Backtest", err)
+	}
+
+	collector := &backtestCollector{StatusUpdater: e.statusUpdater}
+	if err := e.run(strategy, collector, RunOptions{Backtest: &config, Params: config.Params, KLineCache: cache}); err != nil {
+		return nil, err
+	}
+
+	status := e.GetStatus()
+	result := &BacktestResult{
+		StrategyID:      strategy.ID,
+		StrategyName:    strategy.Name,
+		Config:          config,
+		Status:          status.Status,
+		ExecutionTime:   status.StartTime,
+		TotalStocks:     status.TotalStocks,
+		ProcessedStocks: status.ProcessedCount,
+	}
+	failures := e.GetFailures()
+	result.Failures = &failures
+
+	collector.mutex.Lock()
+	signals := make([]StockSignal, len(collector.signals))
+	copy(signals, collector.signals)
+	collector.mutex.Unlock()
+
+	result.Signals = e.evaluateSignals(signals, config.Freq, config.ScoreEndDate, cache)
+	SummarizeBacktest(result)
+	result.CompletionTime = time.Now()
+
+	return result, nil
+}
+
+// evaluateSignals 计算每个信号的前瞻收益和回撤, scoreEnd不为空时只使用该日期及之前的K线
+func (e *Engine) evaluateSignals(signals []StockSignal, freq types.KLineFreq, scoreEnd string, cache *KLineCache) []BacktestSignal {
+	sort.SliceStable(signals, func(i, j int) bool {
+		if signals[i].Date != signals[j].Date {
+			return signals[i].Date < signals[j].Date
+		}
+		return signals[i].Code < signals[j].Code
+	})
+
+	// 按股票索引卖出信号, 用于与买入信号配对
+	exits := make(map[string][]StockSignal)
+	for _, signal := range signals {
+		if signal.IsExit() {
+			exits[signal.Code] = append(exits[signal.Code], signal)
+		}
+	}
+
+	seriesCache := make(map[string][]types.KLineData)
+	results := make([]BacktestSignal, 0, len(signals))
+	for _, signal := range signals {
+		if signal.IsExit() {
+			results = append(results, BacktestSignal{StockSignal: signal, Returns: make(map[int]float64)})
+			continue
+		}
+
+		series, ok := seriesCache[signal.Code]
+		if !ok {
+			var data []types.KLineData
+			var err error
+			if cache != nil {
+				data, err = cache.Get(context.Background(), e.apiClient, signal.Code, freq)
+			} else {
+				data, err = e.apiClient.Market.GetKLineData(context.Background(), signal.Code, freq)
+			}
+			if err != nil {
+				fmt.Printf("Warning: 获取 %s K线数据失败: %v\n", signal.Code, err)
+			}
+			series = truncateKLine(data, scoreEnd)
+			seriesCache[signal.Code] = series
+		}
+
+		result := evaluateSignal(signal, series)
+		for _, exit := range exits[signal.Code] {
+			if exit.Date > signal.Date {
+				evaluateExit(&result, exit, series)
+				break
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// evaluateExit 以卖出信号日收盘价计算买入信号的持有收益
+func evaluateExit(result *BacktestSignal, exit StockSignal, series []types.KLineData) {
+	entryIdx := len(truncateKLine(series, result.Date)) - 1
+	exitIdx := len(truncateKLine(series, exit.Date)) - 1
+	if entryIdx < 0 || exitIdx <= entryIdx || series[entryIdx].Close <= 0 {
+		return
+	}
+
+	result.ExitDate = exit.Date
+	result.ExitReturn = (series[exitIdx].Close/series[entryIdx].Close - 1) * 100
+	result.HoldingDays = exitIdx - entryIdx
+}
+
+// evaluateSignal 以信号日收盘价为基准计算前瞻收益
+func evaluateSignal(signal StockSignal, series []types.KLineData) BacktestSignal {
+	result := BacktestSignal{
+		StockSignal: signal,
+		Returns:     make(map[int]float64),
+	}
+
+	idx := len(truncateKLine(series, signal.Date)) - 1
+	if idx < 0 || barDate(series[idx].Time) != signal.Date {
+		return result
+	}
+
+	entry := series[idx].Close
+	if entry <= 0 {
+		return result
+	}
+
+	for _, days := range BacktestHorizons {
+		if idx+days < len(series) {
+			result.Returns[days] = (series[idx+days].Close/entry - 1) * 100
+		}
+	}
+
+	// 在最长统计周期内按收盘价计算最大回撤
+	maxHorizon := BacktestHorizons[len(BacktestHorizons)-1]
+	peak := entry
+	for i := idx + 1; i < len(series) && i <= idx+maxHorizon; i++ {
+		price := series[i].Close
+		if price > peak {
+			peak = price
+		}
+		if drawdown := (1 - price/peak) * 100; drawdown > result.Drawdown {
+			result.Drawdown = drawdown
+		}
+	}
+
+	return result
+}
+
+// SummarizeBacktest 根据信号汇总各周期胜率、平均收益和回撤
+func SummarizeBacktest(result *BacktestResult) {
+	result.Horizons = make([]HorizonStats, 0, len(BacktestHorizons))
+	for _, days := range BacktestHorizons {
+		stats := HorizonStats{Days: days}
+		var sum, sumSquares float64
+		var wins int
+		for _, signal := range result.Signals {
+			if signal.IsExit() {
+				continue
+			}
+			ret, ok := signal.Returns[days]
+			if !ok {
+				continue
+			}
+			if stats.Count == 0 || ret > stats.MaxReturn {
+				stats.MaxReturn = ret
+			}
+			if stats.Count == 0 || ret < stats.MinReturn {
+				stats.MinReturn = ret
+			}
+			stats.Count++
+			sum += ret
+			sumSquares += ret * ret
+			if ret > 0 {
+				wins++
+			}
+		}
+		if stats.Count > 0 {
+			stats.MeanReturn = sum / float64(stats.Count)
+			stats.HitRate = float64(wins) / float64(stats.Count) * 100
+		}
+		if stats.Count > 1 {
+			variance := (sumSquares - sum*sum/float64(stats.Count)) / float64(stats.Count-1)
+			if variance > 0 {
+				stats.Sharpe = stats.MeanReturn / math.Sqrt(variance) * math.Sqrt(252/float64(days))
+			}
+		}
+		result.Horizons = append(result.Horizons, stats)
+	}
+
+	var sumDrawdown, sumExitReturn float64
+	var entries, exitWins int
+	for _, signal := range result.Signals {
+		if signal.IsExit() {
+			continue
+		}
+		entries++
+		sumDrawdown += signal.Drawdown
+		if signal.Drawdown > result.MaxDrawdown {
+			result.MaxDrawdown = signal.Drawdown
+		}
+		if signal.ExitDate != "" {
+			result.ExitCount++
+			sumExitReturn += signal.ExitReturn
+			if signal.ExitReturn > 0 {
+				exitWins++
+			}
+		}
+	}
+	if entries > 0 {
+		result.AvgDrawdown = sumDrawdown / float64(entries)
+	}
+	if result.ExitCount > 0 {
+		result.MeanExitReturn = sumExitReturn / float64(result.ExitCount)
+		result.ExitHitRate = float64(exitWins) / float64(result.ExitCount) * 100
+	}
+}
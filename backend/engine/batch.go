@@ -0,0 +1,390 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/universe"
+)
+
+// BatchRun 批量执行中的一个策略
+type BatchRun struct {
+	Strategy *Strategy
+	Options  RunOptions    // 运行选项, 批量执行只做实盘选股, 忽略回测配置、断点和股票池
+	Updater  StatusUpdater // 接收该策略的状态和信号
+}
+
+// batchMember 批量执行中单个策略的运行状态
+type batchMember struct {
+	run     BatchRun
+	state   *engineState // 该策略的状态, 由引擎的stateLock保护
+	metrics *ExecutionMetrics
+	scores  *scoreBoard // 两阶段排序策略的评分
+}
+
+// batchWorker 批量执行的工作单元, 为每个策略各持有一个Lua状态
+type batchWorker struct {
+	workers []*Worker // 与batchMember一一对应, 加载失败的策略为nil
+}
+
+// ExecuteBatch 在一次遍历中对同一股票池运行多个策略(u为空时使用默认股票池): 每只股票的K线和数据接口结果只获取一次,
+// 依次交给各策略的Lua状态处理; 各策略有独立的信号、状态和失败记录, 某个策略加载失败或select出错时只将该策略标记为出错
+func (e *Engine) ExecuteBatch(runs []BatchRun, u *universe.Universe) error {
+	if len(runs) == 0 {
+		return NewInvalidConfigError("Runs", fmt.Errorf("at least one strategy is required"))
+	}
+	seen := make(map[int]bool, len(runs))
+	for _, run := range runs {
+		if run.Strategy == nil || run.Updater == nil {
+			return NewInvalidConfigError("Runs", fmt.Errorf("strategy and updater are required"))
+		}
+		if seen[run.Strategy.ID] {
+			return NewInvalidConfigError("Runs", fmt.Errorf("strategy %d is listed more than once", run.Strategy.ID))
+		}
+		seen[run.Strategy.ID] = true
+	}
+
+	// 检查是否已经在运行
+	if e.IsRunning() {
+		return NewEngineError(ErrEngineAlreadyRunning, "engine is already running", fmt.Errorf("engine is already running"))
+	}
+
+	// 解析股票池
+	stocks, err := e.resolveUniverse(u, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	// 初始化各策略的状态
+	e.metrics = NewExecutionMetrics(int32(len(stocks)))
+	startTime := time.Now()
+	members := make([]*batchMember, len(runs))
+	for i, run := range runs {
+		run.Options.Backtest = nil
+		run.Options.Resume = nil
+		if run.Options.Sandbox == (SandboxLimits{}) {
+			run.Options.Sandbox = e.config.Sandbox
+		}
+
+		state := newEngineState()
+		state.status = StatusRunning
+		state.startTime = startTime
+		state.totalStocks = int32(len(stocks))
+		state.strategyId = run.Strategy.ID
+		state.failures = newFailureCollector()
+		members[i] = &batchMember{
+			run:     run,
+			state:   state,
+			metrics: NewExecutionMetrics(int32(len(stocks))),
+			scores:  &scoreBoard{},
+		}
+	}
+
+	// 初始化引擎状态, 批量执行时整体状态的策略ID为0
+	e.updateState(func(s *engineState) {
+		s.status = StatusRunning
+		s.startTime = startTime
+		s.totalStocks = int32(len(stocks))
+		s.processedCount = 0
+		s.currentStock = ""
+		s.speed = 0
+		s.error = ""
+		s.strategyId = 0
+		s.paused = false
+		s.shouldStop = false
+		s.failures = nil
+		s.checkpoints = nil
+		s.batch = members
+	})
+	for _, m := range members {
+		e.updateMember(m, func(*engineState) {})
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	defer cancel()
+
+	// 创建工作单元
+	workers, err := e.newBatchWorkers(ctx, members)
+	if err != nil {
+		e.updateState(func(s *engineState) {
+			s.status = StatusError
+			s.error = err.Error()
+		})
+		return err
+	}
+	defer func() {
+		for _, bw := range workers {
+			bw.close()
+		}
+	}()
+
+	// 启动状态更新协程
+	done := make(chan struct{})
+	go e.updateBatchStatus(members, done)
+
+	// 启动工作协程, 运行被取消后只取出剩余的股票不再处理
+	stockChan := make(chan types.Index, len(workers)*2)
+	var wg sync.WaitGroup
+	for _, bw := range workers {
+		wg.Add(1)
+		go func(bw *batchWorker) {
+			defer wg.Done()
+			for stock := range stockChan {
+				if ctx.Err() != nil {
+					continue
+				}
+				bw.processStock(ctx, stock, members)
+				e.metrics.IncrementProcessed()
+			}
+		}(bw)
+	}
+
+	// 设置了沙箱内存限制时, 进程存活堆内存在运行期间增长超过最小的限制则停止运行, 各策略共用一个检查
+	var memoryLimit uint64
+	for _, m := range members {
+		if limit := m.run.Options.Sandbox.MemoryLimit; limit > 0 && (memoryLimit == 0 || limit < memoryLimit) {
+			memoryLimit = limit
+		}
+	}
+	var memoryExceeded atomic.Bool
+	stopWatch := watchMemory(memoryLimit, func() {
+		memoryExceeded.Store(true)
+		cancel()
+	})
+	defer stopWatch()
+
+	// 提交股票
+	stopped := false
+submit:
+	for _, stock := range stocks {
+		if e.state.shouldStop {
+			stopped = true
+			break
+		}
+
+		// 检查是否暂停
+		for e.IsPaused() && !e.state.shouldStop {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		e.updateState(func(s *engineState) {
+			s.currentStock = fmt.Sprintf("%s(%s)", stock.Name, stock.Code)
+		})
+		select {
+		case <-ctx.Done():
+			break submit
+		case stockChan <- stock:
+		}
+	}
+	if stopped {
+		cancel()
+	}
+	close(stockChan)
+	wg.Wait()
+	close(done)
+
+	// 确定各策略的最终状态, 两阶段排序策略在全部股票评分完成后运行select
+	interrupted := e.ctx.Err()
+	var memoryErr error
+	if memoryExceeded.Load() {
+		memoryErr = memoryLimitError(memoryLimit)
+	}
+	for i, m := range members {
+		e.stateLock.RLock()
+		failed := m.state.status == StatusError
+		e.stateLock.RUnlock()
+		if failed {
+			continue
+		}
+
+		var runErr error
+		status := StatusCompleted
+		switch {
+		case memoryErr != nil:
+			runErr = memoryErr
+		case stopped:
+			status = StatusStopped
+		case interrupted != nil:
+			runErr = NewEngineError(ErrEngineTimeout, "execution interrupted", interrupted)
+		default:
+			if w := workers[0].workers[i]; w.isRanking() {
+				if err := w.callSelect(m.scores.ranked()); err != nil {
+					runErr = scriptError(err, fmt.Sprintf("failed to run %s", hookSelect))
+				}
+			}
+		}
+
+		processed := m.metrics.processedCount.Load()
+		e.updateMember(m, func(s *engineState) {
+			s.processedCount = processed
+			s.speed = 0
+			s.status = status
+			if runErr != nil {
+				s.status = StatusError
+				s.error = runErr.Error()
+			}
+		})
+	}
+
+	// 确保最终状态正确
+	e.updateState(func(s *engineState) {
+		s.processedCount = e.metrics.processedCount.Load()
+		s.currentStock = ""
+		switch {
+		case memoryErr != nil:
+			s.status = StatusError
+			s.error = memoryErr.Error()
+		case stopped:
+			s.status = StatusStopped
+		case interrupted != nil:
+			s.status = StatusError
+			s.error = NewEngineError(ErrEngineTimeout, "execution interrupted", interrupted).Error()
+		default:
+			s.status = StatusCompleted
+			s.shouldStop = true
+		}
+	})
+	if memoryErr != nil {
+		return memoryErr
+	}
+	if interrupted != nil && !stopped {
+		return NewEngineError(ErrEngineTimeout, "execution interrupted", interrupted)
+	}
+	return nil
+}
+
+// newBatchWorkers 为每个工作协程创建各策略的工作单元, 加载失败的策略标记为出错, 全部失败时返回错误
+func (e *Engine) newBatchWorkers(ctx context.Context, members []*batchMember) ([]*batchWorker, error) {
+	workers := make([]*batchWorker, e.config.WorkerPoolSize)
+	for i := range workers {
+		workers[i] = &batchWorker{workers: make([]*Worker, len(members))}
+	}
+
+	loaded := 0
+	for j, m := range members {
+		for i, bw := range workers {
+			worker, err := NewWorker(i, m.run.Strategy, m.metrics, ctx, e.apiClient, m.run.Updater, m.run.Options)
+			if err != nil {
+				for _, created := range workers[:i] {
+					created.workers[j].Close()
+					created.workers[j] = nil
+				}
+				e.updateMember(m, func(s *engineState) {
+					s.status = StatusError
+					s.error = fmt.Sprintf("failed to create worker: %v", err)
+				})
+				break
+			}
+			worker.scores = m.scores
+			bw.workers[j] = worker
+		}
+		if workers[0].workers[j] != nil {
+			loaded++
+		}
+	}
+
+	if loaded == 0 {
+		for _, bw := range workers {
+			bw.close()
+		}
+		return nil, NewEngineError(ErrWorkerPoolCreation, "failed to create worker pool", fmt.Errorf("none of the strategies could be loaded"))
+	}
+	return workers, nil
+}
+
+// processStock 依次用各策略处理同一只股票, 各策略共享该股票的K线和数据接口结果
+func (b *batchWorker) processStock(ctx context.Context, stock types.Index, members []*batchMember) {
+	klineMemo := make(map[string][]types.KLineData)
+	dataMemo := make(map[string]reflect.Value)
+	for i, w := range b.workers {
+		if w == nil {
+			continue
+		}
+		w.klineMemo, w.dataMemo = klineMemo, dataMemo
+		err := w.ProcessStock(stock)
+		w.klineMemo, w.dataMemo = nil, nil
+
+		// 运行被取消时未处理完的股票不算失败
+		if err != nil && ctx.Err() == nil {
+			members[i].state.failures.add(stock, err)
+		}
+	}
+}
+
+// close 关闭各策略的工作单元
+func (b *batchWorker) close() {
+	for _, w := range b.workers {
+		if w != nil {
+			w.Close()
+		}
+	}
+}
+
+// updateMember 更新批量执行中单个策略的状态并通知该策略的状态更新器
+func (e *Engine) updateMember(m *batchMember, update func(*engineState)) {
+	e.stateLock.Lock()
+	update(m.state)
+	status := m.state.toExecutionStatus()
+	e.stateLock.Unlock()
+
+	m.run.Updater.UpdateStatus(status)
+}
+
+// updateBatchStatus 更新整体和各策略的进度, 直到done关闭
+func (e *Engine) updateBatchStatus(members []*batchMember, done <-chan struct{}) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			stats := e.metrics.GetStats()
+			e.updateState(func(s *engineState) {
+				s.processedCount = int32(stats.ProcessedStocks)
+				s.speed = stats.CurrentSpeed
+			})
+			for _, m := range members {
+				stats := m.metrics.GetStats()
+				e.updateMember(m, func(s *engineState) {
+					if s.status != StatusRunning {
+						return
+					}
+					s.processedCount = int32(stats.ProcessedStocks)
+					s.speed = stats.CurrentSpeed
+				})
+			}
+		}
+	}
+}
+
+// GetBatchStatus 获取当前(或最近一次)批量执行中各策略的状态
+func (e *Engine) GetBatchStatus() []ExecutionStatus {
+	e.stateLock.RLock()
+	defer e.stateLock.RUnlock()
+	statuses := make([]ExecutionStatus, 0, len(e.state.batch))
+	for _, m := range e.state.batch {
+		statuses = append(statuses, m.state.toExecutionStatus())
+	}
+	return statuses
+}
+
+// GetBatchFailures 获取当前(或最近一次)批量执行中指定策略处理失败的股票
+func (e *Engine) GetBatchFailures(strategyID int) FailureReport {
+	e.stateLock.RLock()
+	var failures *failureCollector
+	for _, m := range e.state.batch {
+		if m.run.Strategy.ID == strategyID {
+			failures = m.state.failures
+			break
+		}
+	}
+	e.stateLock.RUnlock()
+	return failures.report()
+}
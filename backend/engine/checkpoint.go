@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/universe"
+
+	"github.com/tidwall/buntdb"
+)
+
+// checkpointInterval 运行中保存断点的间隔
+const checkpointInterval = 10 * time.Second
+
+// checkpointKey 断点在数据库中的键, 引擎同一时间只运行一个策略, 只保存一个断点
+const checkpointKey = "checkpoint:execution"
+
+// Checkpoint 实盘选股运行的断点, 记录已处理的股票和已发出的信号, 用于中断后继续运行
+type Checkpoint struct {
+	StrategyID     int                    `json:"strategyId"`
+	StrategyName   string                 `json:"strategyName"`
+	StartTime      time.Time              `json:"startTime"`      // 原运行的开始时间, 继续运行时保持不变
+	UpdateTime     time.Time              `json:"updateTime"`     // 断点保存时间
+	TotalStocks    int                    `json:"totalStocks"`    // 总股票数
+	ProcessedCount int                    `json:"processedCount"` // 处理成功的股票数
+	Processed      []string               `json:"processed"`      // 已处理(含失败)的股票代码, 继续运行时跳过
+	Signals        []StockSignal          `json:"signals"`        // 已发出的信号
+	Scores         []RankedStock          `json:"scores,omitempty"`
+	Failures       FailureReport          `json:"failures"`
+	Params         map[string]interface{} `json:"params,omitempty"`
+	Positions      []Position             `json:"positions,omitempty"`
+
+	Universe *universe.Universe `json:"universe,omitempty"` // 运行的股票池, 继续运行时重新解析
+}
+
+// CheckpointStore 断点存储
+type CheckpointStore interface {
+	SaveCheckpoint(checkpoint *Checkpoint) error
+	LoadCheckpoint() (*Checkpoint, error) // 没有断点时返回nil
+	ClearCheckpoint() error
+}
+
+// buntCheckpointStore 基于buntdb的断点存储
+type buntCheckpointStore struct {
+	db *buntdb.DB
+}
+
+// NewCheckpointStore 创建基于buntdb的断点存储
+func NewCheckpointStore(db *buntdb.DB) CheckpointStore {
+	return &buntCheckpointStore{db: db}
+}
+
+// SaveCheckpoint 保存断点
+func (s *buntCheckpointStore) SaveCheckpoint(checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(checkpointKey, string(data), nil)
+		return err
+	})
+}
+
+// LoadCheckpoint 加载断点
+func (s *buntCheckpointStore) LoadCheckpoint() (*Checkpoint, error) {
+	var data string
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		data, err = tx.Get(checkpointKey)
+		return err
+	})
+	if err == buntdb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal([]byte(data), &checkpoint); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint: %v", err)
+	}
+	return &checkpoint, nil
+}
+
+// ClearCheckpoint 删除断点
+func (s *buntCheckpointStore) ClearCheckpoint() error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(checkpointKey)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+// checkpointer 记录运行进度, 按工作单元完成的股票累积已处理代码和信号
+type checkpointer struct {
+	mu         sync.Mutex
+	checkpoint Checkpoint
+	processed  map[string]bool
+	dirty      bool
+}
+
+// newCheckpointer 创建运行进度记录, resume不为空时从断点继续
+func newCheckpointer(strategy *Strategy, startTime time.Time, totalStocks int, options RunOptions) *checkpointer {
+	c := &checkpointer{
+		checkpoint: Checkpoint{
+			StrategyID:   strategy.ID,
+			StrategyName: strategy.Name,
+			StartTime:    startTime,
+			TotalStocks:  totalStocks,
+			Params:       options.Params,
+			Positions:    options.Positions,
+			Universe:     options.Universe,
+		},
+		processed: make(map[string]bool),
+	}
+	if resume := options.Resume; resume != nil {
+		c.checkpoint.ProcessedCount = resume.ProcessedCount
+		c.checkpoint.Processed = append(c.checkpoint.Processed, resume.Processed...)
+		c.checkpoint.Signals = append(c.checkpoint.Signals, resume.Signals...)
+		for _, code := range resume.Processed {
+			c.processed[code] = true
+		}
+	}
+	return c
+}
+
+// isProcessed 股票是否已在断点前处理
+func (c *checkpointer) isProcessed(code string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.processed[code]
+}
+
+// done 记录处理完成的股票及其发出的信号
+func (c *checkpointer) done(stock types.Index, signals []StockSignal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.processed[stock.Code] = true
+	c.checkpoint.Processed = append(c.checkpoint.Processed, stock.Code)
+	c.checkpoint.Signals = append(c.checkpoint.Signals, signals...)
+	c.dirty = true
+}
+
+// snapshot 生成断点, 处理成功数、评分和失败汇总取自工作池
+func (c *checkpointer) snapshot(pool *WorkerPool, metrics *ExecutionMetrics) *Checkpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	checkpoint := c.checkpoint
+	checkpoint.UpdateTime = time.Now()
+	checkpoint.ProcessedCount = int(metrics.processedCount.Load())
+	checkpoint.Processed = append([]string(nil), c.checkpoint.Processed...)
+	checkpoint.Signals = append([]StockSignal(nil), c.checkpoint.Signals...)
+	checkpoint.Scores = pool.scores.ranked()
+	checkpoint.Failures = pool.Failures()
+	c.dirty = false
+	return &checkpoint
+}
+
+// changed 上次保存后是否有新完成的股票
+func (c *checkpointer) changed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dirty
+}
+
+// restore 从断点恢复评分和失败记录
+func (p *WorkerPool) restore(checkpoint *Checkpoint) {
+	for _, stock := range checkpoint.Scores {
+		p.scores.add(stock)
+	}
+	p.failures.restore(checkpoint.Failures)
+}
+
+// SaveCheckpoint 立即保存当前运行的断点(如应用关闭前), 没有运行中的实盘选股时不做任何操作
+func (e *Engine) SaveCheckpoint() error {
+	e.stateLock.RLock()
+	checkpoints, pool, metrics := e.state.checkpoints, e.workerPool, e.metrics
+	e.stateLock.RUnlock()
+	if checkpoints == nil || pool == nil || e.config.Checkpoints == nil {
+		return nil
+	}
+	return e.config.Checkpoints.SaveCheckpoint(checkpoints.snapshot(pool, metrics))
+}
+
+// LoadCheckpoint 加载中断运行的断点, 没有断点时返回nil
+func (e *Engine) LoadCheckpoint() (*Checkpoint, error) {
+	if e.config.Checkpoints == nil {
+		return nil, nil
+	}
+	return e.config.Checkpoints.LoadCheckpoint()
+}
+
+// ClearCheckpoint 丢弃中断运行的断点
+func (e *Engine) ClearCheckpoint() error {
+	if e.config.Checkpoints == nil {
+		return nil
+	}
+	return e.config.Checkpoints.ClearCheckpoint()
+}
+
+// finishCheckpoint 运行结束时处理断点: 正常完成或被用户停止时删除断点, 出错时保存断点以便继续运行
+func (e *Engine) finishCheckpoint(c *checkpointer, pool *WorkerPool) {
+	var err error
+	switch e.GetStatus().Status {
+	case StatusCompleted, StatusStopped:
+		err = e.config.Checkpoints.ClearCheckpoint()
+	default:
+		err = e.config.Checkpoints.SaveCheckpoint(c.snapshot(pool, e.metrics))
+	}
+	if err != nil {
+		fmt.Printf("Warning: 更新断点失败: %v\n", err)
+	}
+}
+
+// saveCheckpoints 定期保存断点, 直到done关闭
+func (e *Engine) saveCheckpoints(c *checkpointer, pool *WorkerPool, done <-chan struct{}) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := e.config.Checkpoints.SaveCheckpoint(c.snapshot(pool, e.metrics)); err != nil {
+				fmt.Printf("Warning: 保存断点失败: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+
+	"github.com/tidwall/buntdb"
+)
+
+// slowStrategy 每只股票忙等一段时间, 保证取消时还有股票排队
+const slowStrategy = `
+function process_stock(stock)
+    local start = os.clock()
+    while os.clock() - start < 0.02 do end
+end
+`
+
+// TestCancelledRunKeepsCheckpoint 运行中途取消时工作池能够退出, 且保留断点以便继续运行
+func TestCancelledRunKeepsCheckpoint(t *testing.T) {
+	db, err := buntdb.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// 股票列表直接写入API缓存, 运行期间不访问网络
+	const total = 200
+	stocks := make([]types.Index, total)
+	for i := range stocks {
+		stocks[i] = types.Index{Code: fmt.Sprintf("600%03d", i), Name: fmt.Sprintf("股票%d", i)}
+	}
+	list, _ := json.Marshal(stocks)
+	if err := db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set("cache:hslt/list", string(list), nil)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := api.NewClient("http://127.0.0.1:0", "test", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "slow.lua")
+	if err := os.WriteFile(path, []byte(slowStrategy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewCheckpointStore(db)
+	e, err := NewEngine(ExecutionConfig{
+		APIClient:      client,
+		Context:        ctx,
+		WorkerPoolSize: 2,
+		Checkpoints:    store,
+	}, discardUpdater{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- e.Execute(&Strategy{ID: 1, Name: "slow", FilePath: path}, RunOptions{})
+	}()
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	select {
+	case err = <-result:
+	case <-time.After(10 * time.Second):
+		t.Fatal("run did not return after cancel")
+	}
+
+	var engineErr *EngineError
+	if !errors.As(err, &engineErr) || engineErr.Code != ErrEngineTimeout {
+		t.Fatalf("expected interrupted error, got %v", err)
+	}
+
+	checkpoint, err := store.LoadCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil {
+		t.Fatal("checkpoint was cleared after cancel")
+	}
+	if len(checkpoint.Processed) == 0 || len(checkpoint.Processed) >= total {
+		t.Fatalf("expected a partial checkpoint, got %d of %d processed", len(checkpoint.Processed), total)
+	}
+}
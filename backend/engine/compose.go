@@ -0,0 +1,409 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// 组合表达式运算符
+const (
+	composeAnd = "AND"
+	composeOr  = "OR"
+	composeNot = "NOT"
+)
+
+// ComposeExpr 组合策略表达式, 如 "1 AND NOT 2", "(1 OR 3) AND NOT 2"
+// 叶子节点为策略ID, 运算符优先级为 NOT > AND > OR
+type ComposeExpr struct {
+	Op       string         // 运算符, 叶子节点为空
+	ID       int            // 叶子节点的策略ID
+	Operands []*ComposeExpr // 子表达式
+}
+
+// ParseCompose 解析组合策略表达式
+func ParseCompose(expr string) (*ComposeExpr, error) {
+	tokens, err := tokenizeCompose(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty compose expression")
+	}
+
+	p := &composeParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q in compose expression", p.tokens[p.pos])
+	}
+	return node, nil
+}
+
+// IDs 表达式引用的策略ID(按首次出现顺序, 不重复)
+func (e *ComposeExpr) IDs() []int {
+	var ids []int
+	seen := make(map[int]bool)
+	var walk func(*ComposeExpr)
+	walk = func(node *ComposeExpr) {
+		if node.Op == "" {
+			if !seen[node.ID] {
+				seen[node.ID] = true
+				ids = append(ids, node.ID)
+			}
+			return
+		}
+		for _, operand := range node.Operands {
+			walk(operand)
+		}
+	}
+	walk(e)
+	return ids
+}
+
+// Eval 根据各子策略是否发出信号计算表达式的值
+func (e *ComposeExpr) Eval(fired map[int]bool) bool {
+	switch e.Op {
+	case composeNot:
+		return !e.Operands[0].Eval(fired)
+	case composeAnd:
+		for _, operand := range e.Operands {
+			if !operand.Eval(fired) {
+				return false
+			}
+		}
+		return true
+	case composeOr:
+		for _, operand := range e.Operands {
+			if operand.Eval(fired) {
+				return true
+			}
+		}
+		return false
+	default:
+		return fired[e.ID]
+	}
+}
+
+// String 规范化的表达式文本
+func (e *ComposeExpr) String() string {
+	switch e.Op {
+	case composeNot:
+		return composeNot + " " + e.Operands[0].operandString()
+	case composeAnd, composeOr:
+		parts := make([]string, len(e.Operands))
+		for i, operand := range e.Operands {
+			parts[i] = operand.operandString()
+		}
+		return strings.Join(parts, " "+e.Op+" ")
+	default:
+		return strconv.Itoa(e.ID)
+	}
+}
+
+// operandString 作为操作数时的文本, 二元运算加括号
+func (e *ComposeExpr) operandString() string {
+	if e.Op == composeAnd || e.Op == composeOr {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
+
+// tokenizeCompose 将表达式拆分为策略ID、运算符和括号
+func tokenizeCompose(expr string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsDigit(r) || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || unicode.IsLetter(runes[i])) {
+				i++
+			}
+			word := strings.ToUpper(string(runes[start:i]))
+			if word != composeAnd && word != composeOr && word != composeNot {
+				if _, err := strconv.Atoi(word); err != nil {
+					return nil, fmt.Errorf("invalid token %q in compose expression", string(runes[start:i]))
+				}
+			}
+			tokens = append(tokens, word)
+		default:
+			return nil, fmt.Errorf("invalid character %q in compose expression", r)
+		}
+	}
+	return tokens, nil
+}
+
+// composeParser 递归下降解析器
+type composeParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *composeParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *composeParser) parseOr() (*ComposeExpr, error) {
+	return p.parseBinary(composeOr, p.parseAnd)
+}
+
+func (p *composeParser) parseAnd() (*ComposeExpr, error) {
+	return p.parseBinary(composeAnd, p.parseUnary)
+}
+
+// parseBinary 解析由同一运算符连接的操作数序列
+func (p *composeParser) parseBinary(op string, operand func() (*ComposeExpr, error)) (*ComposeExpr, error) {
+	first, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	operands := []*ComposeExpr{first}
+	for p.peek() == op {
+		p.pos++
+		next, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &ComposeExpr{Op: op, Operands: operands}, nil
+}
+
+func (p *composeParser) parseUnary() (*ComposeExpr, error) {
+	token := p.peek()
+	switch token {
+	case "":
+		return nil, fmt.Errorf("unexpected end of compose expression")
+	case composeNot:
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &ComposeExpr{Op: composeNot, Operands: []*ComposeExpr{operand}}, nil
+	case "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) in compose expression")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	id, err := strconv.Atoi(token)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected token %q in compose expression", token)
+	}
+	p.pos++
+	return &ComposeExpr{ID: id}, nil
+}
+
+// ComponentParamName 组合策略中子策略参数的名称, 如 "1.SHORT_MA"
+func ComponentParamName(strategyID int, name string) string {
+	return fmt.Sprintf("%d.%s", strategyID, name)
+}
+
+// componentParams 从组合策略参数中提取指定子策略的参数
+func componentParams(component *Strategy, params map[string]interface{}) (map[string]interface{}, error) {
+	overrides := make(map[string]interface{})
+	prefix := ComponentParamName(component.ID, "")
+	for name, value := range params {
+		if strings.HasPrefix(name, prefix) {
+			overrides[strings.TrimPrefix(name, prefix)] = value
+		}
+	}
+	return ResolveParams(component.Params, overrides)
+}
+
+// componentWorker 组合策略中的子策略
+type componentWorker struct {
+	strategy *Strategy
+	worker   *Worker
+}
+
+// newComponentWorkers 为组合策略的每个子策略创建独立的Lua状态, 子策略发出的信号不直接发送
+func newComponentWorkers(w *Worker) ([]componentWorker, error) {
+	expr, err := ParseCompose(w.strategy.Compose)
+	if err != nil {
+		return nil, NewEngineError(ErrInvalidStrategy, "invalid compose expression", err)
+	}
+
+	byID := make(map[int]*Strategy, len(w.strategy.Components))
+	for _, component := range w.strategy.Components {
+		byID[component.ID] = component
+	}
+
+	components := make([]componentWorker, 0, len(byID))
+	closeAll := func() {
+		for _, c := range components {
+			c.worker.Close()
+		}
+	}
+	for _, id := range expr.IDs() {
+		component, ok := byID[id]
+		if !ok {
+			closeAll()
+			return nil, NewEngineError(ErrInvalidStrategy, "compose expression references unknown strategy",
+				fmt.Errorf("strategy %d is not a component of %s", id, w.strategy.Name))
+		}
+
+		options := w.options
+		options.Positions = nil
+		options.Params, err = componentParams(component, w.options.Params)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		worker, err := NewWorker(w.id, component, w.metrics, w.ctx, w.apiClient, discardUpdater{}, options)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		if worker.isRanking() {
+			worker.Close()
+			closeAll()
+			return nil, NewEngineError(ErrInvalidStrategy, "ranking strategies cannot be composed",
+				fmt.Errorf("strategy %d defines %s", id, hookScoreStock))
+		}
+		components = append(components, componentWorker{strategy: component, worker: worker})
+	}
+
+	w.compose = expr
+	return components, nil
+}
+
+// callComposite 在同一份数据上依次运行各子策略, 按表达式组合结果后发出信号
+func (w *Worker) callComposite(stock types.Index) error {
+	// 子策略共享同一只股票的K线缓存, 数据只获取一次
+	memo := w.klineMemo
+	if memo == nil {
+		memo = make(map[string][]types.KLineData)
+	}
+
+	fired := make(map[int]bool, len(w.components))
+	var signals []StockSignal
+	var names []string
+	for _, c := range w.components {
+		c.worker.current = stock
+		c.worker.emitted = c.worker.emitted[:0]
+		c.worker.asOf = w.asOf
+		c.worker.klineMemo = memo
+		c.worker.dataMemo = w.dataMemo
+		err := c.worker.callStrategy(stock)
+		c.worker.klineMemo = nil
+		c.worker.dataMemo = nil
+		if err != nil {
+			return fmt.Errorf("component %s: %w", c.strategy.Name, err)
+		}
+
+		if signal := c.worker.emittedType(stock.Code, SignalTypeEntry); signal != nil {
+			fired[c.strategy.ID] = true
+			signals = append(signals, *signal)
+			names = append(names, c.strategy.Name)
+		}
+	}
+
+	if !w.compose.Eval(fired) {
+		return nil
+	}
+
+	combined := combineSignals(stock, w.compose, signals, names, w.asOf)
+	if len(signals) == 0 {
+		// 表达式不依赖子策略发出信号(如 NOT 2)时没有可用的价格, 取最近一根日线
+		data, err := w.KLineData(stock.Code, types.FREQ_DAILY_HFQ)
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		last := data[len(data)-1]
+		combined.Price = last.Close
+		combined.Turnover = last.Turnover
+		combined.Change = last.Change
+	}
+	w.emit(combined)
+	return nil
+}
+
+// combineSignals 合并发出信号的子策略结果, 信号原因中注明各子策略
+func combineSignals(stock types.Index, expr *ComposeExpr, signals []StockSignal, names []string, date string) StockSignal {
+	combined := StockSignal{
+		Code: stock.Code,
+		Name: stock.Name,
+		Date: date,
+		Type: SignalTypeEntry,
+	}
+
+	reasons := make([]string, len(signals))
+	tags := make(map[string]bool)
+	for i, signal := range signals {
+		if i == 0 {
+			combined.Price = signal.Price
+			combined.Turnover = signal.Turnover
+			combined.Change = signal.Change
+			combined.Score = signal.Score
+		} else if signal.Score > combined.Score {
+			combined.Score = signal.Score
+		}
+		reasons[i] = fmt.Sprintf("[%s] %s", names[i], signal.Reason)
+		for _, tag := range signal.Tags {
+			if !tags[tag] {
+				tags[tag] = true
+				combined.Tags = append(combined.Tags, tag)
+			}
+		}
+	}
+
+	combined.Reason = fmt.Sprintf("组合条件(%s)成立", expr.String())
+	if len(reasons) > 0 {
+		combined.Reason += ": " + strings.Join(reasons, "; ")
+	}
+	combined.Fields = map[string]interface{}{
+		"components": names,
+	}
+	return combined
+}
+
+// discardUpdater 丢弃状态更新和信号, 用于组合策略的子策略
+type discardUpdater struct{}
+
+func (discardUpdater) UpdateStatus(ExecutionStatus)                            {}
+func (discardUpdater) UpdateProgress(processedStocks int, currentStock string) {}
+func (discardUpdater) AddSignal(StockSignal)                                   {}
+
+// closeComponents 关闭组合策略的子策略
+func (w *Worker) closeComponents() {
+	for _, c := range w.components {
+		c.worker.Close()
+	}
+	w.components = nil
+}
+
+// isComposite 策略是否为组合策略
+func (s *Strategy) isComposite() bool {
+	return s.Compose != ""
+}
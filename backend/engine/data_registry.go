@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"stock-helper-svelte/backend/api"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// DataBinding 行情、公司和财务数据接口到Lua的绑定声明, 通过api.<Module>.<Name>调用
+// 参数按方法签名(不含ctx)从Lua值转换, 返回值中的结构体转换为以小驼峰字段名为键的表;
+// 调用失败时返回nil和错误信息. 返回值实现了publishedReport时, 回测中报告在模拟交易日尚未发布则不可用
+type DataBinding struct {
+	Module      string                          `json:"module"`              // 模块名: market, company, financial
+	Name        string                          `json:"name"`                // Lua函数名
+	Method      func(c *api.Client) interface{} `json:"-"`                   // 返回api.Client上对应的方法, 方法签名为(ctx, args...) (T, error)
+	Args        []string                        `json:"args"`                // 参数名, 与方法参数(不含ctx)一一对应
+	Returns     string                          `json:"returns"`             // 返回值说明
+	Description string                          `json:"description"`         // 接口说明
+	LiveOnly    bool                            `json:"liveOnly"`            // 实时数据, 回测时不可用
+	DateField   string                          `json:"dateField,omitempty"` // 回测时按该日期字段截取到模拟交易日(含)
+}
+
+// dataBindings 暴露给Lua的全部数据接口, 新增接口只需在此登记
+var dataBindings = []DataBinding{
+	{
+		Module:      "market",
+		Name:        "getRealtimeData",
+		Method:      func(c *api.Client) interface{} { return c.Market.GetRealtimeData },
+		Args:        []string{"code"},
+		Returns:     "{price, changePercent, turnover, volumeRatio, pe, pb, totalValue, floatValue, ...}",
+		Description: "实时交易数据",
+		LiveOnly:    true,
+	},
+	{
+		Module:      "market",
+		Name:        "getCapitalFlow",
+		Method:      func(c *api.Client) interface{} { return c.Market.GetCapitalFlow },
+		Args:        []string{"code"},
+		Returns:     "[{time, netInflow, mainForceNetInflow, mainForceInflowRate, turnoverRate, ...}]",
+		Description: "历史资金流向",
+		DateField:   "Time",
+	},
+	{
+		Module:      "market",
+		Name:        "getMainForcePhase",
+		Method:      func(c *api.Client) interface{} { return c.Market.GetMainForcePhase },
+		Args:        []string{"code"},
+		Returns:     "[{time, netInflow3Day, netInflow5Day, netInflow10Day, rate3Day, rate5Day, rate10Day}]",
+		Description: "阶段主力动向",
+		DateField:   "Time",
+	},
+	{
+		Module:      "market",
+		Name:        "getHistoricalTransactions",
+		Method:      func(c *api.Client) interface{} { return c.Market.GetHistoricalTransactions },
+		Args:        []string{"code"},
+		Returns:     "[{time, close, changePercent, netInflowRate, totalNetInflow, superNetInflow, largeNetInflow, ...}]",
+		Description: "历史成交分布",
+		DateField:   "Time",
+	},
+	{
+		Module:      "company",
+		Name:        "getCompanyInfo",
+		Method:      func(c *api.Client) interface{} { return c.Company.GetCompanyInfo },
+		Args:        []string{"code"},
+		Returns:     "{code, name, industry, mainBusiness, listDate, ...}",
+		Description: "公司简介",
+	},
+	{
+		Module:      "company",
+		Name:        "getShareUnlocks",
+		Method:      func(c *api.Client) interface{} { return c.Company.GetShareUnlocks },
+		Args:        []string{"code"},
+		Returns:     "[{unlockDate, unlockShares, marketValue, batch, announceDate}]",
+		Description: "限售解禁",
+		DateField:   "AnnounceDate",
+	},
+	{
+		Module:      "company",
+		Name:        "getDividendHistory",
+		Method:      func(c *api.Client) interface{} { return c.Company.GetDividendHistory },
+		Args:        []string{"code"},
+		Returns:     "[{announceDate, giveStocks, transferStocks, dividendAmount, progress, exRightDate}]",
+		Description: "历年分红",
+		DateField:   "AnnounceDate",
+	},
+	{
+		Module:      "financial",
+		Name:        "getStockFinancialData",
+		Method:      func(c *api.Client) interface{} { return c.Financial.GetStockFinancialData },
+		Args:        []string{"code", "year", "quarter"},
+		Returns:     "{code, year, quarter, profitability, operation, growth, solvency, cashFlow, performance}",
+		Description: "指定报告期的完整财务数据(盈利能力中的roe等), 未披露的部分为nil; 回测时只能获取模拟交易日前已发布的报告",
+	},
+}
+
+// publishedReport 带发布日期的报告(如财务报表), 回测时发布日期晚于模拟交易日的报告不可用
+type publishedReport interface {
+	PublishDate() string
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// dataRefRegex 匹配策略源码中对数据接口的引用
+var dataRefRegex = regexp.MustCompile(`api\.(market|company|financial)\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// DataBindings 获取已登记的数据接口列表(按模块和名称排序)
+func DataBindings() []DataBinding {
+	bindings := make([]DataBinding, len(dataBindings))
+	copy(bindings, dataBindings)
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Module != bindings[j].Module {
+			return bindings[i].Module < bindings[j].Module
+		}
+		return bindings[i].Name < bindings[j].Name
+	})
+	return bindings
+}
+
+// findDataBinding 按模块和名称查找数据接口
+func findDataBinding(module, name string) (DataBinding, bool) {
+	for _, binding := range dataBindings {
+		if binding.Module == module && binding.Name == name {
+			return binding, true
+		}
+	}
+	return DataBinding{}, false
+}
+
+// registerDataTables 根据登记表创建api.market、api.company和api.financial表, 访问未登记的接口时抛出错误
+func (w *Worker) registerDataTables(apiTable *lua.LTable) error {
+	L := w.luaState
+	tables := make(map[string]*lua.LTable)
+	for _, binding := range dataBindings {
+		fn, err := w.bindData(binding)
+		if err != nil {
+			return err
+		}
+
+		table, ok := tables[binding.Module]
+		if !ok {
+			table = L.NewTable()
+			module := binding.Module
+			meta := L.NewTable()
+			L.SetField(meta, "__index", L.NewFunction(func(L *lua.LState) int {
+				L.RaiseError("unknown data binding: api.%s.%s", module, L.CheckString(2))
+				return 0
+			}))
+			L.SetMetatable(table, meta)
+			L.SetField(apiTable, module, table)
+			tables[module] = table
+		}
+		L.SetField(table, binding.Name, L.NewFunction(fn))
+	}
+	return nil
+}
+
+// bindData 根据方法签名生成Lua包装函数
+func (w *Worker) bindData(binding DataBinding) (lua.LGFunction, error) {
+	fnType := reflect.TypeOf(binding.Method(&api.Client{}))
+	name := binding.Module + "." + binding.Name
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("data binding %s is not a function", name)
+	}
+	if fnType.NumIn() != len(binding.Args)+1 || fnType.In(0) != contextType {
+		return nil, fmt.Errorf("data binding %s declares %d args but method takes ctx and %d", name, len(binding.Args), fnType.NumIn()-1)
+	}
+	if fnType.NumOut() != 2 || fnType.Out(1) != errorType {
+		return nil, fmt.Errorf("data binding %s must return (value, error)", name)
+	}
+	if binding.DateField != "" {
+		elem := fnType.Out(0)
+		if elem.Kind() == reflect.Slice {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("data binding %s: %s is not a struct", name, elem)
+		}
+		if field, ok := elem.FieldByName(binding.DateField); !ok || field.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("data binding %s: %s has no string field %s", name, elem, binding.DateField)
+		}
+	}
+
+	return func(L *lua.LState) int {
+		if binding.LiveOnly && w.asOf != "" {
+			luaErr := NewAPIRequestError(name, fmt.Errorf("realtime data is not available in backtest"))
+			L.Push(lua.LNil)
+			L.Push(lua.LString(luaErr.Error()))
+			return 2
+		}
+
+		if w.apiClient == nil {
+			luaErr := NewAPIRequestError(name, fmt.Errorf("API client is not available"))
+			L.Push(lua.LNil)
+			L.Push(lua.LString(luaErr.Error()))
+			return 2
+		}
+
+		ctx := w.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		args := make([]reflect.Value, fnType.NumIn())
+		args[0] = reflect.ValueOf(ctx)
+		for i := 1; i < len(args); i++ {
+			arg, err := luaToReflect(L.Get(i), fnType.In(i))
+			if err != nil {
+				L.ArgError(i, fmt.Sprintf("%s: %v", binding.Args[i-1], err))
+				return 0
+			}
+			args[i] = arg
+		}
+
+		// 批量执行时同一参数的调用只请求一次
+		key := name
+		for _, arg := range args[1:] {
+			key += fmt.Sprintf("|%v", arg.Interface())
+		}
+		value, ok := w.dataMemo[key]
+		if !ok {
+			results := reflect.ValueOf(binding.Method(w.apiClient)).Call(args)
+			if err, _ := results[1].Interface().(error); err != nil {
+				luaErr := NewAPIRequestError(name, err)
+				L.Push(lua.LNil)
+				L.Push(lua.LString(luaErr.Error()))
+				return 2
+			}
+			value = results[0]
+			if w.dataMemo != nil {
+				w.dataMemo[key] = value
+			}
+		}
+
+		if binding.DateField != "" && w.asOf != "" {
+			value = truncateByDate(value, binding.DateField, w.asOf)
+		}
+		if w.asOf != "" && value.Kind() == reflect.Ptr && !value.IsNil() {
+			if report, ok := value.Interface().(publishedReport); ok && report.PublishDate() > w.asOf {
+				luaErr := NewAPIRequestError(name, fmt.Errorf("report is not published until %s", report.PublishDate()))
+				L.Push(lua.LNil)
+				L.Push(lua.LString(luaErr.Error()))
+				return 2
+			}
+		}
+		L.Push(reflectToLua(L, value))
+		return 1
+	}, nil
+}
+
+// truncateByDate 回测模式下去掉日期字段晚于模拟交易日的记录, 日期为空的记录保留
+func truncateByDate(value reflect.Value, field, asOf string) reflect.Value {
+	if value.Kind() != reflect.Slice {
+		return value
+	}
+	result := reflect.MakeSlice(value.Type(), 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		item := reflect.Indirect(value.Index(i))
+		if !item.IsValid() {
+			continue
+		}
+		if date := item.FieldByName(field).String(); date != "" && barDate(date) > asOf {
+			continue
+		}
+		result = reflect.Append(result, value.Index(i))
+	}
+	return result
+}
+
+// checkDataRefs 检查策略源码引用的数据接口是否都已登记, 未登记时加载失败
+func checkDataRefs(filePath string) error {
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(source), "\n") {
+		// 忽略注释部分
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, match := range dataRefRegex.FindAllStringSubmatch(line, -1) {
+			ref := match[1] + "." + match[2]
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			if _, ok := findDataBinding(match[1], match[2]); !ok {
+				unknown = append(unknown, ref)
+			}
+		}
+	}
+
+	if len(unknown) > 0 {
+		return NewEngineError(ErrInvalidStrategy, "strategy references unknown data bindings",
+			fmt.Errorf("api.%s", strings.Join(unknown, ", api.")))
+	}
+	return nil
+}
@@ -3,12 +3,13 @@ package engine
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"stock-helper-svelte/backend/api"
 	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/universe"
 )
 
 // Engine 执行引擎实现
@@ -85,6 +86,14 @@ func (e *Engine) GetStatus() ExecutionStatus {
 	return e.state.toExecutionStatus()
 }
 
+// GetFailures 获取当前(或最近一次)运行中处理失败的股票
+func (e *Engine) GetFailures() FailureReport {
+	e.stateLock.RLock()
+	failures := e.state.failures
+	e.stateLock.RUnlock()
+	return failures.report()
+}
+
 // updateState 更新内部状态
 func (e *Engine) updateState(update func(*engineState)) {
 	e.stateLock.Lock()
@@ -99,40 +108,65 @@ func (e *Engine) updateState(update func(*engineState)) {
 }
 
 // Execute 执行策略
-func (e *Engine) Execute(strategy *Strategy) error {
+func (e *Engine) Execute(strategy *Strategy, options RunOptions) error {
+	options.Backtest = nil
+	return e.run(strategy, e.statusUpdater, options)
+}
+
+// run 在股票列表上运行策略, 信号发送到指定的状态更新器
+func (e *Engine) run(strategy *Strategy, updater StatusUpdater, options RunOptions) error {
 	// 检查是否已经在运行
 	if e.IsRunning() {
 		return NewEngineError(ErrEngineAlreadyRunning, "engine is already running", fmt.Errorf("engine is already running"))
 	}
 
-	// 获取股票列表
-	stocks, err := e.apiClient.Market.GetIndexList(context.Background())
+	// 解析股票池, 回测按开始日期排除新股
+	var asOf time.Time
+	if options.Backtest != nil {
+		asOf, _ = time.ParseInLocation("2006-01-02", options.Backtest.StartDate, time.Local)
+	}
+	stocks, err := e.resolveUniverse(options.Universe, asOf)
 	if err != nil {
-		return NewAPIRequestError("failed to get stock list", err)
+		return err
 	}
 
-	// 过滤股票列表
-	stocks = e.filterStocks(stocks)
-
 	// 初始化 metrics
 	e.metrics = NewExecutionMetrics(int32(len(stocks)))
 
+	// 实盘选股记录运行进度, 从断点继续时沿用原开始时间和已处理数量
+	startTime := time.Now()
+	var checkpoints *checkpointer
+	if options.Backtest == nil {
+		if resume := options.Resume; resume != nil {
+			startTime = resume.StartTime
+			e.metrics.processedCount.Store(int32(resume.ProcessedCount))
+			e.metrics.lastProcessed = int32(resume.ProcessedCount)
+		}
+		checkpoints = newCheckpointer(strategy, startTime, len(stocks), options)
+	}
+
 	// 初始化状态
 	e.updateState(func(s *engineState) {
 		s.status = StatusRunning
-		s.startTime = time.Now()
+		s.startTime = startTime
 		s.totalStocks = int32(len(stocks))
-		s.processedCount = 0
+		s.processedCount = e.metrics.processedCount.Load()
 		s.currentStock = ""
 		s.speed = 0
 		s.error = ""
 		s.strategyId = strategy.ID
 		s.paused = false
 		s.shouldStop = false
+		s.checkpoints = checkpoints
 	})
 
+	// 未单独指定时使用引擎配置的沙箱限制
+	if options.Sandbox == (SandboxLimits{}) {
+		options.Sandbox = e.config.Sandbox
+	}
+
 	// 创建工作池
-	pool, err := NewWorkerPool(e.config.WorkerPoolSize, strategy, e.metrics, e.ctx, e.apiClient, e.statusUpdater)
+	pool, err := NewWorkerPool(e.config.WorkerPoolSize, strategy, e.metrics, e.ctx, e.apiClient, updater, options)
 	if err != nil {
 		e.updateState(func(s *engineState) {
 			s.status = StatusError
@@ -143,6 +177,15 @@ func (e *Engine) Execute(strategy *Strategy) error {
 
 	// 保存工作池引用
 	e.workerPool = pool
+	e.updateState(func(s *engineState) {
+		s.failures = pool.failures
+	})
+	if checkpoints != nil {
+		if options.Resume != nil {
+			pool.restore(options.Resume)
+		}
+		pool.checkpoints = checkpoints
+	}
 
 	// 确保在函数返回时清理工作池
 	defer func() {
@@ -155,12 +198,34 @@ func (e *Engine) Execute(strategy *Strategy) error {
 	// 启动工作池
 	pool.Start(e.ctx)
 
+	// 设置了沙箱内存限制时, 进程存活堆内存在运行期间增长超过限制则停止运行
+	memoryLimit := options.Sandbox.MemoryLimit
+	var memoryExceeded atomic.Bool
+	stopWatch := watchMemory(memoryLimit, func() {
+		memoryExceeded.Store(true)
+		e.updateState(func(s *engineState) {
+			s.shouldStop = true
+		})
+	})
+	defer stopWatch()
+
 	// 启动状态更新协程
-	go e.updateStatus()
+	done := make(chan struct{})
+	defer close(done)
+	go e.updateStatus(done)
+
+	// 定期保存断点, 运行结束时按结束状态删除或保存断点
+	if checkpoints != nil && e.config.Checkpoints != nil {
+		go e.saveCheckpoints(checkpoints, pool, done)
+		defer e.finishCheckpoint(checkpoints, pool)
+	}
 
 	// 分批处理股票
 	for i := 0; i < len(stocks); i += e.config.BatchSize {
 		// 检查是否应该停止
+		if memoryExceeded.Load() {
+			return e.failRun(memoryLimitError(memoryLimit))
+		}
 		if e.state.shouldStop {
 			e.updateState(func(s *engineState) {
 				if s.status != StatusCompleted { // 如果不是正常完成,设置为停止状态
@@ -180,8 +245,11 @@ func (e *Engine) Execute(strategy *Strategy) error {
 			end = len(stocks)
 		}
 
-		// 提交一批股票到工作池
+		// 提交一批股票到工作池, 跳过断点前已处理的股票
 		for _, stock := range stocks[i:end] {
+			if checkpoints != nil && checkpoints.isProcessed(stock.Code) {
+				continue
+			}
 			e.updateState(func(s *engineState) {
 				s.currentStock = fmt.Sprintf("%s(%s)", stock.Name, stock.Code)
 			})
@@ -191,9 +259,34 @@ func (e *Engine) Execute(strategy *Strategy) error {
 
 	// 等待所有任务完成
 	pool.Wait()
+	if memoryExceeded.Load() {
+		return e.failRun(memoryLimitError(memoryLimit))
+	}
+
+	// 超时或应用关闭导致运行被取消时, 未处理的股票不能算作完成(保留断点以便继续运行)
+	if err := e.ctx.Err(); err != nil && !e.state.shouldStop {
+		interrupted := NewEngineError(ErrEngineTimeout, "execution interrupted", err)
+		e.updateState(func(s *engineState) {
+			s.status = StatusError
+			s.error = interrupted.Error()
+		})
+		return interrupted
+	}
+
+	// 两阶段排序策略: 全部股票评分完成后运行select
+	if !e.state.shouldStop {
+		if err := pool.RunSelect(); err != nil {
+			e.updateState(func(s *engineState) {
+				s.status = StatusError
+				s.error = err.Error()
+			})
+			return err
+		}
+	}
 
 	// 确保最终状态正确
 	e.updateState(func(s *engineState) {
+		s.processedCount = e.metrics.processedCount.Load()
 		if s.status != StatusError && s.status != StatusStopped {
 			s.status = StatusCompleted
 			s.currentStock = ""
@@ -204,13 +297,24 @@ func (e *Engine) Execute(strategy *Strategy) error {
 	return nil
 }
 
-// updateStatus 更新执行状态
-func (e *Engine) updateStatus() {
+// failRun 将运行标记为出错并返回错误
+func (e *Engine) failRun(err error) error {
+	e.updateState(func(s *engineState) {
+		s.status = StatusError
+		s.error = err.Error()
+	})
+	return err
+}
+
+// updateStatus 更新执行状态, 直到done关闭
+func (e *Engine) updateStatus(done <-chan struct{}) {
 	ticker := time.NewTicker(200 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-done:
+			return
 		case <-ticker.C:
 			stats := e.metrics.GetStats()
 			e.updateState(func(s *engineState) {
@@ -237,29 +341,21 @@ func (e *Engine) getStatusString() string {
 	return "idle"
 }
 
-// filterStocks 过滤股票列表
-func (e *Engine) filterStocks(stocks []types.Index) []types.Index {
-	filtered := make([]types.Index, 0, len(stocks))
-	for _, stock := range stocks {
-		// 过滤ST股票
-		if strings.Contains(strings.ToUpper(stock.Name), "ST") {
-			continue
-		}
-
-		// 过滤退市股票
-		if strings.Contains(stock.Name, "退") || strings.Contains(stock.Name, "退市") {
-			continue
-		}
+// resolveUniverse 解析运行的股票池, 未指定时使用默认股票池; asOf为按上市天数排除新股的基准日期, 零值为当前时间
+func (e *Engine) resolveUniverse(u *universe.Universe, asOf time.Time) ([]types.Index, error) {
+	spec := universe.Default()
+	if u != nil {
+		spec = *u
+	}
 
-		// 只保留沪深主板、创业板、科创板的股票
-		if strings.HasPrefix(stock.Code, "00") || // 深证主板
-			strings.HasPrefix(stock.Code, "30") || // 创业板
-			strings.HasPrefix(stock.Code, "60") || // 上证主板
-			strings.HasPrefix(stock.Code, "68") { // 科创板
-			filtered = append(filtered, stock)
-		}
+	sources := e.config.Universe
+	sources.Client = e.apiClient
+	sources.AsOf = asOf
+	stocks, err := spec.Resolve(context.Background(), sources)
+	if err != nil {
+		return nil, NewAPIRequestError("failed to resolve stock universe", err)
 	}
-	return filtered
+	return stocks, nil
 }
 
 // Pause 暂停执行
@@ -39,6 +39,11 @@ const (
 	// 配置相关错误码
 	ErrInvalidConfig
 	ErrInvalidStrategy
+
+	// Lua沙箱相关错误码
+	ErrLuaTimeout
+	ErrLuaMemoryLimit
+	ErrLuaSandboxViolation
 )
 
 // ErrorLevel 错误级别
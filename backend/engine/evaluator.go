@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// Evaluator 非Lua策略(如声明式选股条件)的求值器
+// 同一求值器由工作池中的多个工作单元并发调用, 实现需保证并发安全
+type Evaluator interface {
+	// Evaluate 判断单只股票是否入选, 返回nil表示未入选
+	Evaluate(stock types.Index, data DataSource) (*StockSignal, error)
+}
+
+// BacktestChecker 求值器可选实现的接口, 判断能否回测(如是否使用了回测中不可用的实时行情)
+type BacktestChecker interface {
+	CheckBacktest() error
+}
+
+// checkBacktest 检查策略及其子策略的求值器能否回测
+func checkBacktest(strategy *Strategy) error {
+	if checker, ok := strategy.Evaluator.(BacktestChecker); ok {
+		if err := checker.CheckBacktest(); err != nil {
+			return fmt.Errorf("%s: %v", strategy.Name, err)
+		}
+	}
+	for _, component := range strategy.Components {
+		if err := checkBacktest(component); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DataSource 求值器可访问的行情数据, 回测模式下只提供模拟交易日(含)之前的数据
+type DataSource interface {
+	// KLineData 获取K线数据(按时间升序)
+	KLineData(code string, freq types.KLineFreq) ([]types.KLineData, error)
+	// Realtime 获取实时行情, 回测模式下不可用
+	Realtime(code string) (*types.RealtimeData, error)
+	// CapitalFlow 获取资金流向(按时间升序)
+	CapitalFlow(code string) ([]types.CapitalFlow, error)
+}
+
+// KLineData 实现DataSource, 回测模式下截断到模拟交易日
+func (w *Worker) KLineData(code string, freq types.KLineFreq) ([]types.KLineData, error) {
+	data, err := w.fetchKLineData(code, freq)
+	if err != nil {
+		return nil, NewAPIRequestError("getKLineData", err)
+	}
+	return truncateKLine(data, w.asOf), nil
+}
+
+// Realtime 实现DataSource
+func (w *Worker) Realtime(code string) (*types.RealtimeData, error) {
+	if w.asOf != "" {
+		return nil, NewInvalidStockDataError(code, fmt.Errorf("realtime data is not available in backtest"))
+	}
+	data, err := w.apiClient.Market.GetRealtimeData(context.Background(), code)
+	if err != nil {
+		return nil, NewAPIRequestError("getRealtimeData", err)
+	}
+	return data, nil
+}
+
+// CapitalFlow 实现DataSource, 回测模式下截断到模拟交易日
+func (w *Worker) CapitalFlow(code string) ([]types.CapitalFlow, error) {
+	data, err := w.apiClient.Market.GetCapitalFlow(context.Background(), code)
+	if err != nil {
+		return nil, NewAPIRequestError("getCapitalFlow", err)
+	}
+	return SortCapitalFlow(data, w.asOf), nil
+}
+
+// SortCapitalFlow 将资金流向按时间升序排列, asOf不为空时只保留该日(含)之前的数据, 不修改原切片
+func SortCapitalFlow(data []types.CapitalFlow, asOf string) []types.CapitalFlow {
+	sorted := make([]types.CapitalFlow, 0, len(data))
+	for _, flow := range data {
+		if asOf == "" || barDate(flow.Time) <= asOf {
+			sorted = append(sorted, flow)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time < sorted[j].Time
+	})
+	return sorted
+}
+
+// callEvaluator 调用求值器, 入选时发出买入信号
+func (w *Worker) callEvaluator(stock types.Index) error {
+	signal, err := w.strategy.Evaluator.Evaluate(stock, w)
+	if err != nil || signal == nil {
+		return err
+	}
+
+	if signal.Code == "" {
+		signal.Code = stock.Code
+	}
+	if signal.Name == "" {
+		signal.Name = stock.Name
+	}
+	signal.Date = w.asOf
+	signal.Type = SignalTypeEntry
+	w.emit(*signal)
+	return nil
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// 失败记录上限
+const (
+	maxRecordedFailures = 200 // 保存明细的失败股票数, 超出部分只计数
+	maxGroupSamples     = 10  // 每类错误列出的示例股票数
+)
+
+// StockFailure 单只股票处理失败的记录
+type StockFailure struct {
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	ErrorCode ErrorCode `json:"errorCode"`
+	Message   string    `json:"message"`
+	Traceback string    `json:"traceback,omitempty"` // Lua错误的调用栈
+}
+
+// FailureGroup 同一错误码的失败汇总
+type FailureGroup struct {
+	ErrorCode ErrorCode `json:"errorCode"`
+	Count     int       `json:"count"`   // 失败股票数
+	Message   string    `json:"message"` // 第一个失败的错误信息
+	Codes     []string  `json:"codes"`   // 示例股票代码, 最多maxGroupSamples个
+}
+
+// FailureReport 一次运行中处理失败的股票
+type FailureReport struct {
+	Total     int            `json:"total"`     // 失败股票总数
+	Groups    []FailureGroup `json:"groups"`    // 按错误码汇总, 按数量降序
+	Failures  []StockFailure `json:"failures"`  // 失败明细, 最多maxRecordedFailures条
+	Truncated bool           `json:"truncated"` // 明细是否因超出上限被截断
+}
+
+// failureCollector 收集工作池中各工作单元的处理失败, 不会阻塞工作单元
+type failureCollector struct {
+	mu       sync.Mutex
+	total    int
+	groups   map[ErrorCode]*FailureGroup
+	order    []ErrorCode
+	failures []StockFailure
+}
+
+// newFailureCollector 创建失败收集器
+func newFailureCollector() *failureCollector {
+	return &failureCollector{groups: make(map[ErrorCode]*FailureGroup)}
+}
+
+// add 记录处理失败的股票, 因运行被取消而未处理的股票不计入
+func (c *failureCollector) add(stock types.Index, err error) {
+	failure := StockFailure{
+		Code:      stock.Code,
+		Name:      stock.Name,
+		ErrorCode: ErrLuaScriptExecution,
+		Message:   diagnosticMessage(err),
+	}
+	var engineErr *EngineError
+	if errors.As(err, &engineErr) {
+		if engineErr.Code == ErrWorkerClosed {
+			return
+		}
+		failure.ErrorCode = engineErr.Code
+	}
+	if _, traceback, found := strings.Cut(err.Error(), "stack traceback:"); found {
+		failure.Traceback = "stack traceback:" + strings.TrimRight(traceback, "\n")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	if len(c.failures) < maxRecordedFailures {
+		c.failures = append(c.failures, failure)
+	}
+
+	group, ok := c.groups[failure.ErrorCode]
+	if !ok {
+		group = &FailureGroup{ErrorCode: failure.ErrorCode, Message: failure.Message}
+		c.groups[failure.ErrorCode] = group
+		c.order = append(c.order, failure.ErrorCode)
+	}
+	group.Count++
+	if len(group.Codes) < maxGroupSamples {
+		group.Codes = append(group.Codes, failure.Code)
+	}
+}
+
+// restore 从断点的失败汇总恢复
+func (c *failureCollector) restore(report FailureReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += report.Total
+	c.failures = append(c.failures, report.Failures...)
+	for _, group := range report.Groups {
+		if _, ok := c.groups[group.ErrorCode]; !ok {
+			c.order = append(c.order, group.ErrorCode)
+		}
+		restored := group
+		restored.Codes = append([]string(nil), group.Codes...)
+		c.groups[group.ErrorCode] = &restored
+	}
+}
+
+// count 失败股票总数
+func (c *failureCollector) count() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// report 生成失败汇总
+func (c *failureCollector) report() FailureReport {
+	report := FailureReport{
+		Groups:   []FailureGroup{},
+		Failures: []StockFailure{},
+	}
+	if c == nil {
+		return report
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report.Total = c.total
+	report.Truncated = c.total > len(c.failures)
+	report.Failures = append(report.Failures, c.failures...)
+	for _, code := range c.order {
+		group := *c.groups[code]
+		group.Codes = append([]string(nil), group.Codes...)
+		report.Groups = append(report.Groups, group)
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Count > report.Groups[j].Count
+	})
+	return report
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/indicators"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// IndicatorBinding 指标函数到Lua的绑定声明
+// 参数按函数签名从Lua值转换, 返回值中的结构体转换为以小驼峰字段名为键的表;
+// 函数最后一个返回值为error时, Lua中额外返回错误信息(成功时为nil)
+type IndicatorBinding struct {
+	Name        string      `json:"name"`        // Lua函数名, 通过api.indicator.<Name>调用
+	Func        interface{} `json:"-"`           // indicators包中的函数
+	Args        []string    `json:"args"`        // 参数名, 与函数参数一一对应
+	Returns     []string    `json:"returns"`     // 返回值说明
+	Description string      `json:"description"` // 函数说明
+}
+
+// indicatorBindings 暴露给Lua的全部指标函数, 新增指标只需在此登记
+var indicatorBindings = []IndicatorBinding{
+	{
+		Name:        "calculateMA",
+		Func:        indicators.CalculateMA,
+		Args:        []string{"prices", "maType", "period"},
+		Returns:     []string{"ma", "err"},
+		Description: "移动平均线, maType: sma, ema, wma, tma",
+	},
+	{
+		Name:        "calculateMACD",
+		Func:        indicators.CalculateMACD,
+		Args:        []string{"prices", "shortPeriod", "longPeriod", "signalPeriod"},
+		Returns:     []string{"{dif, dea, macd}", "err"},
+		Description: "MACD指标",
+	},
+	{
+		Name:        "calculateRSI",
+		Func:        indicators.CalculateRSI,
+		Args:        []string{"prices", "period"},
+		Returns:     []string{"rsi", "err"},
+		Description: "相对强弱指标",
+	},
+	{
+		Name:        "calculateKDJ",
+		Func:        indicators.CalculateKDJ,
+		Args:        []string{"prices", "n", "m1", "m2"},
+		Returns:     []string{"{k, d, j}", "err"},
+		Description: "KDJ指标(按收盘价计算RSV)",
+	},
+	{
+		Name:        "calculateTrend",
+		Func:        indicators.TrendStrength,
+		Args:        []string{"values", "period"},
+		Returns:     []string{"trend", "err"},
+		Description: "趋势强度, 取值范围[-1, 1]",
+	},
+	{
+		Name:        "calculateKDJOHLCV",
+		Func:        indicators.CalculateKDJOHLCV,
+		Args:        []string{"kdata", "n", "m1", "m2"},
+		Returns:     []string{"{k, d, j}", "err"},
+		Description: "KDJ指标(按最高价和最低价计算RSV)",
+	},
+	{
+		Name:        "calculateATR",
+		Func:        indicators.CalculateATR,
+		Args:        []string{"kdata", "period"},
+		Returns:     []string{"atr", "err"},
+		Description: "平均真实波幅",
+	},
+	{
+		Name:        "calculateBollinger",
+		Func:        indicators.CalculateBollinger,
+		Args:        []string{"prices", "period", "multiplier"},
+		Returns:     []string{"{upper, middle, lower, width}", "err"},
+		Description: "布林带",
+	},
+	{
+		Name:        "calculateOBV",
+		Func:        indicators.CalculateOBV,
+		Args:        []string{"kdata"},
+		Returns:     []string{"obv", "err"},
+		Description: "能量潮",
+	},
+	{
+		Name:        "calculateVWAP",
+		Func:        indicators.CalculateVWAP,
+		Args:        []string{"kdata", "period"},
+		Returns:     []string{"vwap", "err"},
+		Description: "成交量加权平均价, period<=0时从序列起点累计",
+	},
+	{
+		Name:        "calculateCCI",
+		Func:        indicators.CalculateCCI,
+		Args:        []string{"kdata", "period"},
+		Returns:     []string{"cci", "err"},
+		Description: "顺势指标",
+	},
+	{
+		Name:        "calculateWilliamsR",
+		Func:        indicators.CalculateWilliamsR,
+		Args:        []string{"kdata", "period"},
+		Returns:     []string{"wr", "err"},
+		Description: "威廉指标, 取值范围[-100, 0]",
+	},
+	{
+		Name:        "calculateDMI",
+		Func:        indicators.CalculateDMI,
+		Args:        []string{"kdata", "period", "adxPeriod"},
+		Returns:     []string{"{pdi, mdi, adx, adxr}", "err"},
+		Description: "趋向指标(DMI/ADX)",
+	},
+	{
+		Name:        "patterns",
+		Func:        indicators.DetectPatterns,
+		Args:        []string{"kdata", "options"},
+		Returns:     []string{"[{time, doji, hammer, ..., limitUp, patterns}]", "err"},
+		Description: "K线形态识别, 各形态字段为强度(0表示未出现); options: {dojiThreshold, limitUpRate, trendPeriod}, 可省略",
+	},
+	{
+		Name:        "limitUpRate",
+		Func:        indicators.LimitUpRate,
+		Args:        []string{"code"},
+		Returns:     []string{"rate"},
+		Description: "根据股票代码获取涨停幅度",
+	},
+	{
+		Name:        "isValidFloat",
+		Func:        indicators.IsValidFloat,
+		Args:        []string{"value"},
+		Returns:     []string{"valid"},
+		Description: "检查数值是否有效",
+	},
+	{
+		Name:        "clamp",
+		Func:        indicators.Clamp,
+		Args:        []string{"value", "min", "max"},
+		Returns:     []string{"value"},
+		Description: "将数值限制在指定范围内",
+	},
+	{
+		Name:        "sqrt",
+		Func:        indicators.Sqrt,
+		Args:        []string{"value"},
+		Returns:     []string{"value"},
+		Description: "平方根, 负数返回0",
+	},
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// luaArgConverters 需要特殊转换的参数类型
+var luaArgConverters = map[reflect.Type]func(lua.LValue) (reflect.Value, error){}
+
+func init() {
+	luaArgConverters[reflect.TypeOf((*indicators.OHLCV)(nil))] = luaToOHLCV
+}
+
+// luaToOHLCV 由K线数组(api.getKLineData的返回值)构建OHLCV序列
+func luaToOHLCV(value lua.LValue) (reflect.Value, error) {
+	data, err := luaToReflect(value, reflect.TypeOf([]types.KLineData(nil)))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(indicators.NewOHLCV(data.Interface().([]types.KLineData))), nil
+}
+
+// indicatorRefRegex 匹配策略源码中对指标函数的引用
+var indicatorRefRegex = regexp.MustCompile(`api\.indicator\.([A-Za-z_][A-Za-z0-9_]*)`)
+
+// IndicatorBindings 获取已登记的指标函数列表(按名称排序)
+func IndicatorBindings() []IndicatorBinding {
+	bindings := make([]IndicatorBinding, len(indicatorBindings))
+	copy(bindings, indicatorBindings)
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].Name < bindings[j].Name
+	})
+	return bindings
+}
+
+// findIndicatorBinding 按名称查找指标函数
+func findIndicatorBinding(name string) (IndicatorBinding, bool) {
+	for _, binding := range indicatorBindings {
+		if binding.Name == name {
+			return binding, true
+		}
+	}
+	return IndicatorBinding{}, false
+}
+
+// newIndicatorTable 根据登记表创建api.indicator表, 访问未登记的函数时抛出错误
+func newIndicatorTable(L *lua.LState) (*lua.LTable, error) {
+	table := L.NewTable()
+	for _, binding := range indicatorBindings {
+		fn, err := bindIndicator(binding)
+		if err != nil {
+			return nil, err
+		}
+		L.SetField(table, binding.Name, L.NewFunction(fn))
+	}
+
+	meta := L.NewTable()
+	L.SetField(meta, "__index", L.NewFunction(func(L *lua.LState) int {
+		L.RaiseError("unknown indicator binding: api.indicator.%s", L.CheckString(2))
+		return 0
+	}))
+	L.SetMetatable(table, meta)
+
+	return table, nil
+}
+
+// bindIndicator 根据函数签名生成Lua包装函数
+func bindIndicator(binding IndicatorBinding) (lua.LGFunction, error) {
+	fn := reflect.ValueOf(binding.Func)
+	if fn.Kind() != reflect.Func {
+		return nil, fmt.Errorf("indicator %s is not a function", binding.Name)
+	}
+	fnType := fn.Type()
+	if fnType.IsVariadic() {
+		return nil, fmt.Errorf("indicator %s: variadic functions are not supported", binding.Name)
+	}
+	if len(binding.Args) != fnType.NumIn() {
+		return nil, fmt.Errorf("indicator %s declares %d args but function takes %d", binding.Name, len(binding.Args), fnType.NumIn())
+	}
+	returnsError := fnType.NumOut() > 0 && fnType.Out(fnType.NumOut()-1) == errorType
+
+	return func(L *lua.LState) int {
+		args := make([]reflect.Value, fnType.NumIn())
+		for i := range args {
+			arg, err := luaToReflect(L.Get(i+1), fnType.In(i))
+			if err != nil {
+				L.ArgError(i+1, fmt.Sprintf("%s: %v", binding.Args[i], err))
+				return 0
+			}
+			args[i] = arg
+		}
+
+		results := fn.Call(args)
+		values := results
+		if returnsError {
+			values = results[:len(results)-1]
+			if err, _ := results[len(results)-1].Interface().(error); err != nil {
+				for range values {
+					L.Push(lua.LNil)
+				}
+				L.Push(lua.LString(err.Error()))
+				return len(results)
+			}
+		}
+
+		for _, value := range values {
+			L.Push(reflectToLua(L, value))
+		}
+		if returnsError {
+			L.Push(lua.LNil)
+		}
+		return len(results)
+	}, nil
+}
+
+// checkIndicatorRefs 检查策略源码引用的指标函数是否都已登记, 未登记时加载失败
+func checkIndicatorRefs(filePath string) error {
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(source), "\n") {
+		// 忽略注释部分
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, match := range indicatorRefRegex.FindAllStringSubmatch(line, -1) {
+			name := match[1]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if _, ok := findIndicatorBinding(name); !ok {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+
+	if len(unknown) > 0 {
+		return NewEngineError(ErrInvalidStrategy, "strategy references unknown indicator bindings",
+			fmt.Errorf("api.indicator.%s", strings.Join(unknown, ", api.indicator.")))
+	}
+	return nil
+}
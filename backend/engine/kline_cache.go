@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+)
+
+// KLineCache 多次回测共享的K线内存缓存, 按代码和周期索引
+// 设置了回看窗口时只保留回测区间前lookback根到区间后最长统计周期的K线, 以控制内存占用
+type KLineCache struct {
+	mutex     sync.RWMutex
+	data      map[string][]types.KLineData
+	startDate string
+	endDate   string
+	lookback  int
+}
+
+// NewKLineCache 创建K线缓存, lookback<=0表示保留完整历史
+func NewKLineCache(startDate, endDate string, lookback int) *KLineCache {
+	return &KLineCache{
+		data:      make(map[string][]types.KLineData),
+		startDate: startDate,
+		endDate:   endDate,
+		lookback:  lookback,
+	}
+}
+
+// Get 获取K线数据, 未缓存时从API加载
+func (c *KLineCache) Get(ctx context.Context, client *api.Client, code string, freq types.KLineFreq) ([]types.KLineData, error) {
+	key := code + "|" + string(freq)
+
+	c.mutex.RLock()
+	data, ok := c.data[key]
+	c.mutex.RUnlock()
+	if ok {
+		return data, nil
+	}
+
+	data, err := client.Market.GetKLineData(ctx, code, freq)
+	if err != nil {
+		return nil, err
+	}
+	data = c.trim(data)
+
+	c.mutex.Lock()
+	c.data[key] = data
+	c.mutex.Unlock()
+
+	return data, nil
+}
+
+// Len 已缓存的序列数
+func (c *KLineCache) Len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.data)
+}
+
+// trim 截取回测窗口所需的K线, 复制后释放原始数据
+func (c *KLineCache) trim(data []types.KLineData) []types.KLineData {
+	if c.lookback <= 0 {
+		return data
+	}
+
+	start := sort.Search(len(data), func(i int) bool {
+		return barDate(data[i].Time) >= c.startDate
+	})
+	end := sort.Search(len(data), func(i int) bool {
+		return barDate(data[i].Time) > c.endDate
+	})
+
+	from := start - c.lookback
+	if from < 0 {
+		from = 0
+	}
+	to := end + BacktestHorizons[len(BacktestHorizons)-1]
+	if to > len(data) {
+		to = len(data)
+	}
+
+	return append([]types.KLineData(nil), data[from:to]...)
+}
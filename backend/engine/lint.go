@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// 诊断级别
+const (
+	DiagnosticError   = "error"   // 策略无法运行
+	DiagnosticWarning = "warning" // 策略可以运行, 但可能存在问题
+)
+
+// Diagnostic 策略检查发现的问题
+type Diagnostic struct {
+	Line     int    `json:"line"`             // 行号, 从1开始, 0表示与具体行无关
+	Column   int    `json:"column,omitempty"` // 列号, 从1开始
+	Severity string `json:"severity"`         // 诊断级别: error, warning
+	Message  string `json:"message"`
+}
+
+// apiRefRegex 匹配策略源码中对api表成员的引用, 如api.emit、api.indicator.calculateMA
+var apiRefRegex = regexp.MustCompile(`\bapi\.([A-Za-z_][A-Za-z0-9_]*)(?:\.([A-Za-z_][A-Za-z0-9_]*))?`)
+
+// LintStrategy 在沙箱中编译并加载Lua策略文件, 检查语法、api引用和必需的钩子函数, 不会调用process_stock等钩子
+func LintStrategy(strategy *Strategy) []Diagnostic {
+	source, err := os.ReadFile(strategy.FilePath)
+	if err != nil {
+		return []Diagnostic{{Severity: DiagnosticError, Message: fmt.Sprintf("failed to read strategy file: %v", err)}}
+	}
+
+	// 语法检查
+	chunk, err := parse.Parse(bytes.NewReader(source), strategy.FilePath)
+	if err != nil {
+		return []Diagnostic{syntaxDiagnostic(err)}
+	}
+	if _, err := lua.Compile(chunk, strategy.FilePath); err != nil {
+		return []Diagnostic{{Severity: DiagnosticError, Message: fmt.Sprintf("failed to compile strategy: %v", err)}}
+	}
+
+	// 创建不连接API的沙箱工作单元
+	worker := &Worker{
+		metrics:       NewExecutionMetrics(0),
+		strategy:      strategy,
+		ctx:           context.Background(),
+		statusUpdater: discardUpdater{},
+		positions:     make(map[string]Position),
+		limits:        SandboxLimits{}.withDefaults(),
+	}
+	L := worker.newSandboxState()
+	defer L.Close()
+	worker.luaState = L
+	if err := worker.registerLuaFunctions(); err != nil {
+		return []Diagnostic{{Severity: DiagnosticError, Message: fmt.Sprintf("failed to register Lua functions: %v", err)}}
+	}
+
+	var diagnostics []Diagnostic
+	params, err := ResolveParams(strategy.Params, nil)
+	if err != nil {
+		diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticError, Message: err.Error()})
+	}
+	L.SetGlobal("params", goToLua(L, params))
+
+	// 检查api引用
+	diagnostics = append(diagnostics, lintAPIRefs(L.GetGlobal("api"), string(source))...)
+
+	// 加载策略文件(执行顶层代码)
+	builtinSelect := L.GetGlobal(hookSelect)
+	var loadErr error
+	if err := worker.guard(func() error {
+		loadErr = L.DoFile(strategy.FilePath)
+		return loadErr
+	}); err != nil {
+		diagnostics = append(diagnostics, loadDiagnostic(strategy.FilePath, err, loadErr))
+		return sortDiagnostics(diagnostics)
+	}
+
+	// 检查钩子函数, 组合策略由子策略处理股票
+	if !strategy.isComposite() {
+		if err := worker.checkRankingHooks(builtinSelect); err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticError, Message: diagnosticMessage(err)})
+		} else if !worker.isRanking() && L.GetGlobal("process_stock").Type() != lua.LTFunction {
+			// 只定义on_tick的策略只能用于盘中监控
+			if worker.hasOnTick() {
+				diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticWarning, Message: "process_stock(stock) is not defined, the strategy can only be used for monitoring"})
+			} else {
+				diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticError, Message: "process_stock(stock) is not defined"})
+			}
+		}
+	}
+	if fn := L.GetGlobal("check_exit"); fn != lua.LNil && fn.Type() != lua.LTFunction {
+		diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticWarning, Message: "check_exit is not a function and will be ignored"})
+	}
+	if fn := L.GetGlobal(hookOnTick); fn != lua.LNil && fn.Type() != lua.LTFunction {
+		diagnostics = append(diagnostics, Diagnostic{Severity: DiagnosticWarning, Message: "on_tick is not a function and will be ignored"})
+	}
+
+	return sortDiagnostics(diagnostics)
+}
+
+// lintAPIRefs 逐行检查源码中引用的api成员是否存在, 注释部分不检查
+func lintAPIRefs(apiValue lua.LValue, source string) []Diagnostic {
+	apiTable, ok := apiValue.(*lua.LTable)
+	if !ok {
+		return nil
+	}
+
+	var diagnostics []Diagnostic
+	for i, line := range strings.Split(source, "\n") {
+		if idx := strings.Index(line, "--"); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, match := range apiRefRegex.FindAllStringSubmatchIndex(line, -1) {
+			name := line[match[2]:match[3]]
+			member := apiTable.RawGetString(name)
+			ref := "api." + name
+			if member == lua.LNil {
+				diagnostics = append(diagnostics, Diagnostic{
+					Line:     i + 1,
+					Column:   match[0] + 1,
+					Severity: DiagnosticError,
+					Message:  fmt.Sprintf("%s is not defined", ref),
+				})
+				continue
+			}
+
+			// 子表(indicator, market等)还需检查成员名
+			table, ok := member.(*lua.LTable)
+			if !ok || match[4] < 0 {
+				continue
+			}
+			sub := line[match[4]:match[5]]
+			if table.RawGetString(sub) == lua.LNil {
+				diagnostics = append(diagnostics, Diagnostic{
+					Line:     i + 1,
+					Column:   match[0] + 1,
+					Severity: DiagnosticError,
+					Message:  fmt.Sprintf("%s.%s is not defined", ref, sub),
+				})
+			}
+		}
+	}
+	return diagnostics
+}
+
+// syntaxDiagnostic 将语法错误转换为诊断
+func syntaxDiagnostic(err error) Diagnostic {
+	var parseErr *parse.Error
+	if !errors.As(err, &parseErr) {
+		return Diagnostic{Severity: DiagnosticError, Message: fmt.Sprintf("syntax error: %v", err)}
+	}
+
+	diagnostic := Diagnostic{Severity: DiagnosticError, Message: parseErr.Message}
+	if parseErr.Pos.Line != parse.EOF {
+		diagnostic.Line = parseErr.Pos.Line
+		diagnostic.Column = parseErr.Pos.Column
+		diagnostic.Message += fmt.Sprintf(" near '%s'", parseErr.Token)
+	} else {
+		diagnostic.Message += " at end of file"
+	}
+	return diagnostic
+}
+
+// loadDiagnostic 将加载策略文件时的错误转换为诊断, 行号取自Lua错误消息的"文件名:行号:"前缀
+func loadDiagnostic(filePath string, err, raw error) Diagnostic {
+	diagnostic := Diagnostic{Severity: DiagnosticError, Message: diagnosticMessage(err)}
+
+	var apiErr *lua.ApiError
+	if !errors.As(raw, &apiErr) || apiErr.Object == nil {
+		return diagnostic
+	}
+	msg := apiErr.Object.String()
+	prefix := filePath + ":"
+	if !strings.HasPrefix(msg, prefix) {
+		return diagnostic
+	}
+	lineText, rest, ok := strings.Cut(msg[len(prefix):], ":")
+	if line, convErr := strconv.Atoi(lineText); ok && convErr == nil {
+		diagnostic.Line = line
+		if !IsEngineError(err) {
+			diagnostic.Message = strings.TrimSpace(firstLine(rest))
+		}
+	}
+	return diagnostic
+}
+
+// diagnosticMessage 获取错误的诊断消息, 引擎错误不含错误码, Lua错误不含堆栈
+func diagnosticMessage(err error) string {
+	var engineErr *EngineError
+	if errors.As(err, &engineErr) && engineErr.Err != nil {
+		return engineErr.Message + ": " + firstLine(engineErr.Err.Error())
+	}
+	return firstLine(err.Error())
+}
+
+// firstLine 获取多行文本(如带堆栈的Lua错误)的第一行
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// sortDiagnostics 按行号、列号排序诊断
+func sortDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	return diagnostics
+}
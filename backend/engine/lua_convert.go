@@ -0,0 +1,307 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaToGo 将Lua值转换为Go值: 数组形式的表转换为切片, 其余表转换为map
+func luaToGo(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		if n := v.MaxN(); n > 0 {
+			items := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				items = append(items, luaToGo(v.RawGetInt(i)))
+			}
+			return items
+		}
+		fields := make(map[string]interface{})
+		v.ForEach(func(key, val lua.LValue) {
+			fields[key.String()] = luaToGo(val)
+		})
+		return fields
+	default:
+		return nil
+	}
+}
+
+// goToLua 将Go值转换为Lua值: 切片转换为数组表, map转换为键值表, 结构体转换为以小驼峰字段名为键的表
+func goToLua(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case int:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		table := L.CreateTable(len(v), 0)
+		for i, item := range v {
+			table.RawSetInt(i+1, goToLua(L, item))
+		}
+		return table
+	case map[string]interface{}:
+		table := L.CreateTable(0, len(v))
+		for key, item := range v {
+			table.RawSetString(key, goToLua(L, item))
+		}
+		return table
+	default:
+		return reflectToLua(L, reflect.ValueOf(value))
+	}
+}
+
+// reflectToLua 通过反射转换任意Go值
+func reflectToLua(L *lua.LState, value reflect.Value) lua.LValue {
+	if !value.IsValid() {
+		return lua.LNil
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return lua.LNil
+		}
+		return reflectToLua(L, value.Elem())
+	case reflect.Bool:
+		return lua.LBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return lua.LNumber(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return lua.LNumber(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return lua.LNumber(value.Float())
+	case reflect.String:
+		return lua.LString(value.String())
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice && value.IsNil() {
+			return lua.LNil
+		}
+		// 按下标赋值, nil元素不会使后续元素前移
+		table := L.CreateTable(value.Len(), 0)
+		for i := 0; i < value.Len(); i++ {
+			table.RawSetInt(i+1, reflectToLua(L, value.Index(i)))
+		}
+		return table
+	case reflect.Map:
+		if value.IsNil() {
+			return lua.LNil
+		}
+		table := L.CreateTable(0, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			table.RawSetString(fmt.Sprint(iter.Key().Interface()), reflectToLua(L, iter.Value()))
+		}
+		return table
+	case reflect.Struct:
+		if t, ok := value.Interface().(time.Time); ok {
+			return lua.LString(t.Format("2006-01-02 15:04:05"))
+		}
+		table := L.CreateTable(0, value.NumField())
+		setStructFields(L, table, value)
+		return table
+	default:
+		return lua.LNil
+	}
+}
+
+// setStructFields 将结构体的导出字段写入表, 匿名嵌入的结构体字段展开到同一层
+func setStructFields(L *lua.LState, table *lua.LTable, value reflect.Value) {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			setStructFields(L, table, value.Field(i))
+			continue
+		}
+		table.RawSetString(luaFieldName(field), reflectToLua(L, value.Field(i)))
+	}
+}
+
+// luaFieldName 结构体字段在Lua中的名称: lua标签优先, 否则为小驼峰(DIF -> dif, PEValue -> peValue, TotalVolume -> totalVolume)
+func luaFieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("lua"); tag != "" {
+		return tag
+	}
+
+	runes := []rune(field.Name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	switch {
+	case upper == len(runes):
+		return strings.ToLower(field.Name)
+	case upper > 1:
+		// 保留首字母缩写后紧跟的单词首字母大写
+		upper--
+	}
+	for i := 0; i < upper || i == 0; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// luaToReflect 将Lua值转换为指定类型的Go值
+func luaToReflect(value lua.LValue, target reflect.Type) (reflect.Value, error) {
+	if convert, ok := luaArgConverters[target]; ok {
+		return convert(value)
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		if value == lua.LNil {
+			return reflect.Zero(target), nil
+		}
+		elem, err := luaToReflect(value, target.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ptr := reflect.New(target.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+
+	case reflect.Interface:
+		if converted := luaToGo(value); converted != nil {
+			return reflect.ValueOf(converted), nil
+		}
+		return reflect.Zero(target), nil
+
+	case reflect.Bool:
+		return reflect.ValueOf(lua.LVAsBool(value)).Convert(target), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number, ok := value.(lua.LNumber)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("number expected, got %s", value.Type().String())
+		}
+		return reflect.ValueOf(float64(number)).Convert(target), nil
+
+	case reflect.String:
+		switch v := value.(type) {
+		case lua.LString, lua.LNumber:
+			return reflect.ValueOf(v.String()).Convert(target), nil
+		}
+		return reflect.Value{}, fmt.Errorf("string expected, got %s", value.Type().String())
+
+	case reflect.Slice:
+		table, ok := value.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("table expected, got %s", value.Type().String())
+		}
+		// 宽松转换: 遍历表中所有元素, 跳过无法转换的元素(如稀疏表或混合类型表中的非数值)
+		slice := reflect.MakeSlice(target, 0, table.Len())
+		table.ForEach(func(_, item lua.LValue) {
+			if converted, err := luaToReflect(item, target.Elem()); err == nil {
+				slice = reflect.Append(slice, converted)
+			}
+		})
+		return slice, nil
+
+	case reflect.Map:
+		table, ok := value.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("table expected, got %s", value.Type().String())
+		}
+		result := reflect.MakeMap(target)
+		var convErr error
+		table.ForEach(func(key, val lua.LValue) {
+			if convErr != nil {
+				return
+			}
+			k, err := luaToReflect(key, target.Key())
+			if err != nil {
+				convErr = fmt.Errorf("key %s: %v", key.String(), err)
+				return
+			}
+			v, err := luaToReflect(val, target.Elem())
+			if err != nil {
+				convErr = fmt.Errorf("field %s: %v", key.String(), err)
+				return
+			}
+			result.SetMapIndex(k, v)
+		})
+		return result, convErr
+
+	case reflect.Struct:
+		table, ok := value.(*lua.LTable)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("table expected, got %s", value.Type().String())
+		}
+		result := reflect.New(target).Elem()
+		if err := getStructFields(table, result); err != nil {
+			return reflect.Value{}, err
+		}
+		return result, nil
+
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported type %s", target.String())
+	}
+}
+
+// getStructFields 从表中按Lua字段名读取结构体的导出字段, 缺失的字段保持零值
+func getStructFields(table *lua.LTable, value reflect.Value) error {
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := getStructFields(table, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := luaFieldName(field)
+		item := table.RawGetString(name)
+		if item == lua.LNil {
+			continue
+		}
+		converted, err := luaToReflect(item, field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", name, err)
+		}
+		value.Field(i).Set(converted)
+	}
+	return nil
+}
+
+// luaStringSlice 将Lua数组表转换为字符串切片
+func luaStringSlice(value lua.LValue) []string {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, table.Len())
+	table.ForEach(func(_ lua.LValue, item lua.LValue) {
+		if item != lua.LNil {
+			result = append(result, item.String())
+		}
+	})
+	return result
+}
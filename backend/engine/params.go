@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 策略参数类型
+const (
+	ParamTypeInt    = "int"
+	ParamTypeFloat  = "float"
+	ParamTypeBool   = "bool"
+	ParamTypeString = "string"
+)
+
+// StrategyParam 策略声明的可调参数, 来自 "-- @param NAME type default min max description" 头注释
+type StrategyParam struct {
+	Name        string      `json:"name"`          // 参数名
+	Type        string      `json:"type"`          // 参数类型: int, float, bool, string
+	Default     interface{} `json:"default"`       // 默认值
+	Min         *float64    `json:"min,omitempty"` // 最小值(仅数值类型, 为空不限)
+	Max         *float64    `json:"max,omitempty"` // 最大值(仅数值类型, 为空不限)
+	Description string      `json:"description"`   // 参数说明
+}
+
+// IsNumeric 是否为数值类型参数
+func (p StrategyParam) IsNumeric() bool {
+	return p.Type == ParamTypeInt || p.Type == ParamTypeFloat
+}
+
+// ParseStrategyParam 解析参数声明, min和max为"-"表示不限
+func ParseStrategyParam(name, paramType, defaultValue, min, max, description string) (StrategyParam, error) {
+	param := StrategyParam{
+		Name:        name,
+		Type:        strings.ToLower(paramType),
+		Description: strings.TrimSpace(description),
+	}
+
+	switch param.Type {
+	case ParamTypeInt, ParamTypeFloat, ParamTypeBool, ParamTypeString:
+	default:
+		return param, fmt.Errorf("parameter %s has unsupported type: %s", name, paramType)
+	}
+
+	var err error
+	if param.Min, err = parseBound(min); err != nil {
+		return param, fmt.Errorf("parameter %s has invalid min: %v", name, err)
+	}
+	if param.Max, err = parseBound(max); err != nil {
+		return param, fmt.Errorf("parameter %s has invalid max: %v", name, err)
+	}
+	if param.Default, err = param.Coerce(defaultValue); err != nil {
+		return param, fmt.Errorf("parameter %s has invalid default: %v", name, err)
+	}
+
+	return param, nil
+}
+
+// parseBound 解析参数取值边界
+func parseBound(value string) (*float64, error) {
+	if value == "" || value == "-" {
+		return nil, nil
+	}
+	bound, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &bound, nil
+}
+
+// Coerce 将输入值转换为参数类型并检查取值范围, 数值统一为float64
+func (p StrategyParam) Coerce(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case ParamTypeInt, ParamTypeFloat:
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case int:
+			number = float64(v)
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%v is not a number", value)
+			}
+			number = parsed
+		default:
+			return nil, fmt.Errorf("%v is not a number", value)
+		}
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("%v is not a finite number", value)
+		}
+		if p.Type == ParamTypeInt && number != math.Trunc(number) {
+			return nil, fmt.Errorf("%v is not an integer", value)
+		}
+		if p.Min != nil && number < *p.Min {
+			return nil, fmt.Errorf("%v is less than min %v", value, *p.Min)
+		}
+		if p.Max != nil && number > *p.Max {
+			return nil, fmt.Errorf("%v is greater than max %v", value, *p.Max)
+		}
+		return number, nil
+
+	case ParamTypeBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("%v is not a bool", value)
+			}
+			return parsed, nil
+		default:
+			return nil, fmt.Errorf("%v is not a bool", value)
+		}
+
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// ResolveParams 以声明的默认值为基础应用覆盖值, 返回本次运行实际使用的全部参数
+func ResolveParams(declared []StrategyParam, overrides map[string]interface{}) (map[string]interface{}, error) {
+	resolved := make(map[string]interface{}, len(declared))
+	index := make(map[string]StrategyParam, len(declared))
+	for _, param := range declared {
+		resolved[param.Name] = param.Default
+		index[param.Name] = param
+	}
+
+	for name, value := range overrides {
+		param, ok := index[name]
+		if !ok {
+			return nil, NewInvalidConfigError("params."+name, fmt.Errorf("unknown parameter"))
+		}
+		coerced, err := param.Coerce(value)
+		if err != nil {
+			return nil, NewInvalidConfigError("params."+name, err)
+		}
+		resolved[name] = coerced
+	}
+
+	return resolved, nil
+}
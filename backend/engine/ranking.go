@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"stock-helper-svelte/backend/api/types"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// 两阶段排序策略的钩子函数名
+const (
+	hookScoreStock = "score_stock" // 第一阶段: score_stock(stock)返回评分(及可选的附加数据), 返回nil表示不参与排序
+	hookSelect     = "select"      // 第二阶段: select(ranked)在单个Lua状态中处理按评分降序排列的全部股票(会覆盖Lua内置的select函数)
+)
+
+// RankedStock 参与排序的股票
+type RankedStock struct {
+	Code     string      `json:"code"`
+	Name     string      `json:"name"`
+	Exchange string      `json:"exchange"`
+	Score    float64     `json:"score"`
+	Rank     int         `json:"rank"`           // 名次, 从1开始
+	Data     interface{} `json:"data,omitempty"` // score_stock返回的附加数据
+}
+
+// scoreBoard 收集工作池中各工作单元计算的评分
+type scoreBoard struct {
+	mu     sync.Mutex
+	stocks []RankedStock
+}
+
+// add 记录评分
+func (b *scoreBoard) add(stock RankedStock) {
+	b.mu.Lock()
+	b.stocks = append(b.stocks, stock)
+	b.mu.Unlock()
+}
+
+// ranked 按评分降序排列(评分相同时按代码升序)并填写名次
+func (b *scoreBoard) ranked() []RankedStock {
+	b.mu.Lock()
+	ranked := make([]RankedStock, len(b.stocks))
+	copy(ranked, b.stocks)
+	b.mu.Unlock()
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Code < ranked[j].Code
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
+
+// isRanking 策略是否定义了两阶段排序钩子
+func (w *Worker) isRanking() bool {
+	return w.luaState.GetGlobal(hookScoreStock).Type() == lua.LTFunction
+}
+
+// checkRankingHooks 检查排序钩子是否成对定义, builtinSelect为加载策略前的Lua内置select函数
+func (w *Worker) checkRankingHooks(builtinSelect lua.LValue) error {
+	if !w.isRanking() {
+		return nil
+	}
+	if fn := w.luaState.GetGlobal(hookSelect); fn.Type() != lua.LTFunction || fn == builtinSelect {
+		return NewEngineError(ErrInvalidStrategy, "ranking strategy must define select(ranked)",
+			fmt.Errorf("%s is defined but %s is missing", hookScoreStock, hookSelect))
+	}
+	if w.options.Backtest != nil {
+		return NewEngineError(ErrInvalidStrategy, "ranking strategies do not support backtest",
+			fmt.Errorf("%s/%s hooks require a full cross-section", hookScoreStock, hookSelect))
+	}
+	return nil
+}
+
+// callScoreStock 调用策略的score_stock函数并记录评分
+func (w *Worker) callScoreStock(stock types.Index) error {
+	L := w.luaState
+	stockTable := L.NewTable()
+	L.SetField(stockTable, "code", lua.LString(stock.Code))
+	L.SetField(stockTable, "name", lua.LString(stock.Name))
+	L.SetField(stockTable, "exchange", lua.LString(stock.Exchange))
+
+	if err := w.callLua(L.GetGlobal(hookScoreStock), 2, stockTable); err != nil {
+		return err
+	}
+	score, data := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+
+	if score == lua.LNil {
+		return nil
+	}
+	number, ok := score.(lua.LNumber)
+	if !ok {
+		return fmt.Errorf("%s must return a number or nil, got %s", hookScoreStock, score.Type().String())
+	}
+
+	w.scores.add(RankedStock{
+		Code:     stock.Code,
+		Name:     stock.Name,
+		Exchange: stock.Exchange,
+		Score:    float64(number),
+		Data:     luaToGo(data),
+	})
+	return nil
+}
+
+// callSelect 调用策略的select函数处理排序后的全部股票
+func (w *Worker) callSelect(ranked []RankedStock) error {
+	L := w.luaState
+	w.current = types.Index{}
+	w.emitted = w.emitted[:0]
+
+	rankedTable := L.CreateTable(len(ranked), 0)
+	for _, stock := range ranked {
+		item := L.NewTable()
+		L.SetField(item, "code", lua.LString(stock.Code))
+		L.SetField(item, "name", lua.LString(stock.Name))
+		L.SetField(item, "exchange", lua.LString(stock.Exchange))
+		L.SetField(item, "score", lua.LNumber(stock.Score))
+		L.SetField(item, "rank", lua.LNumber(stock.Rank))
+		L.SetField(item, "data", goToLua(L, stock.Data))
+		rankedTable.Append(item)
+	}
+
+	return w.callLua(L.GetGlobal(hookSelect), 0, rankedTable)
+}
+
+// isRanking 工作池运行的是否为两阶段排序策略
+func (p *WorkerPool) isRanking() bool {
+	return len(p.workers) > 0 && p.workers[0].isRanking()
+}
+
+// RunSelect 在全部股票评分完成后运行select钩子, 需在Wait之后调用
+func (p *WorkerPool) RunSelect() error {
+	if !p.isRanking() {
+		return nil
+	}
+	if err := p.workers[0].callSelect(p.scores.ranked()); err != nil {
+		return scriptError(err, fmt.Sprintf("failed to run %s", hookSelect))
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// SandboxLimits Lua沙箱的资源限制, 零值字段使用默认值
+type SandboxLimits struct {
+	CallTimeout   time.Duration // 单次调用策略代码(加载脚本、process_stock、check_exit、score_stock、select)的时间预算
+	MemoryLimit   uint64        // 一次运行期间允许的整个进程存活堆内存增长(字节), 0表示不限制(默认), 见watchMemory
+	CallStackSize int           // Lua调用栈深度
+	RegistrySize  int           // Lua数据栈大小, 不允许增长
+}
+
+// 沙箱默认限制
+const (
+	defaultCallTimeout   = 30 * time.Second
+	defaultCallStackSize = 200
+	defaultRegistrySize  = 256 * 20
+
+	maxStringRepLength  = 16 << 20 // string.rep结果的最大长度
+	memoryCheckInterval = 50 * time.Millisecond
+)
+
+// withDefaults 填充默认限制
+func (l SandboxLimits) withDefaults() SandboxLimits {
+	if l.CallTimeout <= 0 {
+		l.CallTimeout = defaultCallTimeout
+	}
+	if l.CallStackSize <= 0 {
+		l.CallStackSize = defaultCallStackSize
+	}
+	if l.RegistrySize <= 0 {
+		l.RegistrySize = defaultRegistrySize
+	}
+	return l
+}
+
+// sandboxLibs 沙箱中开放的标准库
+var sandboxLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+	{lua.OsLibName, lua.OpenOs}, // 只保留时间相关函数, 见sandboxOsFuncs
+}
+
+// forbiddenGlobals 基础库中可以加载外部代码或绕过沙箱的函数
+var forbiddenGlobals = []string{
+	"dofile", "loadfile", "load", "loadstring", "require", "module",
+	"getfenv", "setfenv", "collectgarbage", "newproxy", "_printregs",
+}
+
+// forbiddenLibs 不开放的标准库, 访问其中任何函数都视为违规
+var forbiddenLibs = []string{lua.IoLibName, lua.LoadLibName, lua.DebugLibName, lua.CoroutineLibName, lua.ChannelLibName}
+
+// sandboxOsFuncs os库中允许使用的函数
+var sandboxOsFuncs = []string{"time", "date", "clock", "difftime"}
+
+// newSandboxState 创建只开放白名单标准库的Lua状态
+func (w *Worker) newSandboxState() *lua.LState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:  true,
+		CallStackSize: w.limits.CallStackSize,
+		RegistrySize:  w.limits.RegistrySize,
+	})
+	for _, lib := range sandboxLibs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	for _, name := range forbiddenGlobals {
+		L.SetGlobal(name, L.NewFunction(w.forbidden(name)))
+	}
+
+	// os库只保留时间函数, 其余函数(execute, remove, exit等)视为违规
+	osLib := L.GetGlobal(lua.OsLibName).(*lua.LTable)
+	safeOs := L.NewTable()
+	for _, name := range sandboxOsFuncs {
+		safeOs.RawSetString(name, osLib.RawGetString(name))
+	}
+	L.SetMetatable(safeOs, w.forbiddenMeta(L, lua.OsLibName))
+	L.SetGlobal(lua.OsLibName, safeOs)
+
+	for _, name := range forbiddenLibs {
+		lib := L.NewTable()
+		L.SetMetatable(lib, w.forbiddenMeta(L, name))
+		L.SetGlobal(name, lib)
+	}
+
+	// 限制string.rep的结果长度, 避免一次调用耗尽内存
+	stringLib := L.GetGlobal(lua.StringLibName).(*lua.LTable)
+	rep := stringLib.RawGetString("rep")
+	stringLib.RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+		if length := float64(len(L.CheckString(1))) * float64(L.CheckNumber(2)); length > maxStringRepLength {
+			L.RaiseError("string.rep result too large: %.0f bytes", length)
+			return 0
+		}
+		top := L.GetTop()
+		L.Push(rep)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, 1)
+		return 1
+	}))
+
+	return L
+}
+
+// forbidden 被禁用函数的替身, 调用时记录违规并抛出错误
+func (w *Worker) forbidden(name string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		w.violation = fmt.Errorf("%s is not allowed in strategy sandbox", name)
+		L.RaiseError("%v", w.violation)
+		return 0
+	}
+}
+
+// forbiddenMeta 访问库中未开放的字段时记录违规并抛出错误
+func (w *Worker) forbiddenMeta(L *lua.LState, lib string) *lua.LTable {
+	meta := L.NewTable()
+	L.SetField(meta, "__index", L.NewFunction(func(L *lua.LState) int {
+		return w.forbidden(lib + "." + L.CheckString(2))(L)
+	}))
+	return meta
+}
+
+// callLua 在沙箱资源限制下调用Lua函数
+func (w *Worker) callLua(fn lua.LValue, nret int, args ...lua.LValue) error {
+	return w.guard(func() error {
+		return w.luaState.CallByParam(lua.P{
+			Fn:      fn,
+			NRet:    nret,
+			Protect: true,
+		}, args...)
+	})
+}
+
+// guard 在时间预算下执行Lua代码, 超时或违规时中断执行并返回对应错误码的引擎错误;
+// 内存限制按运行检查(见watchMemory), 不在每次调用时检查
+func (w *Worker) guard(run func() error) error {
+	parent := w.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, w.limits.CallTimeout)
+	defer cancel()
+
+	w.violation = nil
+	w.luaState.SetContext(ctx)
+	err := run()
+	w.luaState.RemoveContext()
+
+	switch {
+	case w.violation != nil:
+		violation := w.violation
+		w.violation = nil
+		return NewEngineError(ErrLuaSandboxViolation, "strategy violated sandbox", violation)
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil:
+		return NewEngineError(ErrLuaTimeout, "strategy exceeded time budget",
+			fmt.Errorf("call took longer than %v: %v", w.limits.CallTimeout, err))
+	}
+	return err
+}
+
+// liveHeapMetric 上次GC后的存活堆内存(进程级)
+const liveHeapMetric = "/gc/heap/live:bytes"
+
+// liveHeap 读取存活堆内存
+func liveHeap() uint64 {
+	sample := []metrics.Sample{{Name: liveHeapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// watchMemory 在一次运行期间定期检查存活堆内存, 增长超过limit时调用onExceeded, 返回停止检查的函数; limit为0时不检查
+// 这只是尽力而为的上限: 存活堆内存为整个进程的统计且只在GC后更新, 所有工作单元以及API缓存、K线缓存等
+// 应用其他部分的分配都会计入, 无法归咎于某个策略或某只股票, 因此默认不启用, 启用后超限时停止整个运行;
+// gopher-lua不提供按Lua状态统计内存的方式, 单个Lua状态只受数据栈(RegistrySize)和调用栈(CallStackSize)大小限制
+func watchMemory(limit uint64, onExceeded func()) func() {
+	if limit == 0 {
+		return func() {}
+	}
+	base := liveHeap()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(memoryCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if current := liveHeap(); current > base && current-base > limit {
+					onExceeded()
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// memoryLimitError 运行因进程堆内存增长超过限制而停止的错误
+func memoryLimitError(limit uint64) error {
+	return NewEngineError(ErrLuaMemoryLimit, "strategy run exceeded memory limit",
+		fmt.Errorf("process heap grew more than %d MB during run", limit>>20))
+}
+
+// scriptError 包装策略脚本错误, 沙箱限制类错误保留其错误码以便区分
+func scriptError(err error, action string) error {
+	var engineErr *EngineError
+	if errors.As(err, &engineErr) {
+		switch engineErr.Code {
+		case ErrLuaTimeout, ErrLuaMemoryLimit, ErrLuaSandboxViolation:
+			return NewEngineError(engineErr.Code, engineErr.Message, fmt.Errorf("%s: %v", action, engineErr.Err))
+		}
+	}
+	return ErrLuaScriptFailed(fmt.Errorf("%s: %v", action, err))
+}
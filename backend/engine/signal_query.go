@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+)
+
+// 信号排序字段
+const (
+	SortByScore    = "score"
+	SortByChange   = "change"
+	SortByTurnover = "turnover"
+	SortByPrice    = "price"
+	SortByCode     = "code"
+)
+
+// SignalQuery 信号查询条件
+type SignalQuery struct {
+	SortBy     string   `json:"sortBy"`     // 排序字段: score, change, turnover, price, code, 为空保持原顺序
+	Descending bool     `json:"descending"` // 是否降序
+	Tags       []string `json:"tags"`       // 必须包含的标签(全部匹配)
+	Type       string   `json:"type"`       // 信号类型: entry, exit, 为空不限
+	MinScore   *float64 `json:"minScore"`   // 最低评分
+	Limit      int      `json:"limit"`      // 返回数量上限, 0表示不限
+}
+
+// QuerySignals 按条件筛选并排序信号, 不修改原切片
+func QuerySignals(signals []StockSignal, query SignalQuery) []StockSignal {
+	result := make([]StockSignal, 0, len(signals))
+	for _, signal := range signals {
+		if query.Type != "" && signalType(signal) != query.Type {
+			continue
+		}
+		if query.MinScore != nil && signal.Score < *query.MinScore {
+			continue
+		}
+		if !hasAllTags(signal, query.Tags) {
+			continue
+		}
+		result = append(result, signal)
+	}
+
+	if less := signalLess(query.SortBy); less != nil {
+		sort.SliceStable(result, func(i, j int) bool {
+			if query.Descending {
+				return less(result[j], result[i])
+			}
+			return less(result[i], result[j])
+		})
+	}
+
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+	return result
+}
+
+// SignalTags 汇总信号中出现的全部标签
+func SignalTags(signals []StockSignal) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, signal := range signals {
+		for _, tag := range signal.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// signalType 获取信号类型, 未标注类型的视为买入信号
+func signalType(signal StockSignal) string {
+	if signal.IsExit() {
+		return SignalTypeExit
+	}
+	return SignalTypeEntry
+}
+
+// hasAllTags 信号是否包含全部指定标签(不区分大小写)
+func hasAllTags(signal StockSignal, tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, tag := range signal.Tags {
+			if strings.EqualFold(tag, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// signalLess 获取排序比较函数
+func signalLess(sortBy string) func(a, b StockSignal) bool {
+	switch sortBy {
+	case SortByScore:
+		return func(a, b StockSignal) bool { return a.Score < b.Score }
+	case SortByChange:
+		return func(a, b StockSignal) bool { return a.Change < b.Change }
+	case SortByTurnover:
+		return func(a, b StockSignal) bool { return a.Turnover < b.Turnover }
+	case SortByPrice:
+		return func(a, b StockSignal) bool { return a.Price < b.Price }
+	case SortByCode:
+		return func(a, b StockSignal) bool { return a.Code < b.Code }
+	default:
+		return nil
+	}
+}
@@ -2,6 +2,8 @@ package engine
 
 import (
 	"time"
+
+	"stock-helper-svelte/backend/universe"
 )
 
 // ExecutionStatus 执行状态
@@ -15,7 +17,8 @@ type ExecutionStatus struct {
 	Speed          float64   `json:"speed"`          // 处理速度(个/秒)
 	EstimateTime   int       `json:"estimateTime"`   // 预计剩余时间(秒)
 	Error          string    `json:"error"`          // 错误信息
-	StrategyId     int       `json:"strategyId"`     // 策略ID
+	StrategyId     int       `json:"strategyId"`     // 策略ID, 批量执行的整体状态为0
+	FailedCount    int       `json:"failedCount"`    // 处理失败的股票数
 }
 
 // Status constants
@@ -40,6 +43,10 @@ type engineState struct {
 	strategyId     int       // 当前策略ID
 	paused         bool      // 是否暂停
 	shouldStop     bool      // 是否应该停止
+
+	failures    *failureCollector // 当前(或最近一次)运行中处理失败的股票
+	checkpoints *checkpointer     // 当前实盘选股的运行进度
+	batch       []*batchMember    // 当前(或最近一次)批量执行的各策略
 }
 
 // newEngineState 创建新的引擎状态
@@ -73,6 +80,7 @@ func (s *engineState) toExecutionStatus() ExecutionStatus {
 		EstimateTime:   estimateTime,
 		Error:          s.error,
 		StrategyId:     s.strategyId,
+		FailedCount:    s.failures.count(),
 	}
 }
 
@@ -85,6 +93,12 @@ type ExecutionResult struct {
 	TotalStocks     int           `json:"totalStocks"`     // 总股票数
 	ProcessedStocks int           `json:"processedStocks"` // 已处理股票数
 	Signals         []StockSignal `json:"signals"`         // 信号列表
+	Positions       []Position    `json:"positions"`       // 执行后的持仓(上次持仓去掉卖出信号, 加上新买入信号), 只有定义了check_exit的策略记录
+	Tags            []string      `json:"tags,omitempty"`  // 信号中出现的全部标签
+
+	Params   map[string]interface{} `json:"params,omitempty"`   // 本次运行实际使用的策略参数
+	Failures *FailureReport         `json:"failures,omitempty"` // 处理失败的股票, 旧记录没有此字段
+	Universe *universe.Universe     `json:"universe,omitempty"` // 运行的股票池, 为空表示默认股票池
 }
 
 // ExecutionRecord 执行记录
@@ -96,4 +110,5 @@ type ExecutionRecord struct {
 	SignalCount    int       `json:"signalCount"`    // 信号数量
 	ProcessedCount int       `json:"processedCount"` // 处理数量
 	TotalStocks    int       `json:"totalStocks"`    // 总股票数
+	FailedCount    int       `json:"failedCount"`    // 处理失败的股票数
 }
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// hookOnTick 盘中监控钩子: on_tick(stock, quote)对每次更新的实时行情调用, 通过api.emit发出提醒
+const hookOnTick = "on_tick"
+
+// TickRunner 盘中监控时在单个Lua状态中运行策略的on_tick钩子, 不能并发调用
+type TickRunner struct {
+	worker *Worker
+}
+
+// NewTickRunner 加载策略并检查其定义了on_tick钩子
+func NewTickRunner(ctx context.Context, strategy *Strategy, apiClient *api.Client, options RunOptions) (*TickRunner, error) {
+	if strategy.Evaluator != nil || strategy.isComposite() {
+		return nil, NewEngineError(ErrInvalidStrategy, "strategy does not support monitoring",
+			fmt.Errorf("%s is only available to Lua strategies", hookOnTick))
+	}
+
+	options.Backtest = nil
+	options.Resume = nil
+	worker, err := NewWorker(0, strategy, NewExecutionMetrics(0), ctx, apiClient, discardUpdater{}, options)
+	if err != nil {
+		return nil, err
+	}
+	if !worker.hasOnTick() {
+		worker.Close()
+		return nil, NewEngineError(ErrInvalidStrategy, "strategy must define on_tick(stock, quote) to be monitored",
+			fmt.Errorf("%s is not defined", hookOnTick))
+	}
+	return &TickRunner{worker: worker}, nil
+}
+
+// OnTick 以股票和实时行情调用on_tick, 返回本次调用发出的信号; session为当前交易时段, 写入quote.session
+func (r *TickRunner) OnTick(stock types.Index, quote *types.RealtimeData, session string) ([]StockSignal, error) {
+	w := r.worker
+	select {
+	case <-w.ctx.Done():
+		return nil, NewEngineError(ErrWorkerClosed, "worker context cancelled", w.ctx.Err())
+	default:
+	}
+
+	w.current = stock
+	w.emitted = w.emitted[:0]
+
+	L := w.luaState
+	stockTable := L.NewTable()
+	L.SetField(stockTable, "code", lua.LString(stock.Code))
+	L.SetField(stockTable, "name", lua.LString(stock.Name))
+	L.SetField(stockTable, "exchange", lua.LString(stock.Exchange))
+	quoteTable := reflectToLua(L, reflect.ValueOf(quote))
+	if table, ok := quoteTable.(*lua.LTable); ok {
+		table.RawSetString("session", lua.LString(session))
+	}
+
+	if err := w.callLua(L.GetGlobal(hookOnTick), 0, stockTable, quoteTable); err != nil {
+		return nil, scriptError(err, fmt.Sprintf("failed to run %s for %s", hookOnTick, stock.Code))
+	}
+
+	signals := make([]StockSignal, len(w.emitted))
+	copy(signals, w.emitted)
+	return signals, nil
+}
+
+// Close 关闭Lua状态
+func (r *TickRunner) Close() {
+	r.worker.Close()
+}
+
+// hasOnTick 策略是否定义了on_tick钩子
+func (w *Worker) hasOnTick() bool {
+	return w.luaState.GetGlobal(hookOnTick).Type() == lua.LTFunction
+}
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"stock-helper-svelte/backend/api/types"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// dailyMinutes 日线对应的分钟数, 不低于该值的周期按交易日期对齐
+const dailyMinutes = 24 * 60
+
+// freqMinutes 各K线周期的近似时长(分钟), 仅用于比较周期大小
+var freqMinutes = map[types.KLineFreq]int{
+	types.FREQ_5MIN:        5,
+	types.FREQ_15MIN:       15,
+	types.FREQ_30MIN:       30,
+	types.FREQ_60MIN:       60,
+	types.FREQ_DAILY:       dailyMinutes,
+	types.FREQ_DAILY_QFQ:   dailyMinutes,
+	types.FREQ_DAILY_HFQ:   dailyMinutes,
+	types.FREQ_WEEKLY:      7 * dailyMinutes,
+	types.FREQ_WEEKLY_QFQ:  7 * dailyMinutes,
+	types.FREQ_WEEKLY_HFQ:  7 * dailyMinutes,
+	types.FREQ_MONTHLY:     31 * dailyMinutes,
+	types.FREQ_MONTHLY_QFQ: 31 * dailyMinutes,
+	types.FREQ_MONTHLY_HFQ: 31 * dailyMinutes,
+	types.FREQ_YEARLY:      366 * dailyMinutes,
+	types.FREQ_YEARLY_QFQ:  366 * dailyMinutes,
+	types.FREQ_YEARLY_HFQ:  366 * dailyMinutes,
+}
+
+// TimeframeSeries 对齐到基准周期的K线序列
+type TimeframeSeries struct {
+	Freq types.KLineFreq   `json:"freq"`
+	Bars []types.KLineData `json:"bars"`
+	// Index[i]为基准周期第i根K线对应的本序列K线下标, -1表示没有对应的K线:
+	// 更大周期取包含该K线的那根(如日线所在的周线, 其数值包含同周期内之后的K线, 需要避免未来数据时使用前一根);
+	// 更小或相同周期取该K线结束时(含)最近的一根(如当日最后一根60分钟线)
+	Index []int `json:"index"`
+}
+
+// MultiTimeframe 多周期K线数据
+type MultiTimeframe struct {
+	Base   types.KLineFreq                      `json:"base"`
+	Bars   []types.KLineData                    `json:"bars"`
+	Frames map[types.KLineFreq]*TimeframeSeries `json:"frames"`
+}
+
+// At 获取基准周期第i根K线对应的指定周期K线
+func (m *MultiTimeframe) At(freq types.KLineFreq, i int) (types.KLineData, bool) {
+	frame, ok := m.Frames[freq]
+	if !ok || i < 0 || i >= len(frame.Index) || frame.Index[i] < 0 {
+		return types.KLineData{}, false
+	}
+	return frame.Bars[frame.Index[i]], true
+}
+
+// AlignTimeframes 将多个周期的K线数据对齐到基准周期, 各序列需按时间升序排列
+func AlignTimeframes(base types.KLineFreq, series map[types.KLineFreq][]types.KLineData) (*MultiTimeframe, error) {
+	baseMinutes, ok := freqMinutes[base]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kline freq: %s", base)
+	}
+	baseBars, ok := series[base]
+	if !ok {
+		return nil, fmt.Errorf("missing kline data for base freq: %s", base)
+	}
+
+	result := &MultiTimeframe{
+		Base:   base,
+		Bars:   baseBars,
+		Frames: make(map[types.KLineFreq]*TimeframeSeries, len(series)),
+	}
+	for freq, bars := range series {
+		minutes, ok := freqMinutes[freq]
+		if !ok {
+			return nil, fmt.Errorf("unsupported kline freq: %s", freq)
+		}
+		result.Frames[freq] = &TimeframeSeries{
+			Freq:  freq,
+			Bars:  bars,
+			Index: alignIndex(baseBars, baseMinutes, bars, minutes),
+		}
+	}
+
+	return result, nil
+}
+
+// alignIndex 计算基准序列每根K线对应的目标序列下标
+// 按两者中较大周期的精度比较时间: 日线及以上只比较交易日期
+func alignIndex(base []types.KLineData, baseMinutes int, other []types.KLineData, otherMinutes int) []int {
+	key := func(t string) string { return t }
+	if otherMinutes >= dailyMinutes || baseMinutes >= dailyMinutes {
+		key = barDate
+	}
+
+	index := make([]int, len(base))
+	for i, bar := range base {
+		target := key(bar.Time)
+		var idx int
+		if otherMinutes > baseMinutes {
+			// 更大周期: 第一根结束时间不早于当前K线的K线
+			idx = sort.Search(len(other), func(j int) bool {
+				return key(other[j].Time) >= target
+			})
+			if idx == len(other) {
+				idx = -1
+			}
+		} else {
+			// 更小或相同周期: 最后一根结束时间不晚于当前K线的K线
+			idx = sort.Search(len(other), func(j int) bool {
+				return key(other[j].Time) > target
+			}) - 1
+		}
+		index[i] = idx
+	}
+	return index
+}
+
+// luaGetAlignedKLineData 获取多周期对齐K线数据的Lua包装函数
+// 用法: api.getAlignedKLineData(code, {"dh", "wh", "60m"}), 第一个周期为基准周期
+func (w *Worker) luaGetAlignedKLineData(L *lua.LState) int {
+	code := L.ToString(1)
+	freqs := luaStringSlice(L.Get(2))
+
+	if code == "" || len(freqs) == 0 {
+		luaErr := NewInvalidStockDataError(code, fmt.Errorf("code and freqs are required"))
+		L.Push(lua.LNil)
+		L.Push(lua.LString(luaErr.Error()))
+		return 2
+	}
+
+	series := make(map[types.KLineFreq][]types.KLineData, len(freqs))
+	for _, freq := range freqs {
+		data, err := w.fetchKLineData(code, types.KLineFreq(freq))
+		if err != nil {
+			luaErr := NewAPIRequestError("getAlignedKLineData", err)
+			L.Push(lua.LNil)
+			L.Push(lua.LString(luaErr.Error()))
+			return 2
+		}
+		// 回测模式下只暴露模拟交易日(含)之前的数据
+		series[types.KLineFreq(freq)] = truncateKLine(data, w.asOf)
+	}
+
+	aligned, err := AlignTimeframes(types.KLineFreq(freqs[0]), series)
+	if err != nil {
+		luaErr := NewInvalidStockDataError(code, err)
+		L.Push(lua.LNil)
+		L.Push(lua.LString(luaErr.Error()))
+		return 2
+	}
+
+	L.Push(multiTimeframeTable(L, aligned))
+	return 1
+}
+
+// multiTimeframeTable 将多周期数据转换为Lua表, 下标转换为从1开始, 0表示没有对应的K线
+func multiTimeframeTable(L *lua.LState, data *MultiTimeframe) *lua.LTable {
+	table := L.NewTable()
+	L.SetField(table, "base", lua.LString(data.Base))
+	L.SetField(table, "bars", klineTable(L, data.Bars))
+
+	frames := L.NewTable()
+	for freq, frame := range data.Frames {
+		frameTable := L.NewTable()
+		L.SetField(frameTable, "freq", lua.LString(frame.Freq))
+		L.SetField(frameTable, "bars", klineTable(L, frame.Bars))
+		indexTable := L.CreateTable(len(frame.Index), 0)
+		for _, idx := range frame.Index {
+			indexTable.Append(lua.LNumber(idx + 1))
+		}
+		L.SetField(frameTable, "index", indexTable)
+		L.SetField(frames, string(freq), frameTable)
+	}
+	L.SetField(table, "frames", frames)
+
+	// at(freq, i): 基准周期第i根K线对应的指定周期K线, 没有时返回nil
+	L.SetField(table, "at", L.NewFunction(func(L *lua.LState) int {
+		bar, ok := data.At(types.KLineFreq(L.CheckString(1)), L.CheckInt(2)-1)
+		if !ok {
+			L.Push(lua.LNil)
+			return 1
+		}
+		L.Push(klineTable(L, []types.KLineData{bar}).RawGetInt(1))
+		return 1
+	}))
+
+	return table
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// 跟踪事件类型
+const (
+	TraceEventCall   = "call"   // api函数调用
+	TraceEventLog    = "log"    // log输出
+	TraceEventSignal = "signal" // 发出的信号
+)
+
+// maxTraceString 跟踪记录中字符串参数的最大显示长度
+const maxTraceString = 64
+
+// TraceEvent 调试运行中的一条事件
+type TraceEvent struct {
+	Seq      int          `json:"seq"`
+	Kind     string       `json:"kind"`               // 事件类型: call, log, signal
+	Function string       `json:"function,omitempty"` // 被调用的函数, 如api.indicator.calculateMA
+	Args     []string     `json:"args,omitempty"`     // 参数概要
+	Returns  []string     `json:"returns,omitempty"`  // 返回值概要, 表只给出元素数量
+	Error    string       `json:"error,omitempty"`    // 调用抛出的错误或返回的错误信息
+	Message  string       `json:"message,omitempty"`  // log内容
+	Signal   *StockSignal `json:"signal,omitempty"`   // 发出的信号
+	Offset   float64      `json:"offset"`             // 距运行开始的时间(毫秒)
+	Elapsed  float64      `json:"elapsed"`            // 调用耗时(毫秒)
+}
+
+// DebugTrace 单只股票调试运行的跟踪记录
+type DebugTrace struct {
+	StrategyID   int           `json:"strategyId"`
+	StrategyName string        `json:"strategyName"`
+	Code         string        `json:"code"`
+	Name         string        `json:"name"`
+	StartTime    time.Time     `json:"startTime"`
+	Elapsed      float64       `json:"elapsed"` // 总耗时(毫秒)
+	Events       []TraceEvent  `json:"events"`  // 按调用开始的先后排列
+	Signals      []StockSignal `json:"signals"`
+	Error        string        `json:"error,omitempty"`     // 策略加载或运行失败的原因
+	Traceback    string        `json:"traceback,omitempty"` // Lua错误的调用栈
+}
+
+// tracer 记录调试运行中的事件, 同时作为调试运行的StatusUpdater收集信号
+type tracer struct {
+	mu      sync.Mutex
+	start   time.Time
+	events  []TraceEvent
+	signals []StockSignal
+}
+
+// DebugStrategy 在独立的Lua状态中对单只股票运行策略(不经过执行引擎), 记录每次api调用、log输出和发出的信号
+// 两阶段排序策略对该股票依次运行score_stock和select
+func DebugStrategy(ctx context.Context, strategy *Strategy, stock types.Index, apiClient *api.Client, options RunOptions) *DebugTrace {
+	t := &tracer{start: time.Now()}
+	options.Backtest = nil
+	options.KLineCache = nil
+	options.trace = t
+
+	worker, err := NewWorker(0, strategy, NewExecutionMetrics(1), ctx, apiClient, t, options)
+	if err == nil {
+		defer worker.Close()
+		worker.scores = &scoreBoard{}
+		err = worker.ProcessStock(stock)
+		if err == nil && worker.isRanking() {
+			if selectErr := worker.callSelect(worker.scores.ranked()); selectErr != nil {
+				err = scriptError(selectErr, fmt.Sprintf("failed to run %s", hookSelect))
+			}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	trace := &DebugTrace{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Code:         stock.Code,
+		Name:         stock.Name,
+		StartTime:    t.start,
+		Elapsed:      millis(time.Since(t.start)),
+		Events:       t.events,
+		Signals:      t.signals,
+	}
+	if trace.Events == nil {
+		trace.Events = []TraceEvent{}
+	}
+	if trace.Signals == nil {
+		trace.Signals = []StockSignal{}
+	}
+	if err != nil {
+		trace.Error = diagnosticMessage(err)
+		if _, traceback, found := strings.Cut(err.Error(), "stack traceback:"); found {
+			trace.Traceback = "stack traceback:" + strings.TrimRight(traceback, "\n")
+		}
+	}
+	return trace
+}
+
+func (t *tracer) UpdateStatus(ExecutionStatus)                            {}
+func (t *tracer) UpdateProgress(processedStocks int, currentStock string) {}
+
+// AddSignal 记录发出的信号
+func (t *tracer) AddSignal(signal StockSignal) {
+	idx := t.begin(TraceEvent{Kind: TraceEventSignal, Signal: &signal})
+	t.mu.Lock()
+	t.signals = append(t.signals, signal)
+	t.mu.Unlock()
+	t.end(idx, time.Now())
+}
+
+// begin 记录事件开始, 返回事件序号
+func (t *tracer) begin(event TraceEvent) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	event.Seq = len(t.events) + 1
+	event.Offset = millis(time.Since(t.start))
+	t.events = append(t.events, event)
+	return len(t.events) - 1
+}
+
+// end 记录事件结束, 填写耗时
+func (t *tracer) end(idx int, start time.Time) {
+	t.mu.Lock()
+	t.events[idx].Elapsed = millis(time.Since(start))
+	t.mu.Unlock()
+}
+
+// update 修改已记录的事件
+func (t *tracer) update(idx int, fn func(event *TraceEvent)) {
+	t.mu.Lock()
+	fn(&t.events[idx])
+	t.mu.Unlock()
+}
+
+// instrument 将工作单元的log和api表中的全部函数替换为记录调用的包装函数
+func (t *tracer) instrument(L *lua.LState) {
+	if log, ok := L.GetGlobal("log").(*lua.LFunction); ok {
+		L.SetGlobal("log", t.wrap(L, "log", log))
+	}
+	if apiTable, ok := L.GetGlobal("api").(*lua.LTable); ok {
+		t.instrumentTable(L, "api", apiTable)
+	}
+}
+
+// instrumentTable 递归包装表中的函数
+func (t *tracer) instrumentTable(L *lua.LState, prefix string, table *lua.LTable) {
+	var names []string
+	table.ForEach(func(key, _ lua.LValue) {
+		if name, ok := key.(lua.LString); ok {
+			names = append(names, string(name))
+		}
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		switch value := table.RawGetString(name).(type) {
+		case *lua.LFunction:
+			table.RawSetString(name, t.wrap(L, prefix+"."+name, value))
+		case *lua.LTable:
+			t.instrumentTable(L, prefix+"."+name, value)
+		}
+	}
+}
+
+// wrap 包装函数: 记录参数、返回值、耗时和错误后原样返回
+func (t *tracer) wrap(L *lua.LState, name string, fn *lua.LFunction) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		top := L.GetTop()
+		event := TraceEvent{Kind: TraceEventCall, Function: name, Args: describeValues(L, 1, top)}
+		if name == "log" {
+			event = TraceEvent{Kind: TraceEventLog, Message: L.ToString(1)}
+		}
+		idx := t.begin(event)
+		start := time.Now()
+
+		// 函数抛出错误时记录后继续向上抛出
+		defer func() {
+			if r := recover(); r != nil {
+				t.update(idx, func(event *TraceEvent) {
+					event.Error = firstLine(fmt.Sprint(r))
+				})
+				t.end(idx, start)
+				panic(r)
+			}
+		}()
+
+		L.Push(fn)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, lua.MultRet)
+		nret := L.GetTop() - top
+
+		t.update(idx, func(event *TraceEvent) {
+			if event.Kind != TraceEventCall {
+				return
+			}
+			event.Returns = describeValues(L, top+1, L.GetTop())
+			// 按(nil, err)约定返回的错误
+			if nret >= 2 && L.Get(top+1) == lua.LNil {
+				event.Error = L.Get(top + 2).String()
+			}
+		})
+		t.end(idx, start)
+		return nret
+	})
+}
+
+// describeValues 获取栈上[from, to]范围内各值的概要
+func describeValues(L *lua.LState, from, to int) []string {
+	if to < from {
+		return nil
+	}
+	values := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		values = append(values, describeValue(L.Get(i)))
+	}
+	return values
+}
+
+// describeValue 获取Lua值的概要: 数值和短字符串原样显示, 表只显示元素数量
+func describeValue(value lua.LValue) string {
+	switch v := value.(type) {
+	case lua.LString:
+		if len(v) > maxTraceString {
+			return fmt.Sprintf("%q...(%d bytes)", string(v[:maxTraceString]), len(v))
+		}
+		return fmt.Sprintf("%q", string(v))
+	case *lua.LTable:
+		if n := v.Len(); n > 0 {
+			return fmt.Sprintf("table[%d]", n)
+		}
+		fields := 0
+		v.ForEach(func(_, _ lua.LValue) { fields++ })
+		return fmt.Sprintf("table{%d}", fields)
+	case *lua.LFunction:
+		return "function"
+	default:
+		return value.String()
+	}
+}
+
+// millis 将时长转换为毫秒
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
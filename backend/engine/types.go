@@ -5,6 +5,14 @@ import (
 	"time"
 
 	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/universe"
+)
+
+// 策略类型
+const (
+	StrategyTypeLua       = "lua"       // Lua脚本策略
+	StrategyTypeComposite = "composite" // 组合策略
+	StrategyTypeScreen    = "screen"    // 声明式选股条件
 )
 
 // Strategy 策略定义
@@ -13,6 +21,16 @@ type Strategy struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	FilePath    string `json:"filePath"`
+	Type        string `json:"type"` // 策略类型, 见StrategyType常量
+
+	Params []StrategyParam `json:"params"` // 策略声明的可调参数
+
+	// 组合策略, 由 -- @compose 声明
+	Compose    string      `json:"compose,omitempty"` // 组合表达式, 如 "1 AND NOT 2"
+	Components []*Strategy `json:"-"`                 // 表达式引用的子策略
+
+	// 非Lua策略的求值器, 设置后不加载策略文件
+	Evaluator Evaluator `json:"-"`
 }
 
 // StrategyMeta 策略元数据
@@ -20,17 +38,23 @@ type StrategyMeta struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	Params  []StrategyParam `json:"params"`            // 策略声明的可调参数
+	Compose string          `json:"compose,omitempty"` // 组合表达式
 }
 
 // ExecutionConfig 执行引擎配置
 type ExecutionConfig struct {
-	WorkerPoolSize   int             // 工作池大小
-	BatchSize        int             // 批处理大小
-	RetryAttempts    int             // 重试次数
-	RetryDelay       time.Duration   // 重试延迟
-	ExecutionTimeout time.Duration   // 执行超时时间
-	APIClient        *api.Client     // API客户端
-	Context          context.Context // 上下文
+	WorkerPoolSize   int              // 工作池大小
+	BatchSize        int              // 批处理大小
+	RetryAttempts    int              // 重试次数
+	RetryDelay       time.Duration    // 重试延迟
+	ExecutionTimeout time.Duration    // 执行超时时间
+	APIClient        *api.Client      // API客户端
+	Context          context.Context  // 上下文
+	Sandbox          SandboxLimits    // Lua沙箱资源限制, 零值字段使用默认值
+	Checkpoints      CheckpointStore  // 断点存储, 为空时不保存断点
+	Universe         universe.Sources // 解析自选股、执行记录等股票池来源, Client由APIClient填充
 }
 
 // ExecutionStats 执行统计信息
@@ -54,7 +78,7 @@ type StatusUpdater interface {
 // ExecutionEngine 执行引擎接口
 type ExecutionEngine interface {
 	// 核心执行方法
-	Execute(strategy *Strategy) error
+	Execute(strategy *Strategy, options RunOptions) error
 
 	// 控制方法
 	Pause()
@@ -72,10 +96,51 @@ type ExecutionEngine interface {
 
 // StockSignal 股票信号
 type StockSignal struct {
-	Code     string  `json:"code"`     // 股票代码
-	Name     string  `json:"name"`     // 股票名称
-	Price    float64 `json:"price"`    // 当前价格
-	Turnover float64 `json:"turnover"` // 换手率
-	Change   float64 `json:"change"`   // 涨跌幅
-	Reason   string  `json:"reason"`   // 信号原因
+	Code     string  `json:"code"`           // 股票代码
+	Name     string  `json:"name"`           // 股票名称
+	Price    float64 `json:"price"`          // 当前价格
+	Turnover float64 `json:"turnover"`       // 换手率
+	Change   float64 `json:"change"`         // 涨跌幅
+	Reason   string  `json:"reason"`         // 信号原因
+	Date     string  `json:"date,omitempty"` // 信号日期(回测模式下为模拟交易日)
+	Type     string  `json:"type"`           // 信号类型: entry, exit
+
+	// 结构化信号字段, 由api.emit{...}设置
+	Score  float64                `json:"score"`            // 信号评分, 用于排序
+	Tags   []string               `json:"tags,omitempty"`   // 标签, 用于筛选
+	Fields map[string]interface{} `json:"fields,omitempty"` // 自定义字段
+}
+
+// 信号类型
+const (
+	SignalTypeEntry = "entry" // 买入(选股)信号
+	SignalTypeExit  = "exit"  // 卖出信号
+)
+
+// IsExit 是否为卖出信号, 未标注类型的旧信号视为买入信号
+func (s StockSignal) IsExit() bool {
+	return s.Type == SignalTypeExit
+}
+
+// Position 持仓, 供策略的check_exit钩子判断是否卖出
+type Position struct {
+	Code       string  `json:"code"`       // 股票代码
+	Name       string  `json:"name"`       // 股票名称
+	EntryDate  string  `json:"entryDate"`  // 买入日期
+	EntryPrice float64 `json:"entryPrice"` // 买入价格
+}
+
+// RunOptions 单次运行选项
+type RunOptions struct {
+	Backtest  *BacktestConfig        // 回测配置, 为空表示实盘选股
+	Positions []Position             // 实盘选股时的当前持仓, 对其调用check_exit
+	Params    map[string]interface{} // 策略参数, 以全局变量params注入Lua
+
+	KLineCache *KLineCache   // 共享K线缓存, 为空时每只股票单独缓存
+	Sandbox    SandboxLimits // Lua沙箱资源限制, 零值时使用引擎配置
+	Resume     *Checkpoint   // 从断点继续运行, 跳过已处理的股票(仅实盘选股)
+
+	Universe *universe.Universe // 运行的股票池, 为空时使用默认股票池(沪深A股, 排除ST和北交所)
+
+	trace *tracer // 调试运行的跟踪记录, 见DebugStrategy
 }
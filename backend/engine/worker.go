@@ -3,11 +3,12 @@ package engine
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
 
 	"stock-helper-svelte/backend/api"
 	"stock-helper-svelte/backend/api/types" // 确保这个导入路径正确
-	"stock-helper-svelte/backend/indicators"
 
 	lua "github.com/yuin/gopher-lua"
 )
@@ -21,23 +22,55 @@ type Worker struct {
 	strategy      *Strategy
 	ctx           context.Context
 	statusUpdater StatusUpdater
+	options       RunOptions
+
+	positions map[string]Position // 实盘持仓, 按代码索引
+	current   types.Index         // 当前处理的股票
+	emitted   []StockSignal       // 本次调用发出的信号
+	scores    *scoreBoard         // 两阶段排序策略的评分, 由工作池共享
+
+	// Lua沙箱
+	limits    SandboxLimits // 资源限制
+	violation error         // 本次调用中的沙箱违规
+
+	// 组合策略
+	compose    *ComposeExpr
+	components []componentWorker
+
+	// 回测状态
+	asOf      string                       // 当前模拟交易日, 为空表示不截断数据
+	klineMemo map[string][]types.KLineData // 单只股票回测期间的K线缓存
+
+	// 批量执行时同一只股票在各策略间共享的数据接口结果, 为空表示不缓存
+	dataMemo map[string]reflect.Value
 }
 
-// NewWorker 创建新的工作单元
-func NewWorker(id int, strategy *Strategy, metrics *ExecutionMetrics, ctx context.Context, apiClient *api.Client, statusUpdater StatusUpdater) (*Worker, error) {
-	L := lua.NewState()
-	if L == nil {
-		return nil, NewEngineError(ErrLuaStateCreation, "failed to create Lua state", nil)
-	}
+// strategyParamsGlobal 策略中保存参数默认值的全局表名; 加载策略后引擎将本次运行的参数值(声明的默认值合并覆盖值)
+// 写入该表, 策略代码直接读取即可, 不需要自行合并params; 该表必须是全局变量, local表引擎无法访问
+const strategyParamsGlobal = "STRATEGY_PARAMS"
 
+// NewWorker 创建新的工作单元
+func NewWorker(id int, strategy *Strategy, metrics *ExecutionMetrics, ctx context.Context, apiClient *api.Client, statusUpdater StatusUpdater, options RunOptions) (*Worker, error) {
 	worker := &Worker{
 		id:            id,
-		luaState:      L,
 		metrics:       metrics,
 		strategy:      strategy,
 		ctx:           ctx,
 		apiClient:     apiClient,
 		statusUpdater: statusUpdater,
+		options:       options,
+		positions:     make(map[string]Position, len(options.Positions)),
+		limits:        options.Sandbox.withDefaults(),
+	}
+
+	// 创建只开放白名单标准库的Lua状态
+	L := worker.newSandboxState()
+	if L == nil {
+		return nil, NewEngineError(ErrLuaStateCreation, "failed to create Lua state", nil)
+	}
+	worker.luaState = L
+	for _, pos := range options.Positions {
+		worker.positions[pos.Code] = pos
 	}
 
 	// 注册Lua函数
@@ -46,15 +79,75 @@ func NewWorker(id int, strategy *Strategy, metrics *ExecutionMetrics, ctx contex
 		return nil, ErrLuaFuncRegFailed(err)
 	}
 
+	// 注入本次运行的策略参数
+	params := options.Params
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	L.SetGlobal("params", goToLua(L, params))
+
+	// 调试运行时记录api调用和日志
+	if options.trace != nil {
+		options.trace.instrument(L)
+	}
+
+	// 非Lua策略由求值器处理, 不加载策略文件
+	if strategy.Evaluator != nil {
+		return worker, nil
+	}
+
+	// 检查策略引用的指标函数是否都已登记
+	if err := checkIndicatorRefs(strategy.FilePath); err != nil {
+		L.Close()
+		return nil, err
+	}
+
+	// 检查策略引用的数据接口是否都已登记
+	if err := checkDataRefs(strategy.FilePath); err != nil {
+		L.Close()
+		return nil, err
+	}
+
 	// 加载策略文件
-	if err := L.DoFile(strategy.FilePath); err != nil {
+	builtinSelect := L.GetGlobal(hookSelect)
+	if err := worker.guard(func() error { return L.DoFile(strategy.FilePath) }); err != nil {
 		L.Close()
-		return nil, ErrLuaScriptFailed(fmt.Errorf("failed to load strategy file: %v", err))
+		return nil, scriptError(err, "failed to load strategy file")
+	}
+
+	// 将本次运行的参数合并到策略的参数表
+	worker.mergeStrategyParams(params)
+
+	// 检查两阶段排序钩子
+	if err := worker.checkRankingHooks(builtinSelect); err != nil {
+		L.Close()
+		return nil, err
+	}
+
+	// 创建组合策略的子策略
+	if strategy.isComposite() {
+		components, err := newComponentWorkers(worker)
+		if err != nil {
+			L.Close()
+			return nil, err
+		}
+		worker.components = components
 	}
 
 	return worker, nil
 }
 
+// mergeStrategyParams 将参数值写入策略定义的STRATEGY_PARAMS全局表, 使策略代码无需自行合并params; 未定义该表时跳过
+func (w *Worker) mergeStrategyParams(params map[string]interface{}) {
+	table, ok := w.luaState.GetGlobal(strategyParamsGlobal).(*lua.LTable)
+	if !ok {
+		return
+	}
+	for name, value := range params {
+		table.RawSetString(name, goToLua(w.luaState, value))
+	}
+}
+
 // ProcessStock 处理单个股票
 func (w *Worker) ProcessStock(stock types.Index) error {
 	select {
@@ -63,37 +156,256 @@ func (w *Worker) ProcessStock(stock types.Index) error {
 	default:
 	}
 
+	w.current = stock
+	w.emitted = w.emitted[:0]
+	if w.options.Backtest != nil {
+		return w.backtestStock(stock)
+	}
+
+	// 已持有的股票先检查卖出条件
+	if pos, ok := w.positions[stock.Code]; ok {
+		if err := w.callCheckExit(pos); err != nil {
+			w.metrics.IncrementErrors()
+			return scriptError(err, fmt.Sprintf("failed to check exit for %s", stock.Code))
+		}
+	}
+
+	// 两阶段排序策略只计算评分, 信号由select统一发出
+	if w.isRanking() {
+		if err := w.callScoreStock(stock); err != nil {
+			w.metrics.IncrementErrors()
+			return scriptError(err, fmt.Sprintf("failed to score stock %s", stock.Code))
+		}
+		w.metrics.IncrementProcessed()
+		return nil
+	}
+
+	if err := w.callStrategy(stock); err != nil {
+		w.metrics.IncrementErrors()
+		return scriptError(err, fmt.Sprintf("failed to process stock %s", stock.Code))
+	}
+
+	w.metrics.IncrementProcessed()
+	return nil
+}
+
+// backtestStock 按交易日逐日回放单个股票
+func (w *Worker) backtestStock(stock types.Index) error {
+	w.klineMemo = make(map[string][]types.KLineData)
+	defer func() {
+		w.asOf = ""
+		w.klineMemo = nil
+	}()
+
+	series, err := w.fetchKLineData(stock.Code, w.options.Backtest.Freq)
+	if err != nil {
+		w.metrics.IncrementErrors()
+		return NewAPIRequestError("getKLineData", err)
+	}
+
+	// 回测期间模拟的持仓, 发出买入信号后开仓, check_exit发出卖出信号后平仓
+	var held *Position
+	for _, date := range tradingDates(series, w.options.Backtest.StartDate, w.options.Backtest.EndDate) {
+		select {
+		case <-w.ctx.Done():
+			return NewEngineError(ErrWorkerClosed, "worker context cancelled", w.ctx.Err())
+		default:
+		}
+
+		w.asOf = date
+		w.emitted = w.emitted[:0]
+		if held != nil {
+			if err := w.callCheckExit(*held); err != nil {
+				w.metrics.IncrementErrors()
+				return scriptError(err, fmt.Sprintf("failed to check exit for %s on %s", stock.Code, date))
+			}
+			if w.emittedType(stock.Code, SignalTypeExit) != nil {
+				held = nil
+			}
+		}
+
+		if err := w.callStrategy(stock); err != nil {
+			w.metrics.IncrementErrors()
+			return scriptError(err, fmt.Sprintf("failed to process stock %s on %s", stock.Code, date))
+		}
+
+		if held == nil {
+			if signal := w.emittedType(stock.Code, SignalTypeEntry); signal != nil {
+				held = &Position{
+					Code:       stock.Code,
+					Name:       stock.Name,
+					EntryDate:  date,
+					EntryPrice: signal.Price,
+				}
+			}
+		}
+	}
+
+	w.metrics.IncrementProcessed()
+	return nil
+}
+
+// callStrategy 处理单个股票: 求值器策略调用求值器, 组合策略运行各子策略, 其余策略调用process_stock
+func (w *Worker) callStrategy(stock types.Index) error {
+	if w.strategy.Evaluator != nil {
+		return w.callEvaluator(stock)
+	}
+	if w.strategy.isComposite() {
+		return w.callComposite(stock)
+	}
+	return w.callProcessStock(stock)
+}
+
+// callProcessStock 调用策略的process_stock函数
+func (w *Worker) callProcessStock(stock types.Index) error {
 	// 创建股票数据表
 	stockTable := w.luaState.NewTable()
 	w.luaState.SetField(stockTable, "code", lua.LString(stock.Code))
 	w.luaState.SetField(stockTable, "name", lua.LString(stock.Name))
 	w.luaState.SetField(stockTable, "exchange", lua.LString(stock.Exchange))
 
-	// 调用process_stock函数
-	err := w.luaState.CallByParam(lua.P{
-		Fn:      w.luaState.GetGlobal("process_stock"),
-		NRet:    0,
-		Protect: true,
-	}, stockTable)
+	return w.callLua(w.luaState.GetGlobal("process_stock"), 0, stockTable)
+}
 
+// DefinesExitHook 加载策略文件(不连接API), 判断策略是否定义了check_exit钩子; 加载失败时返回false
+func DefinesExitHook(strategy *Strategy) bool {
+	params, err := ResolveParams(strategy.Params, nil)
 	if err != nil {
-		w.metrics.IncrementErrors()
-		return ErrLuaScriptFailed(fmt.Errorf("failed to process stock %s: %v", stock.Code, err))
+		return false
 	}
+	worker, err := NewWorker(0, strategy, NewExecutionMetrics(0), context.Background(), nil, discardUpdater{}, RunOptions{Params: params})
+	if err != nil {
+		return false
+	}
+	defer worker.Close()
+	return worker.hasCheckExit()
+}
 
-	w.metrics.IncrementProcessed()
+// hasCheckExit 策略是否定义了check_exit钩子
+func (w *Worker) hasCheckExit() bool {
+	return w.luaState.GetGlobal("check_exit").Type() == lua.LTFunction
+}
+
+// callCheckExit 调用策略的check_exit(position, kdata)钩子, 策略未定义时跳过
+func (w *Worker) callCheckExit(pos Position) error {
+	fn := w.luaState.GetGlobal("check_exit")
+	if fn.Type() != lua.LTFunction {
+		return nil
+	}
+
+	freq := types.FREQ_DAILY_HFQ
+	if w.options.Backtest != nil {
+		freq = w.options.Backtest.Freq
+	}
+	data, err := w.fetchKLineData(pos.Code, freq)
+	if err != nil {
+		return NewAPIRequestError("getKLineData", err)
+	}
+	data = truncateKLine(data, w.asOf)
+
+	// 构建持仓表
+	L := w.luaState
+	posTable := L.NewTable()
+	L.SetField(posTable, "code", lua.LString(pos.Code))
+	L.SetField(posTable, "name", lua.LString(pos.Name))
+	L.SetField(posTable, "entryDate", lua.LString(pos.EntryDate))
+	L.SetField(posTable, "entryPrice", lua.LNumber(pos.EntryPrice))
+	holdingDays := 0
+	for i := len(data) - 1; i >= 0 && barDate(data[i].Time) > pos.EntryDate; i-- {
+		holdingDays++
+	}
+	L.SetField(posTable, "holdingDays", lua.LNumber(holdingDays))
+	if len(data) > 0 {
+		lastPrice := data[len(data)-1].Close
+		L.SetField(posTable, "lastPrice", lua.LNumber(lastPrice))
+		if pos.EntryPrice > 0 {
+			L.SetField(posTable, "profit", lua.LNumber((lastPrice/pos.EntryPrice-1)*100))
+		}
+	}
+
+	return w.callLua(fn, 0, posTable, klineTable(L, data))
+}
+
+// emit 发送信号并记录本次调用发出的信号
+func (w *Worker) emit(signal StockSignal) {
+	w.emitted = append(w.emitted, signal)
+	w.statusUpdater.AddSignal(signal)
+}
+
+// emittedType 查找本次调用中发出的指定股票、指定类型的信号
+func (w *Worker) emittedType(code, signalType string) *StockSignal {
+	for i := range w.emitted {
+		if w.emitted[i].Code == code && w.emitted[i].Type == signalType {
+			return &w.emitted[i]
+		}
+	}
 	return nil
 }
 
+// fetchKLineData 获取K线数据, 回测期间同一股票同一周期只获取一次
+func (w *Worker) fetchKLineData(code string, freq types.KLineFreq) ([]types.KLineData, error) {
+	if w.options.KLineCache != nil {
+		return w.options.KLineCache.Get(context.Background(), w.apiClient, code, freq)
+	}
+
+	key := code + "|" + string(freq)
+	if data, ok := w.klineMemo[key]; ok {
+		return data, nil
+	}
+
+	data, err := w.apiClient.Market.GetKLineData(context.Background(), code, freq)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.klineMemo != nil {
+		w.klineMemo[key] = data
+	}
+	return data, nil
+}
+
+// truncateKLine 截取指定日期(含)之前的K线, 数据需按时间升序排列
+func truncateKLine(data []types.KLineData, asOf string) []types.KLineData {
+	if asOf == "" {
+		return data
+	}
+	idx := sort.Search(len(data), func(i int) bool {
+		return barDate(data[i].Time) > asOf
+	})
+	return data[:idx]
+}
+
+// tradingDates 获取区间内(含首尾)的交易日列表
+func tradingDates(data []types.KLineData, startDate, endDate string) []string {
+	dates := make([]string, 0)
+	for _, item := range data {
+		date := barDate(item.Time)
+		if date < startDate || date > endDate {
+			continue
+		}
+		if len(dates) > 0 && dates[len(dates)-1] == date {
+			continue
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// barDate 获取K线的日期部分(yyyy-MM-dd)
+func barDate(t string) string {
+	if len(t) > 10 {
+		return t[:10]
+	}
+	return t
+}
+
 // registerLuaFunctions 注册Lua函数
 func (w *Worker) registerLuaFunctions() error {
 	// 注册全局函数
 	w.registerGlobalFunctions()
 
 	// 注册API函数
-	w.registerAPIFunctions()
-
-	return nil
+	return w.registerAPIFunctions()
 }
 
 // registerGlobalFunctions 注册全局函数
@@ -107,7 +419,7 @@ func (w *Worker) registerGlobalFunctions() {
 }
 
 // registerAPIFunctions 注册API函数
-func (w *Worker) registerAPIFunctions() {
+func (w *Worker) registerAPIFunctions() error {
 	// 创建API表
 	apiTable := w.luaState.NewTable()
 
@@ -117,29 +429,39 @@ func (w *Worker) registerAPIFunctions() {
 	// 注册获取K线数据函数
 	w.luaState.SetField(apiTable, "getKLineData", w.luaState.NewFunction(w.luaGetKLineData))
 
+	// 注册获取多周期对齐K线数据函数
+	w.luaState.SetField(apiTable, "getAlignedKLineData", w.luaState.NewFunction(w.luaGetAlignedKLineData))
+
 	// 注册发送股票信号函数
 	w.luaState.SetField(apiTable, "sendSignal", w.luaState.NewFunction(w.luaSendStockSignal))
 
-	// 注册更新进度函数
-	w.luaState.SetField(apiTable, "updateProgress", w.luaState.NewFunction(w.luaUpdateProgress))
+	// 注册发送结构化信号函数
+	w.luaState.SetField(apiTable, "emit", w.luaState.NewFunction(w.luaEmit))
 
-	// 注册技术指标相关函数
-	indicatorTable := w.luaState.NewTable()
+	// 注册发送卖出信号函数
+	w.luaState.SetField(apiTable, "sendExitSignal", w.luaState.NewFunction(w.luaSendExitSignal))
 
-	// 注册RSI函数
-	w.luaState.SetField(indicatorTable, "calculateRSI", w.luaState.NewFunction(w.luaCalculateRSI))
-
-	// 注册MACD函数
-	w.luaState.SetField(indicatorTable, "calculateMACD", w.luaState.NewFunction(w.luaCalculateMACD))
+	// 注册更新进度函数
+	w.luaState.SetField(apiTable, "updateProgress", w.luaState.NewFunction(w.luaUpdateProgress))
 
-	// 注册MA函数
-	w.luaState.SetField(indicatorTable, "calculateMA", w.luaState.NewFunction(w.luaCalculateMA))
+	// 注册技术指标相关函数(由indicatorBindings登记表生成)
+	indicatorTable, err := newIndicatorTable(w.luaState)
+	if err != nil {
+		return err
+	}
 
 	// 将indicator表设置为api表的一个字段
 	w.luaState.SetField(apiTable, "indicator", indicatorTable)
 
+	// 注册行情、公司和财务数据接口(由dataBindings登记表生成)
+	if err := w.registerDataTables(apiTable); err != nil {
+		return err
+	}
+
 	// 将API表设置为全局变量
 	w.luaState.SetGlobal("api", apiTable)
+
+	return nil
 }
 
 // luaGetIndexList 获取指数列表的Lua包装函数
@@ -178,7 +500,7 @@ func (w *Worker) luaGetKLineData(L *lua.LState) int {
 		return 2
 	}
 
-	data, err := w.apiClient.Market.GetKLineData(context.Background(), code, types.KLineFreq(freq))
+	data, err := w.fetchKLineData(code, types.KLineFreq(freq))
 	if err != nil {
 		luaErr := NewAPIRequestError("getKLineData", err)
 		L.Push(lua.LNil)
@@ -186,7 +508,15 @@ func (w *Worker) luaGetKLineData(L *lua.LState) int {
 		return 2
 	}
 
-	// 创建返回表
+	// 回测模式下只暴露模拟交易日(含)之前的数据
+	data = truncateKLine(data, w.asOf)
+
+	L.Push(klineTable(L, data))
+	return 1
+}
+
+// klineTable 将K线数据转换为Lua表
+func klineTable(L *lua.LState, data []types.KLineData) *lua.LTable {
 	dataTable := L.NewTable()
 	for _, item := range data {
 		itemTable := L.NewTable()
@@ -203,9 +533,7 @@ func (w *Worker) luaGetKLineData(L *lua.LState) int {
 		L.SetField(itemTable, "changeAmount", lua.LNumber(item.ChangeAmt))
 		dataTable.Append(itemTable)
 	}
-
-	L.Push(dataTable)
-	return 1
+	return dataTable
 }
 
 // luaSendStockSignal 发送股票信号的Lua包装函数
@@ -224,131 +552,92 @@ func (w *Worker) luaSendStockSignal(L *lua.LState) int {
 		Turnover: turnover,
 		Change:   change,
 		Reason:   reason,
+		Date:     w.asOf,
+		Type:     SignalTypeEntry,
 	}
 
-	w.statusUpdater.AddSignal(signal)
+	w.emit(signal)
 	return 0
 }
 
-// luaUpdateProgress 更新进度的Lua包装函数
-func (w *Worker) luaUpdateProgress(L *lua.LState) int {
-	currentStock := L.ToString(1)
-	processedStocks := L.ToInt(2)
-
-	w.statusUpdater.UpdateProgress(processedStocks, currentStock)
-	L.Push(lua.LBool(true))
-	return 1
+// luaSendExitSignal 发送卖出信号的Lua包装函数: api.sendExitSignal(code, price, reason)
+func (w *Worker) luaSendExitSignal(L *lua.LState) int {
+	code := L.ToString(1)
+	price := float64(L.ToNumber(2))
+	reason := L.ToString(3)
+
+	name := ""
+	if pos, ok := w.positions[code]; ok {
+		name = pos.Name
+	} else if code == w.current.Code {
+		name = w.current.Name
+	}
+
+	w.emit(StockSignal{
+		Code:   code,
+		Name:   name,
+		Price:  price,
+		Reason: reason,
+		Date:   w.asOf,
+		Type:   SignalTypeExit,
+	})
+	return 0
 }
 
-// luaCalculateRSI RSI指标的Lua包装函数
-func (w *Worker) luaCalculateRSI(L *lua.LState) int {
-	// 检查参数
-	prices := luaTableToFloat64Slice(L.CheckTable(1))
-	period := L.CheckInt(2)
+// luaEmit 发送结构化信号的Lua包装函数
+// api.emit{code=..., name=..., price=..., turnover=..., change=..., reason=..., score=..., tags={...}, fields={...}, type="entry"|"exit"}
+// code和name缺省时取当前处理的股票
+func (w *Worker) luaEmit(L *lua.LState) int {
+	tbl := L.CheckTable(1)
 
-	// 计算RSI
-	rsi, err := indicators.CalculateRSI(prices, period)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
+	signal := StockSignal{
+		Code:     lua.LVAsString(tbl.RawGetString("code")),
+		Name:     lua.LVAsString(tbl.RawGetString("name")),
+		Price:    float64(lua.LVAsNumber(tbl.RawGetString("price"))),
+		Turnover: float64(lua.LVAsNumber(tbl.RawGetString("turnover"))),
+		Change:   float64(lua.LVAsNumber(tbl.RawGetString("change"))),
+		Reason:   lua.LVAsString(tbl.RawGetString("reason")),
+		Score:    float64(lua.LVAsNumber(tbl.RawGetString("score"))),
+		Tags:     luaStringSlice(tbl.RawGetString("tags")),
+		Date:     w.asOf,
+		Type:     SignalTypeEntry,
 	}
 
-	// 创建返回表
-	rsiTable := L.NewTable()
-	for i, v := range rsi {
-		rsiTable.RawSetInt(i+1, lua.LNumber(v))
+	if signal.Code == "" {
+		signal.Code = w.current.Code
 	}
-
-	L.Push(rsiTable)
-	L.Push(lua.LNil)
-	return 2
-}
-
-// luaCalculateMACD MACD指标的Lua包装函数
-func (w *Worker) luaCalculateMACD(L *lua.LState) int {
-	// 检查参数
-	prices := luaTableToFloat64Slice(L.CheckTable(1))
-	shortPeriod := L.CheckInt(2)
-	longPeriod := L.CheckInt(3)
-	signalPeriod := L.CheckInt(4)
-
-	// 计算MACD
-	result, err := indicators.CalculateMACD(prices, shortPeriod, longPeriod, signalPeriod)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
+	if signal.Name == "" && signal.Code == w.current.Code {
+		signal.Name = w.current.Name
 	}
-
-	// 创建返回表
-	macdTable := L.NewTable()
-
-	// 设置DIF数组
-	difTable := L.NewTable()
-	for i, v := range result.DIF {
-		difTable.RawSetInt(i+1, lua.LNumber(v))
+	if lua.LVAsString(tbl.RawGetString("type")) == SignalTypeExit {
+		signal.Type = SignalTypeExit
 	}
-	L.SetField(macdTable, "dif", difTable)
-
-	// 设置DEA数组
-	deaTable := L.NewTable()
-	for i, v := range result.DEA {
-		deaTable.RawSetInt(i+1, lua.LNumber(v))
+	if fields, ok := luaToGo(tbl.RawGetString("fields")).(map[string]interface{}); ok {
+		signal.Fields = fields
 	}
-	L.SetField(macdTable, "dea", deaTable)
 
-	// 设置MACD数组
-	macdArrayTable := L.NewTable()
-	for i, v := range result.MACD {
-		macdArrayTable.RawSetInt(i+1, lua.LNumber(v))
+	if signal.Code == "" {
+		L.ArgError(1, "code is required")
+		return 0
 	}
-	L.SetField(macdTable, "macd", macdArrayTable)
 
-	L.Push(macdTable)
-	L.Push(lua.LNil)
-	return 2
+	w.emit(signal)
+	return 0
 }
 
-// luaCalculateMA MA指标的Lua包装函数
-func (w *Worker) luaCalculateMA(L *lua.LState) int {
-	// 检查参数
-	prices := luaTableToFloat64Slice(L.CheckTable(1))
-	maType := indicators.MAType(L.CheckString(2))
-	period := L.CheckInt(3)
-
-	// 计算MA
-	result, err := indicators.CalculateMA(prices, maType, period)
-	if err != nil {
-		L.Push(lua.LNil)
-		L.Push(lua.LString(err.Error()))
-		return 2
-	}
-
-	// 创建返回表
-	maTable := L.NewTable()
-	for i, v := range result {
-		maTable.RawSetInt(i+1, lua.LNumber(v))
-	}
-
-	L.Push(maTable)
-	L.Push(lua.LNil)
-	return 2
-}
+// luaUpdateProgress 更新进度的Lua包装函数
+func (w *Worker) luaUpdateProgress(L *lua.LState) int {
+	currentStock := L.ToString(1)
+	processedStocks := L.ToInt(2)
 
-// luaTableToFloat64Slice 辅助函数：将Lua表转换为float64切片
-func luaTableToFloat64Slice(table *lua.LTable) []float64 {
-	result := make([]float64, 0, table.Len())
-	table.ForEach(func(_ lua.LValue, value lua.LValue) {
-		if num, ok := value.(lua.LNumber); ok {
-			result = append(result, float64(num))
-		}
-	})
-	return result
+	w.statusUpdater.UpdateProgress(processedStocks, currentStock)
+	L.Push(lua.LBool(true))
+	return 1
 }
 
 // Close 关闭工作单元
 func (w *Worker) Close() {
+	w.closeComponents()
 	if w.luaState != nil {
 		w.luaState.Close()
 	}
@@ -358,10 +647,12 @@ func (w *Worker) Close() {
 type WorkerPool struct {
 	workers       []*Worker
 	stockChan     chan types.Index
-	errorChan     chan error
+	failures      *failureCollector // 处理失败的股票
+	checkpoints   *checkpointer     // 运行进度, 为空表示不记录
 	metrics       *ExecutionMetrics
 	apiClient     *api.Client
 	statusUpdater StatusUpdater
+	scores        *scoreBoard // 两阶段排序策略的评分
 	wg            sync.WaitGroup
 	taskWg        sync.WaitGroup // 用于等待所有任务完成
 	ctx           context.Context
@@ -372,7 +663,7 @@ type WorkerPool struct {
 }
 
 // NewWorkerPool 创建新的工作池
-func NewWorkerPool(size int, strategy *Strategy, metrics *ExecutionMetrics, ctx context.Context, apiClient *api.Client, statusUpdater StatusUpdater) (*WorkerPool, error) {
+func NewWorkerPool(size int, strategy *Strategy, metrics *ExecutionMetrics, ctx context.Context, apiClient *api.Client, statusUpdater StatusUpdater, options RunOptions) (*WorkerPool, error) {
 	if size <= 0 {
 		return nil, NewInvalidConfigError("WorkerPoolSize", fmt.Errorf("worker pool size must be positive"))
 	}
@@ -398,21 +689,23 @@ func NewWorkerPool(size int, strategy *Strategy, metrics *ExecutionMetrics, ctx
 	pool := &WorkerPool{
 		workers:       make([]*Worker, size),
 		stockChan:     make(chan types.Index, size*2),
-		errorChan:     make(chan error, size),
+		failures:      newFailureCollector(),
 		metrics:       metrics,
 		apiClient:     apiClient,
 		statusUpdater: statusUpdater,
+		scores:        &scoreBoard{},
 		ctx:           poolCtx,
 		cancel:        cancel,
 	}
 
 	// 创建工作单元
 	for i := 0; i < size; i++ {
-		worker, err := NewWorker(i, strategy, metrics, poolCtx, apiClient, statusUpdater)
+		worker, err := NewWorker(i, strategy, metrics, poolCtx, apiClient, statusUpdater, options)
 		if err != nil {
 			pool.Close()
 			return nil, ErrWorkerPoolFailed(fmt.Errorf("failed to create worker %d: %v", i, err))
 		}
+		worker.scores = pool.scores
 		pool.workers[i] = worker
 	}
 
@@ -443,25 +736,28 @@ func (p *WorkerPool) Submit(stock types.Index) {
 	}
 }
 
-// Start 启动工作池
+// Start 启动工作池, 运行被取消后只取出剩余的股票不再处理, 以便Wait返回
 func (p *WorkerPool) Start(ctx context.Context) {
 	for _, worker := range p.workers {
 		p.wg.Add(1)
 		go func(w *Worker) {
 			defer p.wg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case stock, ok := <-p.stockChan:
-					if !ok {
-						return
+			for stock := range p.stockChan {
+				if ctx.Err() != nil || p.ctx.Err() != nil {
+					p.taskWg.Done()
+					continue
+				}
+				// 运行被取消时未处理完的股票既不算失败也不算已处理
+				err := w.ProcessStock(stock)
+				if p.ctx.Err() == nil {
+					if err != nil {
+						p.failures.add(stock, err)
 					}
-					if err := w.ProcessStock(stock); err != nil {
-						p.errorChan <- err
+					if p.checkpoints != nil {
+						p.checkpoints.done(stock, w.emitted)
 					}
-					p.taskWg.Done()
 				}
+				p.taskWg.Done()
 			}
 		}(worker)
 	}
@@ -478,17 +774,9 @@ func (p *WorkerPool) Wait() {
 	p.wg.Wait() // 等待所有工作协程退出
 }
 
-// DrainErrors 清空错误通道
-func (p *WorkerPool) DrainErrors() []error {
-	var errors []error
-	for {
-		select {
-		case err := <-p.errorChan:
-			errors = append(errors, err)
-		default:
-			return errors
-		}
-	}
+// Failures 获取处理失败的股票汇总
+func (p *WorkerPool) Failures() FailureReport {
+	return p.failures.report()
 }
 
 // Close 关闭工作池
@@ -515,12 +803,4 @@ func (p *WorkerPool) Close() {
 			worker.Close()
 		}
 	}
-
-	// 关闭错误通道
-	p.closeMutex.Lock()
-	if p.errorChan != nil {
-		close(p.errorChan)
-		p.errorChan = nil
-	}
-	p.closeMutex.Unlock()
 }
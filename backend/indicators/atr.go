@@ -0,0 +1,47 @@
+package indicators
+
+import "math"
+
+// CalculateATR 计算平均真实波幅(ATR)
+// series: K线序列
+// period: 计算周期(默认14)
+// 前period-1个位置为已有真实波幅的平均值, 之后使用Wilder平滑
+func CalculateATR(series *OHLCV, period int) ([]float64, error) {
+	size := series.Len()
+	if size == 0 {
+		return make([]float64, 0), nil
+	}
+
+	// 使用默认参数
+	if period <= 0 {
+		period = 14
+	}
+
+	tr := trueRange(series)
+	atr := make([]float64, size)
+	var sum float64
+	for i := 0; i < size; i++ {
+		if i < period {
+			sum += tr[i]
+			atr[i] = sum / float64(i+1)
+			continue
+		}
+		atr[i] = (atr[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+
+	return atr, nil
+}
+
+// trueRange 计算真实波幅, 首根K线为最高价与最低价之差
+func trueRange(series *OHLCV) []float64 {
+	size := series.Len()
+	tr := make([]float64, size)
+	for i := 0; i < size; i++ {
+		tr[i] = series.High[i] - series.Low[i]
+		if i > 0 {
+			prevClose := series.Close[i-1]
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(series.High[i]-prevClose), math.Abs(series.Low[i]-prevClose)))
+		}
+	}
+	return tr
+}
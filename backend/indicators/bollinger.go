@@ -0,0 +1,61 @@
+package indicators
+
+import "math"
+
+// BollingerResult 布林带计算结果
+type BollingerResult struct {
+	Upper  []float64 // 上轨
+	Middle []float64 // 中轨
+	Lower  []float64 // 下轨
+	Width  []float64 // 带宽 (上轨-下轨)/中轨
+}
+
+// CalculateBollinger 计算布林带
+// prices: 收盘价数组
+// period: 计算周期(默认20)
+// multiplier: 标准差倍数(默认2)
+// 前period-1个位置数据不足, 结果为0
+func CalculateBollinger(prices []float64, period int, multiplier float64) (*BollingerResult, error) {
+	size := len(prices)
+	result := &BollingerResult{
+		Upper:  make([]float64, size),
+		Middle: make([]float64, size),
+		Lower:  make([]float64, size),
+		Width:  make([]float64, size),
+	}
+	if size == 0 {
+		return result, nil
+	}
+
+	// 使用默认参数
+	if period <= 0 {
+		period = 20
+	}
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	for i := period - 1; i < size; i++ {
+		var sum float64
+		for j := i - period + 1; j <= i; j++ {
+			sum += validOrZero(prices[j])
+		}
+		mean := sum / float64(period)
+
+		var variance float64
+		for j := i - period + 1; j <= i; j++ {
+			diff := validOrZero(prices[j]) - mean
+			variance += diff * diff
+		}
+		std := math.Sqrt(variance / float64(period))
+
+		result.Middle[i] = mean
+		result.Upper[i] = mean + multiplier*std
+		result.Lower[i] = mean - multiplier*std
+		if mean != 0 {
+			result.Width[i] = (result.Upper[i] - result.Lower[i]) / mean
+		}
+	}
+
+	return result, nil
+}
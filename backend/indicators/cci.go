@@ -0,0 +1,41 @@
+package indicators
+
+import "math"
+
+// CalculateCCI 计算顺势指标(CCI)
+// series: K线序列
+// period: 计算周期(默认14)
+// 前period-1个位置数据不足, 结果为0
+func CalculateCCI(series *OHLCV, period int) ([]float64, error) {
+	size := series.Len()
+	if size == 0 {
+		return make([]float64, 0), nil
+	}
+
+	// 使用默认参数
+	if period <= 0 {
+		period = 14
+	}
+
+	typical := series.TypicalPrice()
+	cci := make([]float64, size)
+	for i := period - 1; i < size; i++ {
+		var sum float64
+		for j := i - period + 1; j <= i; j++ {
+			sum += typical[j]
+		}
+		mean := sum / float64(period)
+
+		var deviation float64
+		for j := i - period + 1; j <= i; j++ {
+			deviation += math.Abs(typical[j] - mean)
+		}
+		deviation /= float64(period)
+
+		if deviation > 0 {
+			cci[i] = (typical[i] - mean) / (0.015 * deviation)
+		}
+	}
+
+	return cci, nil
+}
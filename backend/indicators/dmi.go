@@ -0,0 +1,92 @@
+package indicators
+
+import "math"
+
+// DMIResult DMI 计算结果
+type DMIResult struct {
+	PDI  []float64 // 上升方向线(+DI)
+	MDI  []float64 // 下降方向线(-DI)
+	ADX  []float64 // 平均趋向指数
+	ADXR []float64 // 平均趋向指数评估值
+}
+
+// CalculateDMI 计算趋向指标(DMI/ADX), 使用Wilder平滑
+// series: K线序列
+// period: 方向线周期(默认14)
+// adxPeriod: ADX平滑周期(默认与period相同)
+// 数据不足的位置为0
+func CalculateDMI(series *OHLCV, period, adxPeriod int) (*DMIResult, error) {
+	size := series.Len()
+	result := &DMIResult{
+		PDI:  make([]float64, size),
+		MDI:  make([]float64, size),
+		ADX:  make([]float64, size),
+		ADXR: make([]float64, size),
+	}
+	if size == 0 {
+		return result, nil
+	}
+
+	// 使用默认参数
+	if period <= 0 {
+		period = 14
+	}
+	if adxPeriod <= 0 {
+		adxPeriod = period
+	}
+
+	tr := trueRange(series)
+	var smoothTR, smoothPDM, smoothMDM float64
+	dx := make([]float64, size)
+	for i := 1; i < size; i++ {
+		upMove := series.High[i] - series.High[i-1]
+		downMove := series.Low[i-1] - series.Low[i]
+		var pdm, mdm float64
+		if upMove > downMove && upMove > 0 {
+			pdm = upMove
+		}
+		if downMove > upMove && downMove > 0 {
+			mdm = downMove
+		}
+
+		if i <= period {
+			// 首个周期内累加
+			smoothTR += tr[i]
+			smoothPDM += pdm
+			smoothMDM += mdm
+			if i < period {
+				continue
+			}
+		} else {
+			smoothTR = smoothTR - smoothTR/float64(period) + tr[i]
+			smoothPDM = smoothPDM - smoothPDM/float64(period) + pdm
+			smoothMDM = smoothMDM - smoothMDM/float64(period) + mdm
+		}
+
+		if smoothTR > 0 {
+			result.PDI[i] = smoothPDM / smoothTR * 100
+			result.MDI[i] = smoothMDM / smoothTR * 100
+		}
+		if sum := result.PDI[i] + result.MDI[i]; sum > 0 {
+			dx[i] = math.Abs(result.PDI[i]-result.MDI[i]) / sum * 100
+		}
+	}
+
+	// ADX为DX的Wilder平滑, 首个值为period起adxPeriod个DX的平均
+	first := period + adxPeriod - 1
+	if first < size {
+		var sum float64
+		for i := period; i <= first; i++ {
+			sum += dx[i]
+		}
+		result.ADX[first] = sum / float64(adxPeriod)
+		for i := first + 1; i < size; i++ {
+			result.ADX[i] = (result.ADX[i-1]*float64(adxPeriod-1) + dx[i]) / float64(adxPeriod)
+		}
+		for i := first + adxPeriod; i < size; i++ {
+			result.ADXR[i] = (result.ADX[i] + result.ADX[i-adxPeriod]) / 2
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,20 @@
+package indicators
+
+// CalculateOBV 计算能量潮(OBV)
+// series: K线序列
+// 收盘价上涨累加成交量, 下跌累减成交量, 首根K线为0
+func CalculateOBV(series *OHLCV) ([]float64, error) {
+	size := series.Len()
+	obv := make([]float64, size)
+	for i := 1; i < size; i++ {
+		switch {
+		case series.Close[i] > series.Close[i-1]:
+			obv[i] = obv[i-1] + series.Volume[i]
+		case series.Close[i] < series.Close[i-1]:
+			obv[i] = obv[i-1] - series.Volume[i]
+		default:
+			obv[i] = obv[i-1]
+		}
+	}
+	return obv, nil
+}
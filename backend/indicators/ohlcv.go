@@ -0,0 +1,131 @@
+package indicators
+
+import "stock-helper-svelte/backend/api/types"
+
+// OHLCV K线序列, 各字段按时间升序排列且长度相同
+type OHLCV struct {
+	Time   []string  // 交易时间
+	Open   []float64 // 开盘价
+	High   []float64 // 最高价
+	Low    []float64 // 最低价
+	Close  []float64 // 收盘价
+	Volume []float64 // 成交量(手)
+	Amount []float64 // 成交额(元)
+}
+
+// NewOHLCV 从K线数据构建序列, 无效值替换为0
+func NewOHLCV(data []types.KLineData) *OHLCV {
+	size := len(data)
+	series := &OHLCV{
+		Time:   make([]string, size),
+		Open:   make([]float64, size),
+		High:   make([]float64, size),
+		Low:    make([]float64, size),
+		Close:  make([]float64, size),
+		Volume: make([]float64, size),
+		Amount: make([]float64, size),
+	}
+
+	for i, item := range data {
+		series.Time[i] = item.Time
+		series.Open[i] = validOrZero(item.Open)
+		series.High[i] = validOrZero(item.High)
+		series.Low[i] = validOrZero(item.Low)
+		series.Close[i] = validOrZero(item.Close)
+		series.Volume[i] = validOrZero(item.Volume)
+		series.Amount[i] = validOrZero(item.Amount)
+	}
+
+	return series
+}
+
+// Len 序列长度
+func (s *OHLCV) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.Close)
+}
+
+// TypicalPrice 典型价格 (最高价+最低价+收盘价)/3
+func (s *OHLCV) TypicalPrice() []float64 {
+	result := make([]float64, s.Len())
+	for i := range result {
+		result[i] = (s.High[i] + s.Low[i] + s.Close[i]) / 3
+	}
+	return result
+}
+
+// highestLowest 计算以i结尾的period根K线内的最高价和最低价
+func (s *OHLCV) highestLowest(i, period int) (float64, float64) {
+	start := i - period + 1
+	if start < 0 {
+		start = 0
+	}
+	high, low := s.High[start], s.Low[start]
+	for j := start + 1; j <= i; j++ {
+		if s.High[j] > high {
+			high = s.High[j]
+		}
+		if s.Low[j] < low {
+			low = s.Low[j]
+		}
+	}
+	return high, low
+}
+
+// validOrZero 无效值替换为0
+func validOrZero(value float64) float64 {
+	if !IsValidFloat(value) || value != value {
+		return 0
+	}
+	return value
+}
+
+// CalculateKDJOHLCV 按最高价和最低价计算KDJ指标
+// series: K线序列
+// n: RSV周期（默认9）
+// m1: K值平滑系数（默认3）
+// m2: D值平滑系数（默认3）
+func CalculateKDJOHLCV(series *OHLCV, n, m1, m2 int) (*KDJResult, error) {
+	size := series.Len()
+	if size == 0 {
+		return &KDJResult{
+			K: make([]float64, 0),
+			D: make([]float64, 0),
+			J: make([]float64, 0),
+		}, nil
+	}
+
+	// 使用默认参数
+	if n <= 0 {
+		n = 9
+	}
+	if m1 <= 0 {
+		m1 = 3
+	}
+	if m2 <= 0 {
+		m2 = 3
+	}
+
+	k := make([]float64, size)
+	d := make([]float64, size)
+	j := make([]float64, size)
+
+	prevK, prevD := 50.0, 50.0
+	for i := 0; i < size; i++ {
+		// 计算RSV, 数据不足n天时使用已有数据
+		rsv := 50.0
+		high, low := series.highestLowest(i, n)
+		if high > low {
+			rsv = (series.Close[i] - low) / (high - low) * 100
+		}
+
+		k[i] = (float64(m1-1)*prevK + rsv) / float64(m1)
+		d[i] = (float64(m2-1)*prevD + k[i]) / float64(m2)
+		j[i] = 3*k[i] - 2*d[i]
+		prevK, prevD = k[i], d[i]
+	}
+
+	return &KDJResult{K: k, D: d, J: j}, nil
+}
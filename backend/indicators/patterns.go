@@ -0,0 +1,262 @@
+package indicators
+
+import (
+	"math"
+	"strings"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// K线形态名称, 与CandlePattern中的字段在Lua中的名称一致
+const (
+	PatternDoji               = "doji"               // 十字星
+	PatternHammer             = "hammer"             // 锤子线(下跌后)
+	PatternHangingMan         = "hangingMan"         // 上吊线(上涨后)
+	PatternBullishEngulfing   = "bullishEngulfing"   // 看涨吞没
+	PatternBearishEngulfing   = "bearishEngulfing"   // 看跌吞没
+	PatternBullishHarami      = "bullishHarami"      // 看涨孕线
+	PatternBearishHarami      = "bearishHarami"      // 看跌孕线
+	PatternMorningStar        = "morningStar"        // 早晨之星
+	PatternEveningStar        = "eveningStar"        // 黄昏之星
+	PatternThreeWhiteSoldiers = "threeWhiteSoldiers" // 红三兵
+	PatternGapUp              = "gapUp"              // 向上跳空缺口
+	PatternGapDown            = "gapDown"            // 向下跳空缺口
+	PatternLimitUp            = "limitUp"            // 涨停板
+)
+
+// PatternOptions 形态识别参数, 零值字段使用默认参数
+type PatternOptions struct {
+	DojiThreshold float64 // 十字星实体占价格的最大比例(默认0.008)
+	LimitUpRate   float64 // 涨停幅度(默认0.098), 可由LimitUpRate(code)获取
+	TrendPeriod   int     // 判断前期趋势的K线数(默认5)
+}
+
+// CandlePattern 单根K线的形态识别结果
+// 各形态字段为形态强度, 取值范围(0, 1], 0表示未出现该形态
+type CandlePattern struct {
+	Time               string   // 交易时间
+	Doji               float64  // 十字星
+	Hammer             float64  // 锤子线
+	HangingMan         float64  // 上吊线
+	BullishEngulfing   float64  // 看涨吞没
+	BearishEngulfing   float64  // 看跌吞没
+	BullishHarami      float64  // 看涨孕线
+	BearishHarami      float64  // 看跌孕线
+	MorningStar        float64  // 早晨之星
+	EveningStar        float64  // 黄昏之星
+	ThreeWhiteSoldiers float64  // 红三兵
+	GapUp              float64  // 向上跳空缺口
+	GapDown            float64  // 向下跳空缺口
+	LimitUp            float64  // 涨停板
+	Patterns           []string // 出现的形态名称
+}
+
+// mark 记录出现的形态
+func (p *CandlePattern) mark(name string, field *float64, strength float64) {
+	*field = Clamp(strength, 0.01, 1)
+	p.Patterns = append(p.Patterns, name)
+}
+
+// LimitUpRate 根据股票代码获取涨停幅度: 科创板和创业板20%, 北交所30%, 其余10%(均留有舍入余量)
+func LimitUpRate(code string) float64 {
+	code = strings.ToLower(code)
+	for _, prefix := range []string{"sh", "sz", "bj"} {
+		code = strings.TrimPrefix(code, prefix)
+	}
+	switch {
+	case strings.HasPrefix(code, "688"), strings.HasPrefix(code, "300"), strings.HasPrefix(code, "301"):
+		return 0.198
+	case strings.HasPrefix(code, "8"), strings.HasPrefix(code, "4"), strings.HasPrefix(code, "92"):
+		return 0.298
+	default:
+		return 0.098
+	}
+}
+
+// DetectPatterns 识别K线形态
+// data: K线数据(按时间升序)
+// options: 识别参数, 为nil时使用默认参数
+// 返回值与data一一对应
+func DetectPatterns(data []types.KLineData, options *PatternOptions) ([]CandlePattern, error) {
+	opts := PatternOptions{}
+	if options != nil {
+		opts = *options
+	}
+	// 使用默认参数
+	if opts.DojiThreshold <= 0 {
+		opts.DojiThreshold = 0.008
+	}
+	if opts.LimitUpRate <= 0 {
+		opts.LimitUpRate = 0.098
+	}
+	if opts.TrendPeriod <= 0 {
+		opts.TrendPeriod = 5
+	}
+
+	series := NewOHLCV(data)
+	result := make([]CandlePattern, series.Len())
+	for i := range result {
+		result[i].Time = series.Time[i]
+		detectSingle(series, i, opts, &result[i])
+		if i >= 1 {
+			detectDouble(series, i, &result[i])
+		}
+		if i >= 2 {
+			detectTriple(series, i, &result[i])
+		}
+		if result[i].Patterns == nil {
+			result[i].Patterns = make([]string, 0)
+		}
+	}
+
+	return result, nil
+}
+
+// candle 单根K线的实体和影线
+type candle struct {
+	open, high, low, close  float64
+	body, rng, upper, lower float64
+}
+
+func candleAt(s *OHLCV, i int) candle {
+	c := candle{open: s.Open[i], high: s.High[i], low: s.Low[i], close: s.Close[i]}
+	c.body = math.Abs(c.close - c.open)
+	c.rng = c.high - c.low
+	c.upper = c.high - math.Max(c.open, c.close)
+	c.lower = math.Min(c.open, c.close) - c.low
+	return c
+}
+
+func (c candle) bullish() bool { return c.close > c.open }
+func (c candle) bearish() bool { return c.close < c.open }
+
+// bodyTop 实体上沿
+func (c candle) bodyTop() float64 { return math.Max(c.open, c.close) }
+
+// bodyBottom 实体下沿
+func (c candle) bodyBottom() float64 { return math.Min(c.open, c.close) }
+
+// priorTrend 第i根K线之前period根K线的涨跌幅
+func priorTrend(s *OHLCV, i, period int) float64 {
+	start := i - 1 - period
+	if start < 0 || s.Close[start] <= 0 {
+		return 0
+	}
+	return (s.Close[i-1] - s.Close[start]) / s.Close[start]
+}
+
+// detectSingle 识别单根K线形态及与前一根K线之间的缺口和涨停
+func detectSingle(s *OHLCV, i int, opts PatternOptions, p *CandlePattern) {
+	c := candleAt(s, i)
+
+	if i > 0 {
+		// 跳空缺口
+		prevHigh, prevLow := s.High[i-1], s.Low[i-1]
+		if prevHigh > 0 && c.low > prevHigh {
+			p.mark(PatternGapUp, &p.GapUp, 0.5+0.5*Clamp((c.low-prevHigh)/prevHigh/0.03, 0, 1))
+		}
+		if prevLow > 0 && c.high < prevLow {
+			p.mark(PatternGapDown, &p.GapDown, 0.5+0.5*Clamp((prevLow-c.high)/prevLow/0.03, 0, 1))
+		}
+
+		// 涨停板: 收盘涨幅达到涨停幅度, 一字板强度最高
+		prevClose := s.Close[i-1]
+		if prevClose > 0 && (c.close-prevClose)/prevClose >= opts.LimitUpRate {
+			strength := 0.8
+			if c.low >= c.close {
+				strength = 1
+			}
+			p.mark(PatternLimitUp, &p.LimitUp, strength)
+		}
+	}
+
+	if c.rng <= 0 {
+		return
+	}
+
+	// 十字星: 实体相对价格和振幅都很小
+	price := (c.open + c.close) / 2
+	bodyRatio := c.body / c.rng
+	if price > 0 && c.body/price <= opts.DojiThreshold && bodyRatio <= 0.3 {
+		p.mark(PatternDoji, &p.Doji, 1-bodyRatio/0.3)
+	}
+
+	// 锤子线/上吊线: 长下影线, 几乎没有上影线, 按前期趋势区分
+	if c.lower >= 2*c.body && c.lower >= 0.6*c.rng && c.upper <= 0.1*c.rng {
+		strength := c.lower / c.rng
+		switch trend := priorTrend(s, i, opts.TrendPeriod); {
+		case trend < 0:
+			p.mark(PatternHammer, &p.Hammer, strength)
+		case trend > 0:
+			p.mark(PatternHangingMan, &p.HangingMan, strength)
+		}
+	}
+}
+
+// detectDouble 识别两根K线组合形态
+func detectDouble(s *OHLCV, i int, p *CandlePattern) {
+	prev, cur := candleAt(s, i-1), candleAt(s, i)
+	if prev.body <= 0 || cur.body <= 0 {
+		return
+	}
+
+	// 吞没: 当前实体完全覆盖前一根相反方向的实体
+	if cur.body > prev.body && cur.bodyTop() >= prev.bodyTop() && cur.bodyBottom() <= prev.bodyBottom() {
+		strength := 0.5 + 0.5*(1-prev.body/cur.body)
+		switch {
+		case prev.bearish() && cur.bullish():
+			p.mark(PatternBullishEngulfing, &p.BullishEngulfing, strength)
+		case prev.bullish() && cur.bearish():
+			p.mark(PatternBearishEngulfing, &p.BearishEngulfing, strength)
+		}
+	}
+
+	// 孕线: 当前实体完全位于前一根实体之内
+	if cur.body < prev.body && cur.bodyTop() <= prev.bodyTop() && cur.bodyBottom() >= prev.bodyBottom() {
+		strength := 0.5 + 0.5*(1-cur.body/prev.body)
+		switch {
+		case prev.bearish() && !cur.bearish():
+			p.mark(PatternBullishHarami, &p.BullishHarami, strength)
+		case prev.bullish() && !cur.bullish():
+			p.mark(PatternBearishHarami, &p.BearishHarami, strength)
+		}
+	}
+}
+
+// detectTriple 识别三根K线组合形态
+func detectTriple(s *OHLCV, i int, p *CandlePattern) {
+	first, middle, last := candleAt(s, i-2), candleAt(s, i-1), candleAt(s, i)
+	if first.rng <= 0 || last.rng <= 0 {
+		return
+	}
+
+	// 早晨之星/黄昏之星: 长实体 + 小实体 + 反向长实体, 收盘越过第一根实体中点
+	longFirst := first.body >= 0.5*first.rng
+	smallMiddle := middle.body <= 0.3*first.body
+	mid := (first.open + first.close) / 2
+	if longFirst && smallMiddle && first.body > 0 {
+		switch {
+		case first.bearish() && last.bullish() && middle.bodyTop() <= first.close && last.close > mid:
+			p.mark(PatternMorningStar, &p.MorningStar, 0.5+0.5*Clamp((last.close-mid)/(first.body/2), 0, 1))
+		case first.bullish() && last.bearish() && middle.bodyBottom() >= first.close && last.close < mid:
+			p.mark(PatternEveningStar, &p.EveningStar, 0.5+0.5*Clamp((mid-last.close)/(first.body/2), 0, 1))
+		}
+	}
+
+	// 红三兵: 连续三根阳线逐级抬高, 开盘价位于前一根实体内, 上影线较短
+	bars := []candle{first, middle, last}
+	upperRatio := 0.0
+	for j, bar := range bars {
+		if !bar.bullish() || bar.rng <= 0 || bar.upper > bar.body {
+			return
+		}
+		if j > 0 {
+			prev := bars[j-1]
+			if bar.close <= prev.close || bar.open < prev.open || bar.open > prev.close {
+				return
+			}
+		}
+		upperRatio += bar.upper / bar.rng
+	}
+	p.mark(PatternThreeWhiteSoldiers, &p.ThreeWhiteSoldiers, 1-upperRatio/3)
+}
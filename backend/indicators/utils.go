@@ -1,5 +1,7 @@
 package indicators
 
+import "math"
+
 // IsValidFloat 检查浮点数是否有效
 func IsValidFloat(value float64) bool {
 	// 检查是否为无限大或无效值
@@ -26,5 +28,5 @@ func Sqrt(x float64) float64 {
 	if x < 0 {
 		return 0
 	}
-	return x * x
+	return math.Sqrt(x)
 }
@@ -0,0 +1,29 @@
+package indicators
+
+// CalculateVWAP 计算成交量加权平均价(VWAP), 以典型价格作为成交价格
+// series: K线序列
+// period: 滚动周期, 小于等于0时从序列起点累计
+// 成交量为0的区间取典型价格
+func CalculateVWAP(series *OHLCV, period int) ([]float64, error) {
+	size := series.Len()
+	vwap := make([]float64, size)
+	typical := series.TypicalPrice()
+
+	var sumPV, sumVolume float64
+	for i := 0; i < size; i++ {
+		sumPV += typical[i] * series.Volume[i]
+		sumVolume += series.Volume[i]
+		if period > 0 && i >= period {
+			sumPV -= typical[i-period] * series.Volume[i-period]
+			sumVolume -= series.Volume[i-period]
+		}
+
+		if sumVolume > 0 {
+			vwap[i] = sumPV / sumVolume
+		} else {
+			vwap[i] = typical[i]
+		}
+	}
+
+	return vwap, nil
+}
@@ -0,0 +1,29 @@
+package indicators
+
+// CalculateWilliamsR 计算威廉指标(%R)
+// series: K线序列
+// period: 计算周期(默认14)
+// 返回值范围: [-100, 0], 数据不足period天时使用已有数据, 最高价等于最低价时为-50
+func CalculateWilliamsR(series *OHLCV, period int) ([]float64, error) {
+	size := series.Len()
+	if size == 0 {
+		return make([]float64, 0), nil
+	}
+
+	// 使用默认参数
+	if period <= 0 {
+		period = 14
+	}
+
+	wr := make([]float64, size)
+	for i := 0; i < size; i++ {
+		high, low := series.highestLowest(i, period)
+		if high == low {
+			wr[i] = -50
+			continue
+		}
+		wr[i] = Clamp((high-series.Close[i])/(high-low)*-100, -100, 0)
+	}
+
+	return wr, nil
+}
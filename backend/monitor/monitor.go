@@ -0,0 +1,390 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/universe"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	defaultInterval    = 30  // 默认轮询间隔(秒)
+	minInterval        = 5   // 最小轮询间隔(秒), 实时行情缓存时间更短
+	maxStocks          = 500 // 最多监控的股票数, 每轮请求都经过API客户端的限流
+	quoteConcurrency   = 8   // 获取实时行情的并发数
+	maxRecordedAlerts  = 200 // 保留的最近提醒数
+	monitorStopTimeout = 10 * time.Second
+)
+
+// Config 盘中监控配置
+type Config struct {
+	StrategyID int                    `json:"strategyId"`
+	Universe   *universe.Universe     `json:"universe,omitempty"` // 监控的股票池(如自选股列表), 为空时为该策略最近一次执行记录中发出信号的股票
+	Interval   int                    `json:"interval"`           // 轮询间隔(秒), 0为默认值
+	Params     map[string]interface{} `json:"params,omitempty"`   // 参数覆盖值, 未指定的参数使用声明的默认值
+}
+
+// Alert on_tick发出信号时产生的提醒
+type Alert struct {
+	StrategyID   int                `json:"strategyId"`
+	StrategyName string             `json:"strategyName"`
+	Session      string             `json:"session"` // 触发时的交易时段
+	Time         time.Time          `json:"time"`
+	Quote        types.RealtimeData `json:"quote"`
+	Signal       engine.StockSignal `json:"signal"`
+}
+
+// Status 盘中监控状态
+type Status struct {
+	Running      bool      `json:"running"`
+	StrategyID   int       `json:"strategyId"`
+	StrategyName string    `json:"strategyName"`
+	Universe     string    `json:"universe"` // 股票池描述
+	Stocks       int       `json:"stocks"`   // 监控的股票数
+	Interval     int       `json:"interval"` // 轮询间隔(秒)
+	Session      string    `json:"session"`  // 当前交易时段
+	Polls        int       `json:"polls"`    // 交易时段内的轮询次数
+	Alerts       int       `json:"alerts"`   // 产生的提醒数
+	Errors       int       `json:"errors"`   // 获取行情或on_tick出错的次数
+	LastError    string    `json:"lastError,omitempty"`
+	StartTime    time.Time `json:"startTime"`
+	LastPoll     time.Time `json:"lastPoll"`
+}
+
+// Monitor 盘中监控服务: 在交易时段内按间隔轮询股票池的实时行情, 对有更新的股票调用策略的on_tick钩子,
+// 同一只股票持续满足条件时只在首次发出信号时提醒
+type Monitor struct {
+	ctx       context.Context
+	apiClient *api.Client
+	mutex     sync.RWMutex
+	status    Status
+	alerts    []Alert
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	onPoll []func(ctx context.Context, codes []string) // 每轮轮询完成后的回调
+}
+
+// watch 一次监控运行的状态, 只在轮询协程中访问
+type watch struct {
+	strategy *engine.Strategy
+	runner   *engine.TickRunner
+	stocks   []types.Index
+	updated  map[string]string // 每只股票上次处理的行情时间
+	active   map[string]bool   // 上次处理时发出了信号的股票和信号类型
+}
+
+// NewMonitor 创建盘中监控服务
+func NewMonitor(ctx context.Context, apiClient *api.Client) *Monitor {
+	return &Monitor{
+		ctx:       ctx,
+		apiClient: apiClient,
+		alerts:    make([]Alert, 0),
+	}
+}
+
+// Start 加载策略、解析股票池并开始轮询, 已在监控时返回错误
+func (m *Monitor) Start(strategy *engine.Strategy, config Config, sources universe.Sources) error {
+	if config.Universe == nil {
+		return fmt.Errorf("monitor universe is required")
+	}
+	if config.Interval == 0 {
+		config.Interval = defaultInterval
+	}
+	if config.Interval < minInterval {
+		return fmt.Errorf("monitor interval must be at least %d seconds", minInterval)
+	}
+
+	m.mutex.Lock()
+	running := m.status.Running
+	m.mutex.Unlock()
+	if running {
+		return fmt.Errorf("monitor is already running")
+	}
+
+	params, err := engine.ResolveParams(strategy.Params, config.Params)
+	if err != nil {
+		return err
+	}
+
+	parent := m.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+
+	sources.Client = m.apiClient
+	stocks, err := config.Universe.Resolve(ctx, sources)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("解析股票池失败: %v", err)
+	}
+	if len(stocks) == 0 {
+		cancel()
+		return fmt.Errorf("no stocks to monitor in %s", config.Universe)
+	}
+	if len(stocks) > maxStocks {
+		cancel()
+		return fmt.Errorf("too many stocks to monitor: %d (max %d)", len(stocks), maxStocks)
+	}
+
+	runner, err := engine.NewTickRunner(ctx, strategy, m.apiClient, engine.RunOptions{Params: params})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	w := &watch{
+		strategy: strategy,
+		runner:   runner,
+		stocks:   stocks,
+		updated:  make(map[string]string, len(stocks)),
+		active:   make(map[string]bool),
+	}
+
+	m.mutex.Lock()
+	if m.status.Running {
+		m.mutex.Unlock()
+		runner.Close()
+		cancel()
+		return fmt.Errorf("monitor is already running")
+	}
+	m.status = Status{
+		Running:      true,
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Universe:     config.Universe.String(),
+		Stocks:       len(stocks),
+		Interval:     config.Interval,
+		Session:      Session(time.Now()),
+		StartTime:    time.Now(),
+	}
+	m.alerts = make([]Alert, 0)
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	done := m.done
+	m.mutex.Unlock()
+
+	log.Printf("盘中监控已启动: %s, 股票数: %d, 间隔: %d秒\n", strategy.Name, len(stocks), config.Interval)
+	go m.loop(ctx, w, time.Duration(config.Interval)*time.Second, done)
+	m.emit("monitor:status", m.GetStatus())
+	return nil
+}
+
+// Stop 停止监控并等待轮询协程退出
+func (m *Monitor) Stop() {
+	m.mutex.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel = nil
+	m.mutex.Unlock()
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(monitorStopTimeout):
+		fmt.Printf("Warning: 等待盘中监控停止超时\n")
+	}
+	log.Println("盘中监控已停止")
+}
+
+// GetStatus 获取监控状态
+func (m *Monitor) GetStatus() Status {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	status := m.status
+	if !status.Running {
+		status.Session = Session(time.Now())
+	}
+	return status
+}
+
+// GetAlerts 获取本次(或最近一次)监控产生的提醒, 最多保留maxRecordedAlerts条
+func (m *Monitor) GetAlerts() []Alert {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	alerts := make([]Alert, len(m.alerts))
+	copy(alerts, m.alerts)
+	return alerts
+}
+
+// loop 按间隔轮询, 非交易时段只更新状态, 直到ctx取消
+func (m *Monitor) loop(ctx context.Context, w *watch, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	defer w.runner.Close()
+	defer func() {
+		m.mutex.Lock()
+		m.status.Running = false
+		m.mutex.Unlock()
+		m.emit("monitor:status", m.GetStatus())
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		session := Session(time.Now())
+		m.mutex.Lock()
+		m.status.Session = session
+		m.mutex.Unlock()
+
+		if session != SessionClosed {
+			m.poll(ctx, w, session)
+			m.emit("monitor:status", m.GetStatus())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll 获取一轮实时行情并对行情有更新的股票调用on_tick
+func (m *Monitor) poll(ctx context.Context, w *watch, session string) {
+	quotes, errs := m.fetchQuotes(ctx, w.stocks)
+	if ctx.Err() != nil {
+		return
+	}
+
+	errorCount := 0
+	var lastError error
+	var alerts []Alert
+	for i, stock := range w.stocks {
+		if errs[i] != nil {
+			errorCount++
+			lastError = errs[i]
+			continue
+		}
+		quote := quotes[i]
+		if quote.Time != "" && quote.Time == w.updated[stock.Code] {
+			continue
+		}
+		w.updated[stock.Code] = quote.Time
+
+		signals, err := w.runner.OnTick(stock, quote, session)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			errorCount++
+			lastError = err
+			continue
+		}
+		alerts = append(alerts, w.trigger(stock, quote, session, signals)...)
+	}
+
+	m.mutex.Lock()
+	m.status.Polls++
+	m.status.LastPoll = time.Now()
+	m.status.Alerts += len(alerts)
+	m.status.Errors += errorCount
+	if lastError != nil {
+		m.status.LastError = lastError.Error()
+	}
+	m.alerts = append(m.alerts, alerts...)
+	if len(m.alerts) > maxRecordedAlerts {
+		m.alerts = append([]Alert(nil), m.alerts[len(m.alerts)-maxRecordedAlerts:]...)
+	}
+	m.mutex.Unlock()
+
+	for _, alert := range alerts {
+		m.emit("monitor:alert", alert)
+	}
+
+	m.mutex.RLock()
+	callbacks := append([]func(context.Context, []string){}, m.onPoll...)
+	m.mutex.RUnlock()
+	if len(callbacks) > 0 {
+		codes := make([]string, len(w.stocks))
+		for i, stock := range w.stocks {
+			codes[i] = stock.Code
+		}
+		for _, fn := range callbacks {
+			fn(ctx, codes)
+		}
+	}
+}
+
+// OnPoll 注册每轮轮询完成后的回调, 参数为监控的股票代码, 如检查这些股票的提醒规则
+func (m *Monitor) OnPoll(fn func(ctx context.Context, codes []string)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onPoll = append(m.onPoll, fn)
+}
+
+// trigger 根据本次on_tick发出的信号生成提醒, 上次处理时已发出同类型信号的股票不重复提醒
+func (w *watch) trigger(stock types.Index, quote *types.RealtimeData, session string, signals []engine.StockSignal) []Alert {
+	fired := make(map[string]bool, len(signals))
+	var alerts []Alert
+	for _, signal := range signals {
+		key := signal.Code + "/" + signal.Type
+		if fired[key] {
+			continue
+		}
+		fired[key] = true
+		if w.active[key] {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			StrategyID:   w.strategy.ID,
+			StrategyName: w.strategy.Name,
+			Session:      session,
+			Time:         time.Now(),
+			Quote:        *quote,
+			Signal:       signal,
+		})
+	}
+
+	// 清除本次未再发出的信号, 条件再次满足时重新提醒
+	for _, signalType := range []string{engine.SignalTypeEntry, engine.SignalTypeExit} {
+		delete(w.active, stock.Code+"/"+signalType)
+	}
+	for key := range fired {
+		w.active[key] = true
+	}
+	return alerts
+}
+
+// fetchQuotes 并发获取股票的实时行情, 结果与stocks一一对应
+func (m *Monitor) fetchQuotes(ctx context.Context, stocks []types.Index) ([]*types.RealtimeData, []error) {
+	quotes := make([]*types.RealtimeData, len(stocks))
+	errs := make([]error, len(stocks))
+	sem := make(chan struct{}, quoteConcurrency)
+	var wg sync.WaitGroup
+	for i, stock := range stocks {
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, code string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			quotes[i], errs[i] = m.apiClient.Market.GetRealtimeData(ctx, code)
+		}(i, stock.Code)
+	}
+	wg.Wait()
+	return quotes, errs
+}
+
+// emit 发送监控事件
+func (m *Monitor) emit(event string, data interface{}) {
+	if m.ctx != nil {
+		runtime.EventsEmit(m.ctx, event, data)
+	}
+}
@@ -0,0 +1,34 @@
+package monitor
+
+import "time"
+
+// 交易时段
+const (
+	SessionClosed  = "closed"  // 非交易时段(含午间休市和周末)
+	SessionAuction = "auction" // 集合竞价: 开盘9:15-9:25, 收盘14:57-15:00
+	SessionTrading = "trading" // 连续竞价: 9:30-11:30, 13:00-14:57
+)
+
+// beijing 交易所所在时区, 不依赖系统的时区数据库
+var beijing = time.FixedZone("CST", 8*3600)
+
+// Session 获取指定时刻所处的交易时段, 各时段包含结束的那一分钟以取到该时段最后的行情; 不识别法定节假日,
+// 节假日行情不再更新, 监控会跳过未更新的行情
+func Session(t time.Time) string {
+	t = t.In(beijing)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return SessionClosed
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	between := func(start, end int) bool {
+		return minute >= start && minute <= end
+	}
+	switch {
+	case between(9*60+15, 9*60+25), between(14*60+57, 15*60):
+		return SessionAuction
+	case between(9*60+30, 11*60+30), between(13*60, 14*60+56):
+		return SessionTrading
+	}
+	return SessionClosed
+}
@@ -0,0 +1,586 @@
+package portfolio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+)
+
+// tradingDaysPerYear 年化使用的交易日数
+const tradingDaysPerYear = 252
+
+// 成交和资金按未复权价格计算, 收益(持仓估值、波动率)按前复权价格折算除权除息
+const (
+	tradeFreq  = types.FREQ_DAILY
+	returnFreq = types.FREQ_DAILY_QFQ
+)
+
+// position 持仓
+type position struct {
+	code      string
+	name      string
+	shares    int
+	cost      float64 // 买入总成本(含费用)
+	entryDate string
+	heldDays  int
+	lastPrice float64 // 最近收盘价, 已按复权因子折算到买入时的股数
+	factor    float64 // 买入日的复权因子
+}
+
+// priceSeries 单只股票的价格序列
+type priceSeries struct {
+	bars    []types.KLineData  // 未复权K线
+	index   map[string]int     // 日期 -> 下标
+	factors map[string]float64 // 日期 -> 复权因子(复权收盘价/未复权收盘价)
+}
+
+// simulator 组合模拟器
+type simulator struct {
+	config    Config
+	series    map[string]*priceSeries
+	cash      float64
+	positions []*position
+	result    *Result
+	bought    float64 // 累计买入金额
+	sold      float64 // 累计卖出金额
+}
+
+// normalize 使用默认值填充未设置的配置项
+func (c *Config) normalize() {
+	defaults := DefaultConfig()
+	if *c == (Config{}) {
+		*c = defaults
+		return
+	}
+	if c.InitialCapital <= 0 {
+		c.InitialCapital = defaults.InitialCapital
+	}
+	if c.MaxPositions <= 0 {
+		c.MaxPositions = defaults.MaxPositions
+	}
+	if c.Sizing != SizingVolatility {
+		c.Sizing = SizingEqualWeight
+	}
+	if c.VolatilityWindow <= 1 {
+		c.VolatilityWindow = defaults.VolatilityWindow
+	}
+	if c.TargetVolatility <= 0 {
+		c.TargetVolatility = defaults.TargetVolatility
+	}
+	if c.HoldingDays <= 0 {
+		c.HoldingDays = defaults.HoldingDays
+	}
+	if c.LotSize <= 0 {
+		c.LotSize = defaults.LotSize
+	}
+	if c.CommissionRate < 0 {
+		c.CommissionRate = defaults.CommissionRate
+	}
+	if c.MinCommission < 0 {
+		c.MinCommission = defaults.MinCommission
+	}
+	if c.StampDutyRate < 0 {
+		c.StampDutyRate = defaults.StampDutyRate
+	}
+	if c.SlippageRate < 0 {
+		c.SlippageRate = defaults.SlippageRate
+	}
+}
+
+// Simulate 将带日期的信号流转换为组合交易
+// 信号日收盘后下单, 下一交易日开盘买入; 遵循T+1, 收到卖出信号后次日开盘卖出, 否则持有期满后按收盘价卖出
+func Simulate(ctx context.Context, signals []engine.StockSignal, config Config, loader PriceLoader) (*Result, error) {
+	config.normalize()
+
+	// 按日期分组买入和卖出信号, 同一天同一股票同一类型只保留一次
+	byDate := make(map[string][]engine.StockSignal)
+	exitsByDate := make(map[string][]engine.StockSignal)
+	seen := make(map[string]bool)
+	codes := make([]string, 0)
+	for _, signal := range signals {
+		if signal.Date == "" || signal.Code == "" {
+			continue
+		}
+		key := signal.Date + "|" + signal.Code + "|" + signal.Type
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if signal.IsExit() {
+			exitsByDate[signal.Date] = append(exitsByDate[signal.Date], signal)
+			continue
+		}
+		byDate[signal.Date] = append(byDate[signal.Date], signal)
+		codes = append(codes, signal.Code)
+	}
+	if len(byDate) == 0 {
+		return nil, fmt.Errorf("没有带日期的信号可供模拟")
+	}
+
+	s := &simulator{
+		config: config,
+		series: make(map[string]*priceSeries),
+		cash:   config.InitialCapital,
+		result: &Result{
+			Config:      config,
+			CreatedAt:   time.Now(),
+			EquityCurve: make([]EquityPoint, 0),
+			Trades:      make([]Trade, 0),
+		},
+	}
+
+	// 加载价格数据
+	for _, code := range codes {
+		if _, ok := s.series[code]; ok {
+			continue
+		}
+		s.series[code] = loadSeries(ctx, code, loader)
+	}
+
+	// 构建交易日历
+	signalDates := make([]string, 0, len(byDate))
+	for date := range byDate {
+		signalDates = append(signalDates, date)
+	}
+	sort.Strings(signalDates)
+	calendar := s.calendar(signalDates[0])
+
+	var pending, pendingExits []engine.StockSignal
+	lastSignalDate := signalDates[len(signalDates)-1]
+	for _, date := range calendar {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// 上一交易日的卖出信号在今日开盘卖出, 买入信号在今日开盘买入
+		pendingExits = s.exit(date, pendingExits)
+		s.buy(date, pending)
+		pending = byDate[date]
+		pendingExits = append(pendingExits, exitsByDate[date]...)
+
+		// 持有期满的仓位按收盘价卖出
+		s.sell(date)
+
+		s.markToMarket(date)
+
+		if date >= lastSignalDate && len(pending) == 0 && len(s.positions) == 0 {
+			break
+		}
+	}
+
+	s.result.Skipped += len(pending)
+	if n := len(s.result.EquityCurve); n > 0 {
+		s.result.StartDate = s.result.EquityCurve[0].Date
+		s.result.EndDate = s.result.EquityCurve[n-1].Date
+	}
+	s.computeMetrics()
+
+	return s.result, nil
+}
+
+// loadSeries 加载未复权K线及复权因子, 复权K线加载失败时按未复权价格计算收益
+func loadSeries(ctx context.Context, code string, loader PriceLoader) *priceSeries {
+	bars, err := loader(ctx, code, tradeFreq)
+	if err != nil {
+		fmt.Printf("Warning: 加载 %s 价格数据失败: %v\n", code, err)
+		bars = nil
+	}
+	ps := &priceSeries{
+		bars:    bars,
+		index:   make(map[string]int, len(bars)),
+		factors: make(map[string]float64, len(bars)),
+	}
+	for i, bar := range bars {
+		ps.index[barDate(bar.Time)] = i
+	}
+	if len(bars) == 0 {
+		return ps
+	}
+
+	adjusted, err := loader(ctx, code, returnFreq)
+	if err != nil {
+		fmt.Printf("Warning: 加载 %s 复权价格数据失败: %v\n", code, err)
+		return ps
+	}
+	for _, bar := range adjusted {
+		date := barDate(bar.Time)
+		if idx, ok := ps.index[date]; ok && bars[idx].Close > 0 && bar.Close > 0 {
+			ps.factors[date] = bar.Close / bars[idx].Close
+		}
+	}
+	return ps
+}
+
+// factor 股票在指定日期的复权因子, 缺少复权数据时为1
+func (s *simulator) factor(code, date string) float64 {
+	if ps, ok := s.series[code]; ok {
+		if f, ok := ps.factors[date]; ok {
+			return f
+		}
+	}
+	return 1
+}
+
+// adjustment 持仓从买入日到指定日期因除权除息(送转、分红)产生的价值折算比例
+func (s *simulator) adjustment(p *position, date string) float64 {
+	if p.factor <= 0 {
+		return 1
+	}
+	return s.factor(p.code, date) / p.factor
+}
+
+// calendar 由所有价格序列合成从指定日期开始的交易日历
+func (s *simulator) calendar(from string) []string {
+	dateSet := make(map[string]bool)
+	for _, ps := range s.series {
+		for date := range ps.index {
+			if date >= from {
+				dateSet[date] = true
+			}
+		}
+	}
+	dates := make([]string, 0, len(dateSet))
+	for date := range dateSet {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// bar 获取股票在指定日期的K线
+func (s *simulator) bar(code, date string) (types.KLineData, int, bool) {
+	ps, ok := s.series[code]
+	if !ok {
+		return types.KLineData{}, -1, false
+	}
+	idx, ok := ps.index[date]
+	if !ok {
+		return types.KLineData{}, -1, false
+	}
+	return ps.bars[idx], idx, true
+}
+
+// equity 按最近价格计算总资产
+func (s *simulator) equity() float64 {
+	total := s.cash
+	for _, p := range s.positions {
+		total += float64(p.shares) * p.lastPrice
+	}
+	return total
+}
+
+// holding 是否持有该股票
+func (s *simulator) holding(code string) bool {
+	for _, p := range s.positions {
+		if p.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// commission 计算佣金
+func (s *simulator) commission(amount float64) float64 {
+	return math.Max(amount*s.config.CommissionRate, s.config.MinCommission)
+}
+
+// buy 在开盘价执行买入
+func (s *simulator) buy(date string, signals []engine.StockSignal) {
+	equity := s.equity()
+	for _, signal := range signals {
+		if s.holding(signal.Code) || len(s.positions) >= s.config.MaxPositions {
+			s.result.Skipped++
+			continue
+		}
+
+		bar, idx, ok := s.bar(signal.Code, date)
+		if !ok || bar.Open <= 0 || isOnePriceBoard(bar, true) {
+			// 停牌或一字涨停无法买入
+			s.result.Skipped++
+			continue
+		}
+
+		target := equity / float64(s.config.MaxPositions)
+		if s.config.Sizing == SizingVolatility {
+			if vol := s.volatility(signal.Code, idx); vol > 0 {
+				target *= clamp(s.config.TargetVolatility/vol, 0.25, 2)
+			}
+		}
+		target = math.Min(target, s.cash)
+
+		price := bar.Open * (1 + s.config.SlippageRate)
+		lot := s.config.LotSize
+		shares := int(target/(price*float64(lot))) * lot
+		for shares > 0 {
+			amount := price * float64(shares)
+			if amount+s.commission(amount) <= s.cash {
+				break
+			}
+			shares -= lot
+		}
+		if shares <= 0 {
+			s.result.Skipped++
+			continue
+		}
+
+		amount := price * float64(shares)
+		fee := s.commission(amount)
+		s.cash -= amount + fee
+		s.bought += amount
+		s.positions = append(s.positions, &position{
+			code:      signal.Code,
+			name:      signal.Name,
+			shares:    shares,
+			cost:      amount + fee,
+			entryDate: date,
+			lastPrice: bar.Open,
+			factor:    s.factor(signal.Code, date),
+		})
+		s.result.Trades = append(s.result.Trades, Trade{
+			Date:       date,
+			Code:       signal.Code,
+			Name:       signal.Name,
+			Side:       SideBuy,
+			Price:      price,
+			Shares:     shares,
+			Amount:     amount,
+			Commission: fee,
+			Reason:     signal.Reason,
+		})
+	}
+}
+
+// sell 卖出持有期满的仓位, 当日买入的仓位不可卖出(T+1)
+func (s *simulator) sell(date string) {
+	remaining := s.positions[:0]
+	for _, p := range s.positions {
+		bar, _, ok := s.bar(p.code, date)
+		if !ok || p.entryDate == date {
+			remaining = append(remaining, p)
+			continue
+		}
+
+		p.heldDays++
+		if p.heldDays < s.config.HoldingDays || isOnePriceBoard(bar, false) {
+			remaining = append(remaining, p)
+			continue
+		}
+
+		s.closePosition(p, date, bar.Close, fmt.Sprintf("持有%d天到期", p.heldDays))
+	}
+	s.positions = remaining
+}
+
+// exit 按开盘价执行卖出信号, 返回因停牌、一字跌停或T+1暂不能卖出的信号
+func (s *simulator) exit(date string, signals []engine.StockSignal) []engine.StockSignal {
+	var deferred []engine.StockSignal
+	for _, signal := range signals {
+		idx := -1
+		for i, p := range s.positions {
+			if p.code == signal.Code {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			continue
+		}
+
+		p := s.positions[idx]
+		bar, _, ok := s.bar(p.code, date)
+		if !ok || bar.Open <= 0 || p.entryDate == date || isOnePriceBoard(bar, false) {
+			deferred = append(deferred, signal)
+			continue
+		}
+
+		reason := signal.Reason
+		if reason == "" {
+			reason = "卖出信号"
+		}
+		s.closePosition(p, date, bar.Open, reason)
+		s.positions = append(s.positions[:idx], s.positions[idx+1:]...)
+	}
+	return deferred
+}
+
+// closePosition 以指定价格平仓, 持有期间的除权除息按复权因子折算到卖出金额
+func (s *simulator) closePosition(p *position, date string, closePrice float64, reason string) {
+	price := closePrice * (1 - s.config.SlippageRate)
+	amount := price * float64(p.shares) * s.adjustment(p, date)
+	fee := s.commission(amount)
+	stampDuty := amount * s.config.StampDutyRate
+	proceeds := amount - fee - stampDuty
+
+	s.cash += proceeds
+	s.sold += amount
+	s.result.Trades = append(s.result.Trades, Trade{
+		Date:       date,
+		Code:       p.code,
+		Name:       p.name,
+		Side:       SideSell,
+		Price:      price,
+		Shares:     p.shares,
+		Amount:     amount,
+		Commission: fee,
+		StampDuty:  stampDuty,
+		PnL:        proceeds - p.cost,
+		Reason:     reason,
+	})
+}
+
+// markToMarket 按收盘价估值并记录资金曲线
+func (s *simulator) markToMarket(date string) {
+	var marketValue float64
+	for _, p := range s.positions {
+		if bar, _, ok := s.bar(p.code, date); ok && bar.Close > 0 {
+			p.lastPrice = bar.Close * s.adjustment(p, date)
+		}
+		marketValue += float64(p.shares) * p.lastPrice
+	}
+
+	s.result.EquityCurve = append(s.result.EquityCurve, EquityPoint{
+		Date:        date,
+		Equity:      s.cash + marketValue,
+		Cash:        s.cash,
+		MarketValue: marketValue,
+		Positions:   len(s.positions),
+	})
+}
+
+// volatility 计算买入日之前窗口内的日收益率(复权)标准差
+func (s *simulator) volatility(code string, idx int) float64 {
+	ps := s.series[code]
+	start := idx - s.config.VolatilityWindow
+	if start < 1 {
+		return 0
+	}
+
+	adjusted := func(i int) float64 {
+		return ps.bars[i].Close * s.factor(code, barDate(ps.bars[i].Time))
+	}
+	returns := make([]float64, 0, s.config.VolatilityWindow)
+	for i := start; i < idx; i++ {
+		if prev := adjusted(i - 1); prev > 0 {
+			returns = append(returns, adjusted(i)/prev-1)
+		}
+	}
+	_, std := meanStd(returns)
+	return std
+}
+
+// computeMetrics 根据资金曲线和成交明细计算绩效
+func (s *simulator) computeMetrics() {
+	curve := s.result.EquityCurve
+	metrics := &s.result.Metrics
+	if len(curve) == 0 {
+		return
+	}
+
+	initial := s.config.InitialCapital
+	final := curve[len(curve)-1].Equity
+	metrics.TotalReturn = (final/initial - 1) * 100
+
+	returns := make([]float64, 0, len(curve))
+	prev := initial
+	peak := initial
+	var sumEquity, downside float64
+	for _, point := range curve {
+		if prev > 0 {
+			ret := point.Equity/prev - 1
+			returns = append(returns, ret)
+			if ret < 0 {
+				downside += ret * ret
+			}
+		}
+		prev = point.Equity
+		sumEquity += point.Equity
+
+		if point.Equity > peak {
+			peak = point.Equity
+		}
+		if drawdown := (1 - point.Equity/peak) * 100; drawdown > metrics.MaxDrawdown {
+			metrics.MaxDrawdown = drawdown
+		}
+	}
+
+	days := float64(len(curve))
+	if final > 0 {
+		metrics.AnnualReturn = (math.Pow(final/initial, tradingDaysPerYear/days) - 1) * 100
+	}
+
+	mean, std := meanStd(returns)
+	if std > 0 {
+		metrics.Sharpe = mean / std * math.Sqrt(tradingDaysPerYear)
+	}
+	if len(returns) > 0 && downside > 0 {
+		metrics.Sortino = mean / math.Sqrt(downside/float64(len(returns))) * math.Sqrt(tradingDaysPerYear)
+	}
+
+	if avgEquity := sumEquity / days; avgEquity > 0 {
+		metrics.Turnover = (s.bought + s.sold) / 2 / avgEquity * tradingDaysPerYear / days
+	}
+
+	var wins int
+	for _, trade := range s.result.Trades {
+		metrics.TotalFees += trade.Commission + trade.StampDuty
+		if trade.Side != SideSell {
+			continue
+		}
+		metrics.TradeCount++
+		if trade.PnL > 0 {
+			wins++
+		}
+	}
+	if metrics.TradeCount > 0 {
+		metrics.WinRate = float64(wins) / float64(metrics.TradeCount) * 100
+	}
+}
+
+// isOnePriceBoard 判断是否为一字涨停(up=true)或一字跌停
+func isOnePriceBoard(bar types.KLineData, up bool) bool {
+	if bar.High != bar.Low {
+		return false
+	}
+	if up {
+		return bar.Change > 0
+	}
+	return bar.Change < 0
+}
+
+// meanStd 计算均值和样本标准差
+func meanStd(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)-1))
+}
+
+// clamp 将值限制在指定范围内
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+// barDate 获取K线的日期部分(yyyy-MM-dd)
+func barDate(t string) string {
+	if len(t) > 10 {
+		return t[:10]
+	}
+	return t
+}
@@ -0,0 +1,105 @@
+package portfolio
+
+import (
+	"context"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+)
+
+// 仓位分配方式
+const (
+	SizingEqualWeight = "equal"      // 等权
+	SizingVolatility  = "volatility" // 按波动率倒数加权
+)
+
+// 交易方向
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+// PriceLoader 加载股票指定复权方式的日K线(按时间升序)
+type PriceLoader func(ctx context.Context, code string, freq types.KLineFreq) ([]types.KLineData, error)
+
+// Config 组合模拟配置
+type Config struct {
+	InitialCapital   float64 `json:"initialCapital"`   // 初始资金(元)
+	MaxPositions     int     `json:"maxPositions"`     // 最大持仓数
+	Sizing           string  `json:"sizing"`           // 仓位分配方式: equal, volatility
+	VolatilityWindow int     `json:"volatilityWindow"` // 波动率计算窗口(交易日)
+	TargetVolatility float64 `json:"targetVolatility"` // 目标日波动率, 波动率加权时使用
+	HoldingDays      int     `json:"holdingDays"`      // 最长持有天数(交易日)
+	LotSize          int     `json:"lotSize"`          // 每手股数
+	CommissionRate   float64 `json:"commissionRate"`   // 佣金费率(双向)
+	MinCommission    float64 `json:"minCommission"`    // 最低佣金(元)
+	StampDutyRate    float64 `json:"stampDutyRate"`    // 印花税率(仅卖出)
+	SlippageRate     float64 `json:"slippageRate"`     // 滑点比例
+}
+
+// DefaultConfig 默认组合模拟配置
+func DefaultConfig() Config {
+	return Config{
+		InitialCapital:   1000000,
+		MaxPositions:     10,
+		Sizing:           SizingEqualWeight,
+		VolatilityWindow: 20,
+		TargetVolatility: 0.02,
+		HoldingDays:      5,
+		LotSize:          100,
+		CommissionRate:   0.00025,
+		MinCommission:    5,
+		StampDutyRate:    0.0005,
+		SlippageRate:     0.001,
+	}
+}
+
+// Trade 成交记录
+type Trade struct {
+	Date       string  `json:"date"`       // 成交日期
+	Code       string  `json:"code"`       // 股票代码
+	Name       string  `json:"name"`       // 股票名称
+	Side       string  `json:"side"`       // 方向: buy, sell
+	Price      float64 `json:"price"`      // 成交价(含滑点)
+	Shares     int     `json:"shares"`     // 成交股数
+	Amount     float64 `json:"amount"`     // 成交金额
+	Commission float64 `json:"commission"` // 佣金
+	StampDuty  float64 `json:"stampDuty"`  // 印花税
+	PnL        float64 `json:"pnl"`        // 平仓盈亏(含费用, 仅卖出)
+	Reason     string  `json:"reason"`     // 成交原因
+}
+
+// EquityPoint 资金曲线上的一个点
+type EquityPoint struct {
+	Date        string  `json:"date"`        // 日期
+	Equity      float64 `json:"equity"`      // 总资产
+	Cash        float64 `json:"cash"`        // 现金
+	MarketValue float64 `json:"marketValue"` // 持仓市值
+	Positions   int     `json:"positions"`   // 持仓数
+}
+
+// Metrics 组合绩效指标
+type Metrics struct {
+	TotalReturn  float64 `json:"totalReturn"`  // 总收益率(%)
+	AnnualReturn float64 `json:"annualReturn"` // 年化收益率(%)
+	Sharpe       float64 `json:"sharpe"`       // 夏普比率(年化, 无风险利率为0)
+	Sortino      float64 `json:"sortino"`      // 索提诺比率(年化)
+	MaxDrawdown  float64 `json:"maxDrawdown"`  // 最大回撤(%)
+	Turnover     float64 `json:"turnover"`     // 年化换手率(倍)
+	TradeCount   int     `json:"tradeCount"`   // 平仓交易数
+	WinRate      float64 `json:"winRate"`      // 平仓胜率(%)
+	TotalFees    float64 `json:"totalFees"`    // 总费用(佣金+印花税)
+}
+
+// Result 组合模拟结果
+type Result struct {
+	Source      string        `json:"source"`      // 信号来源记录文件
+	Config      Config        `json:"config"`      // 模拟配置
+	StartDate   string        `json:"startDate"`   // 开始日期
+	EndDate     string        `json:"endDate"`     // 结束日期
+	CreatedAt   time.Time     `json:"createdAt"`   // 生成时间
+	EquityCurve []EquityPoint `json:"equityCurve"` // 资金曲线
+	Trades      []Trade       `json:"trades"`      // 成交明细
+	Skipped     int           `json:"skipped"`     // 因仓位、资金或涨跌停未能成交的信号数
+	Metrics     Metrics       `json:"metrics"`     // 绩效指标
+}
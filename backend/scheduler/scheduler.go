@@ -21,6 +21,8 @@ type Scheduler struct {
 	isRunning   bool
 	lastRun     time.Time
 	updating    bool // 添加更新状态标志
+
+	onUpdated []func() // 数据更新完成后的回调
 }
 
 // NewScheduler 创建新的调度器
@@ -65,6 +67,7 @@ func (s *Scheduler) Start() error {
 		} else {
 			s.lastRun = time.Now()
 			log.Printf("数据更新完成: %s\n", s.lastRun.Format("2006-01-02 15:04:05"))
+			s.notifyUpdated()
 		}
 
 		s.mutex.Lock()
@@ -132,5 +135,23 @@ func (s *Scheduler) RunNow() error {
 
 	s.lastRun = time.Now()
 	log.Printf("手动数据更新完成: %s\n", s.lastRun.Format("2006-01-02 15:04:05"))
+	s.notifyUpdated()
 	return nil
 }
+
+// OnUpdated 注册数据更新(定时或手动)成功后的回调, 如检查提醒规则
+func (s *Scheduler) OnUpdated(fn func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onUpdated = append(s.onUpdated, fn)
+}
+
+// notifyUpdated 依次调用数据更新完成后的回调
+func (s *Scheduler) notifyUpdated() {
+	s.mutex.RLock()
+	callbacks := append([]func(){}, s.onUpdated...)
+	s.mutex.RUnlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+}
@@ -0,0 +1,477 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// 比较运算符
+const (
+	opGT      = ">"
+	opGE      = ">="
+	opLT      = "<"
+	opLE      = "<="
+	opEQ      = "=="
+	opNE      = "!="
+	opBetween = "between"
+)
+
+// node 表达式节点, 求值结果为最新一根K线上的数值
+type node interface {
+	eval(d *stockData) (float64, error)
+	String() string
+	// window 求值需要的最少K线数
+	window() int
+}
+
+// numberNode 数值常量
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(*stockData) (float64, error) { return n.value, nil }
+func (n *numberNode) String() string                   { return formatNumber(n.value) }
+func (n *numberNode) window() int                      { return 0 }
+
+// fieldNode 行情字段, 如 close, turnover, pe
+type fieldNode struct {
+	name  string
+	field *field
+}
+
+func (n *fieldNode) eval(d *stockData) (float64, error) { return d.fieldValue(n.name, n.field) }
+func (n *fieldNode) String() string                     { return n.name }
+func (n *fieldNode) window() int {
+	if n.field.realtime != nil {
+		return 0
+	}
+	return 1
+}
+
+// callNode 指标函数调用, 如 ma(20), rsi(14)
+type callNode struct {
+	name string
+	args []float64
+	fn   *function
+}
+
+func (n *callNode) eval(d *stockData) (float64, error) { return d.callValue(n) }
+func (n *callNode) window() int                        { return n.fn.window(n.args) }
+func (n *callNode) String() string {
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		args[i] = formatNumber(arg)
+	}
+	return n.name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// binaryNode 四则运算
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n *binaryNode) eval(d *stockData) (float64, error) {
+	left, err := n.left.eval(d)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(d)
+	if err != nil {
+		return 0, err
+	}
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	default:
+		// 除数为0时结果为NaN/Inf, 比较结果为不满足
+		return left / right, nil
+	}
+}
+
+func (n *binaryNode) String() string {
+	return operandString(n.left) + " " + string(n.op) + " " + operandString(n.right)
+}
+
+func (n *binaryNode) window() int {
+	return max(n.left.window(), n.right.window())
+}
+
+// negNode 取负
+type negNode struct {
+	operand node
+}
+
+func (n *negNode) eval(d *stockData) (float64, error) {
+	value, err := n.operand.eval(d)
+	return -value, err
+}
+func (n *negNode) String() string { return "-" + operandString(n.operand) }
+func (n *negNode) window() int    { return n.operand.window() }
+
+// operandString 作为操作数时的文本, 四则运算加括号
+func operandString(n node) string {
+	if _, ok := n.(*binaryNode); ok {
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
+
+// finite 数值是否有效(非NaN/Inf)
+func finite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
+
+// formatNumber 格式化数值, 去掉多余的0
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Condition 编译后的选股条件
+type Condition struct {
+	Text     string // 原始条件文本
+	Op       string // 比较运算符
+	left     node
+	right    node // between时为下限
+	upper    node // between时的上限
+	minBars  int
+	valueOps []node // 需要在信号中展示数值的字段和函数
+}
+
+// String 规范化的条件文本
+func (c *Condition) String() string {
+	if c.Op == opBetween {
+		return fmt.Sprintf("%s between %s and %s", c.left, c.right, c.upper)
+	}
+	return fmt.Sprintf("%s %s %s", c.left, c.Op, c.right)
+}
+
+// ParseCondition 解析并校验选股条件, 如 "close > ma(20)", "limit_up_count(90) between 2 and 5"
+func ParseCondition(text string) (*Condition, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+
+	p := &parser{tokens: tokens}
+	cond := &Condition{Text: strings.TrimSpace(text)}
+	if cond.left, err = p.parseExpr(); err != nil {
+		return nil, err
+	}
+
+	op := p.next()
+	switch op.text {
+	case opGT, opGE, opLT, opLE, opEQ, opNE:
+		cond.Op = op.text
+		if cond.right, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	case opBetween:
+		cond.Op = opBetween
+		if cond.right, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+		if and := p.next(); and.text != "and" {
+			return nil, fmt.Errorf("expected \"and\" after between lower bound, got %q", and.text)
+		}
+		if cond.upper, err = p.parseExpr(); err != nil {
+			return nil, err
+		}
+	case "":
+		return nil, fmt.Errorf("missing comparison operator in condition %q", text)
+	default:
+		return nil, fmt.Errorf("unexpected %q in condition %q, expected a comparison operator", op.text, text)
+	}
+
+	if rest := p.next(); rest.text != "" {
+		return nil, fmt.Errorf("unexpected %q at end of condition %q", rest.text, text)
+	}
+
+	for _, operand := range cond.operands() {
+		cond.minBars = max(cond.minBars, operand.window())
+		collectValues(operand, &cond.valueOps)
+	}
+	return cond, nil
+}
+
+// operands 条件的各操作数
+func (c *Condition) operands() []node {
+	if c.Op == opBetween {
+		return []node{c.left, c.right, c.upper}
+	}
+	return []node{c.left, c.right}
+}
+
+// eval 判断条件是否成立, 任一操作数无法计算(NaN/Inf)时不成立
+func (c *Condition) eval(d *stockData) (bool, error) {
+	left, err := c.left.eval(d)
+	if err != nil {
+		return false, err
+	}
+	right, err := c.right.eval(d)
+	if err != nil {
+		return false, err
+	}
+	if !finite(left) || !finite(right) {
+		return false, nil
+	}
+
+	switch c.Op {
+	case opGT:
+		return left > right, nil
+	case opGE:
+		return left >= right, nil
+	case opLT:
+		return left < right, nil
+	case opLE:
+		return left <= right, nil
+	case opEQ:
+		return left == right, nil
+	case opNE:
+		return left != right, nil
+	default:
+		upper, err := c.upper.eval(d)
+		if err != nil || !finite(upper) {
+			return false, err
+		}
+		return left >= right && left <= upper, nil
+	}
+}
+
+// collectValues 收集表达式中的字段和函数节点
+func collectValues(n node, values *[]node) {
+	switch n := n.(type) {
+	case *fieldNode, *callNode:
+		*values = append(*values, n)
+	case *binaryNode:
+		collectValues(n.left, values)
+		collectValues(n.right, values)
+	case *negNode:
+		collectValues(n.operand, values)
+	}
+}
+
+// parseValue 解析并校验数值表达式, 如 "rsi(14)", "close / ma(60) - 1"
+func parseValue(text string) (node, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if rest := p.next(); rest.text != "" {
+		return nil, fmt.Errorf("unexpected %q at end of expression %q", rest.text, text)
+	}
+	return expr, nil
+}
+
+// token 词法单元
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenSymbol
+)
+
+// tokenize 将条件拆分为数值、标识符、运算符和括号, 标识符转为小写
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// 科学计数法, 如1e8, 2.5E-3
+			if i+1 < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if runes[j] == '+' || runes[j] == '-' {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+			value, err := strconv.ParseFloat(string(runes[start:i]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", string(runes[start:i]))
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), value: value})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: strings.ToLower(string(runes[start:i]))})
+		case strings.ContainsRune("<>=!", r):
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenSymbol, text: string(runes[i : i+2])})
+				i += 2
+				continue
+			}
+			if r == '=' || r == '!' {
+				return nil, fmt.Errorf("invalid operator %q, use == or !=", string(r))
+			}
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r)})
+			i++
+		case strings.ContainsRune("+-*/(),", r):
+			tokens = append(tokens, token{kind: tokenSymbol, text: string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("invalid character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+// parser 递归下降解析器, 运算符优先级为 取负 > 乘除 > 加减
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: tokenEOF}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseExpr() (node, error) {
+	return p.parseBinary("+-", p.parseTerm)
+}
+
+func (p *parser) parseTerm() (node, error) {
+	return p.parseBinary("*/", p.parseUnary)
+}
+
+// parseBinary 解析左结合的二元运算
+func (p *parser) parseBinary(ops string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenSymbol || len(t.text) != 1 || !strings.Contains(ops, t.text) {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: t.text[0], left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if t := p.peek(); t.kind == tokenSymbol && t.text == "-" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tokenNumber:
+		return &numberNode{value: t.value}, nil
+	case tokenIdent:
+		if p.peek().text == "(" {
+			return p.parseCall(t.text)
+		}
+		f, ok := fields[t.text]
+		if !ok {
+			if _, isFunc := functions[t.text]; isFunc {
+				return nil, fmt.Errorf("function %s requires parentheses, e.g. %s(...)", t.text, t.text)
+			}
+			return nil, fmt.Errorf("unknown field %q", t.text)
+		}
+		return &fieldNode{name: t.text, field: f}, nil
+	}
+
+	if t.text == "(" {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().text != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return expr, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// parseCall 解析函数调用, 参数只能是数值常量, 省略的参数使用默认值
+func (p *parser) parseCall(name string) (node, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	p.pos++ // (
+
+	var args []float64
+	for p.peek().text != ")" {
+		if len(args) > 0 {
+			if p.next().text != "," {
+				return nil, fmt.Errorf("expected , between arguments of %s", name)
+			}
+		}
+		arg := p.next()
+		if arg.kind != tokenNumber {
+			return nil, fmt.Errorf("arguments of %s must be numbers, got %q", name, arg.text)
+		}
+		args = append(args, arg.value)
+	}
+	p.pos++ // )
+
+	args, err := fn.resolveArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	return &callNode{name: name, args: args, fn: fn}, nil
+}
@@ -0,0 +1,334 @@
+package screener
+
+import (
+	"fmt"
+	"math"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/indicators"
+)
+
+// field 行情字段, K线字段取最新一根K线, 实时字段取实时行情
+type field struct {
+	kline    func(bar types.KLineData) float64
+	realtime func(data *types.RealtimeData) float64
+}
+
+// fields 条件中可用的行情字段
+var fields = map[string]*field{
+	"open":      {kline: func(bar types.KLineData) float64 { return bar.Open }},
+	"high":      {kline: func(bar types.KLineData) float64 { return bar.High }},
+	"low":       {kline: func(bar types.KLineData) float64 { return bar.Low }},
+	"close":     {kline: func(bar types.KLineData) float64 { return bar.Close }},
+	"volume":    {kline: func(bar types.KLineData) float64 { return bar.Volume }},
+	"amount":    {kline: func(bar types.KLineData) float64 { return bar.Amount }},
+	"turnover":  {kline: func(bar types.KLineData) float64 { return bar.Turnover }},
+	"change":    {kline: func(bar types.KLineData) float64 { return bar.Change }},
+	"amplitude": {kline: func(bar types.KLineData) float64 { return bar.Amplitude }},
+
+	// 实时行情字段, 回测时不可用
+	"price":        {realtime: func(data *types.RealtimeData) float64 { return data.Price }},
+	"pe":           {realtime: func(data *types.RealtimeData) float64 { return data.PE }},
+	"pb":           {realtime: func(data *types.RealtimeData) float64 { return data.PB }},
+	"total_value":  {realtime: func(data *types.RealtimeData) float64 { return data.TotalValue }},
+	"float_value":  {realtime: func(data *types.RealtimeData) float64 { return data.FloatValue }},
+	"volume_ratio": {realtime: func(data *types.RealtimeData) float64 { return data.VolumeRatio }},
+}
+
+// function 指标函数
+type function struct {
+	defaults []float64 // 各参数的默认值, 省略的参数使用默认值
+	intArgs  int       // 前intArgs个参数为周期, 必须是正整数
+	// window 求值需要的最少K线数
+	window func(args []float64) int
+	// calc 计算最新一根K线上的指标值
+	calc func(d *stockData, args []float64) (float64, error)
+}
+
+// resolveArgs 校验参数并补齐默认值
+func (f *function) resolveArgs(name string, args []float64) ([]float64, error) {
+	if len(args) > len(f.defaults) {
+		return nil, fmt.Errorf("%s accepts at most %d arguments, got %d", name, len(f.defaults), len(args))
+	}
+	resolved := append([]float64(nil), args...)
+	for i := len(args); i < len(f.defaults); i++ {
+		if math.IsNaN(f.defaults[i]) {
+			return nil, fmt.Errorf("%s requires at least %d arguments", name, i+1)
+		}
+		resolved = append(resolved, f.defaults[i])
+	}
+	for i, arg := range resolved {
+		if arg <= 0 {
+			return nil, fmt.Errorf("argument %d of %s must be positive", i+1, name)
+		}
+		if i < f.intArgs && arg != math.Trunc(arg) {
+			return nil, fmt.Errorf("argument %d of %s must be an integer", i+1, name)
+		}
+	}
+	return resolved, nil
+}
+
+// required 必填参数的占位默认值
+var required = math.NaN()
+
+// period 第i个参数作为周期
+func period(args []float64, i int) int {
+	return int(args[i])
+}
+
+// windowOf 需要的K线数为第i个参数加extra
+func windowOf(i, extra int) func([]float64) int {
+	return func(args []float64) int { return period(args, i) + extra }
+}
+
+// lastOf 取序列最新值
+func lastOf(values []float64, err error) (float64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("empty indicator series")
+	}
+	return values[len(values)-1], nil
+}
+
+// maOf 计算均线最新值
+func maOf(prices []float64, maType indicators.MAType, n int) (float64, error) {
+	return lastOf(indicators.CalculateMA(prices, maType, n))
+}
+
+// functions 条件中可用的指标函数
+var functions = map[string]*function{
+	// 均线
+	"ma": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return maOf(d.series.Close, indicators.SMA, period(args, 0))
+		}},
+	"ema": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return maOf(d.series.Close, indicators.EMA, period(args, 0))
+		}},
+	"volume_ma": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return maOf(d.series.Volume, indicators.SMA, period(args, 0))
+		}},
+
+	// 震荡指标
+	"rsi": {defaults: []float64{14}, intArgs: 1, window: windowOf(0, 1),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateRSI(d.series.Close, period(args, 0)))
+		}},
+	"cci": {defaults: []float64{14}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateCCI(d.series, period(args, 0)))
+		}},
+	"wr": {defaults: []float64{14}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateWilliamsR(d.series, period(args, 0)))
+		}},
+	"kdj_k": kdjFunction(func(r *indicators.KDJResult) []float64 { return r.K }),
+	"kdj_d": kdjFunction(func(r *indicators.KDJResult) []float64 { return r.D }),
+	"kdj_j": kdjFunction(func(r *indicators.KDJResult) []float64 { return r.J }),
+
+	// 趋势指标
+	"macd_dif":  macdFunction(func(r *indicators.MACDResult) []float64 { return r.DIF }),
+	"macd_dea":  macdFunction(func(r *indicators.MACDResult) []float64 { return r.DEA }),
+	"macd_hist": macdFunction(func(r *indicators.MACDResult) []float64 { return r.MACD }),
+	"adx": {defaults: []float64{14, 6}, intArgs: 2,
+		window: func(args []float64) int { return 2*period(args, 0) + period(args, 1) },
+		calc: func(d *stockData, args []float64) (float64, error) {
+			result, err := indicators.CalculateDMI(d.series, period(args, 0), period(args, 1))
+			if err != nil {
+				return 0, err
+			}
+			return lastOf(result.ADX, nil)
+		}},
+
+	// 波动与成交量
+	"atr": {defaults: []float64{14}, intArgs: 1, window: windowOf(0, 1),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateATR(d.series, period(args, 0)))
+		}},
+	"boll_upper": bollFunction(func(r *indicators.BollingerResult) []float64 { return r.Upper }),
+	"boll_mid":   bollFunction(func(r *indicators.BollingerResult) []float64 { return r.Middle }),
+	"boll_lower": bollFunction(func(r *indicators.BollingerResult) []float64 { return r.Lower }),
+	"vwap": {defaults: []float64{20}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateVWAP(d.series, period(args, 0)))
+		}},
+	"obv": {window: func([]float64) int { return 1 },
+		calc: func(d *stockData, args []float64) (float64, error) {
+			return lastOf(indicators.CalculateOBV(d.series))
+		}},
+
+	// 价格区间
+	"highest": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			highest := math.Inf(-1)
+			for _, value := range d.tail(d.series.High, period(args, 0)) {
+				highest = math.Max(highest, value)
+			}
+			return highest, nil
+		}},
+	"lowest": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			lowest := math.Inf(1)
+			for _, value := range d.tail(d.series.Low, period(args, 0)) {
+				lowest = math.Min(lowest, value)
+			}
+			return lowest, nil
+		}},
+	"change_pct": {defaults: []float64{required}, intArgs: 1, window: windowOf(0, 1),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			closes := d.series.Close
+			base := closes[len(closes)-1-period(args, 0)]
+			if base <= 0 {
+				return math.NaN(), nil
+			}
+			return (closes[len(closes)-1]/base - 1) * 100, nil
+		}},
+
+	// K线形态: 最近n根K线中的涨停次数, 上市不足n根K线时统计全部K线
+	"limit_up_count": {defaults: []float64{required}, intArgs: 1, window: func([]float64) int { return 2 },
+		calc: func(d *stockData, args []float64) (float64, error) {
+			patterns, err := d.patterns()
+			if err != nil {
+				return 0, err
+			}
+			count := 0
+			for _, pattern := range patterns[max(0, len(patterns)-period(args, 0)):] {
+				if pattern.LimitUp > 0 {
+					count++
+				}
+			}
+			return float64(count), nil
+		}},
+
+	// 资金流向: 最近n个交易日的主力净流入合计(元), 数据不足n日时合计全部数据
+	"main_inflow": {defaults: []float64{required}, intArgs: 1, window: func([]float64) int { return 1 },
+		calc: func(d *stockData, args []float64) (float64, error) {
+			flows, err := d.capitalFlow()
+			if err != nil {
+				return 0, err
+			}
+			if len(flows) == 0 {
+				return math.NaN(), nil
+			}
+			total := 0.0
+			for _, flow := range flows[max(0, len(flows)-period(args, 0)):] {
+				total += flow.MainForceNetInflow
+			}
+			return total, nil
+		}},
+}
+
+// kdjFunction KDJ指标函数, 参数为(n=9, m1=3, m2=3)
+func kdjFunction(line func(*indicators.KDJResult) []float64) *function {
+	return &function{defaults: []float64{9, 3, 3}, intArgs: 3, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			result, err := indicators.CalculateKDJOHLCV(d.series, period(args, 0), period(args, 1), period(args, 2))
+			if err != nil {
+				return 0, err
+			}
+			return lastOf(line(result), nil)
+		}}
+}
+
+// macdFunction MACD指标函数, 参数为(fast=12, slow=26, signal=9)
+func macdFunction(line func(*indicators.MACDResult) []float64) *function {
+	return &function{defaults: []float64{12, 26, 9}, intArgs: 3,
+		window: func(args []float64) int { return period(args, 1) + period(args, 2) },
+		calc: func(d *stockData, args []float64) (float64, error) {
+			result, err := indicators.CalculateMACD(d.series.Close, period(args, 0), period(args, 1), period(args, 2))
+			if err != nil {
+				return 0, err
+			}
+			return lastOf(line(result), nil)
+		}}
+}
+
+// bollFunction 布林带函数, 参数为(n=20, k=2)
+func bollFunction(line func(*indicators.BollingerResult) []float64) *function {
+	return &function{defaults: []float64{20, 2}, intArgs: 1, window: windowOf(0, 0),
+		calc: func(d *stockData, args []float64) (float64, error) {
+			result, err := indicators.CalculateBollinger(d.series.Close, period(args, 0), args[1])
+			if err != nil {
+				return 0, err
+			}
+			return lastOf(line(result), nil)
+		}}
+}
+
+// stockData 单只股票单次求值的数据, 同一指标只计算一次
+type stockData struct {
+	stock  types.Index
+	source engine.DataSource
+	bars   []types.KLineData
+	series *indicators.OHLCV
+
+	realtime *types.RealtimeData
+	pattern  []indicators.CandlePattern
+	flows    []types.CapitalFlow
+	values   map[string]float64 // 按节点文本缓存的数值
+}
+
+// fieldValue 获取行情字段的值
+func (d *stockData) fieldValue(name string, f *field) (float64, error) {
+	if f.kline != nil {
+		return f.kline(d.bars[len(d.bars)-1]), nil
+	}
+	if d.realtime == nil {
+		realtime, err := d.source.Realtime(d.stock.Code)
+		if err != nil {
+			return 0, fmt.Errorf("field %s: %v", name, err)
+		}
+		d.realtime = realtime
+	}
+	return f.realtime(d.realtime), nil
+}
+
+// callValue 计算指标函数的值
+func (d *stockData) callValue(n *callNode) (float64, error) {
+	key := n.String()
+	if value, ok := d.values[key]; ok {
+		return value, nil
+	}
+	value, err := n.fn.calc(d, n.args)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", key, err)
+	}
+	d.values[key] = value
+	return value, nil
+}
+
+// tail 序列的最后n个值
+func (d *stockData) tail(values []float64, n int) []float64 {
+	return values[max(0, len(values)-n):]
+}
+
+// patterns K线形态识别结果, 涨停幅度按股票代码确定
+func (d *stockData) patterns() ([]indicators.CandlePattern, error) {
+	if d.pattern == nil {
+		patterns, err := indicators.DetectPatterns(d.bars, &indicators.PatternOptions{
+			LimitUpRate: indicators.LimitUpRate(d.stock.Code),
+		})
+		if err != nil {
+			return nil, err
+		}
+		d.pattern = patterns
+	}
+	return d.pattern, nil
+}
+
+// capitalFlow 资金流向(按时间升序)
+func (d *stockData) capitalFlow() ([]types.CapitalFlow, error) {
+	if d.flows == nil {
+		flows, err := d.source.CapitalFlow(d.stock.Code)
+		if err != nil {
+			return nil, err
+		}
+		d.flows = flows
+	}
+	return d.flows, nil
+}
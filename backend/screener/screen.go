@@ -0,0 +1,254 @@
+package screener
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/indicators"
+)
+
+// Screen 声明式选股条件, 以JSON文件保存在策略目录中, 无需编写Lua即可选股
+//
+//	{
+//	  "id": 5,
+//	  "name": "超跌活跃股",
+//	  "conditions": ["close > ma(20)", "turnover > 5", "rsi(14) < 30", "limit_up_count(90) between 2 and 5"],
+//	  "score": "turnover",
+//	  "tags": ["oversold"]
+//	}
+type Screen struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Freq        types.KLineFreq `json:"freq,omitempty"`  // K线周期, 默认日线(后复权)
+	Conditions  []string        `json:"conditions"`      // 选股条件, 按顺序判断, 全部满足时入选
+	Score       string          `json:"score,omitempty"` // 信号评分表达式, 为空时评分为0
+	Tags        []string        `json:"tags,omitempty"`  // 信号标签
+}
+
+// validFreqs 支持的K线周期
+var validFreqs = map[types.KLineFreq]bool{
+	types.FREQ_5MIN: true, types.FREQ_15MIN: true, types.FREQ_30MIN: true, types.FREQ_60MIN: true,
+	types.FREQ_DAILY: true, types.FREQ_DAILY_QFQ: true, types.FREQ_DAILY_HFQ: true,
+	types.FREQ_WEEKLY: true, types.FREQ_WEEKLY_QFQ: true, types.FREQ_WEEKLY_HFQ: true,
+	types.FREQ_MONTHLY: true, types.FREQ_MONTHLY_QFQ: true, types.FREQ_MONTHLY_HFQ: true,
+	types.FREQ_YEARLY: true, types.FREQ_YEARLY_QFQ: true, types.FREQ_YEARLY_HFQ: true,
+}
+
+// Compiled 编译后的选股条件, 实现engine.Evaluator
+type Compiled struct {
+	screen     Screen
+	conditions []*Condition
+	score      node
+	minBars    int // 求值需要的最少K线数, 不足时不入选
+}
+
+// Compile 校验并编译选股条件
+func Compile(screen Screen) (*Compiled, error) {
+	if screen.ID <= 0 {
+		return nil, fmt.Errorf("screen id must be positive")
+	}
+	if strings.TrimSpace(screen.Name) == "" {
+		return nil, fmt.Errorf("screen name is required")
+	}
+	if screen.Freq == "" {
+		screen.Freq = types.FREQ_DAILY_HFQ
+	}
+	if !validFreqs[screen.Freq] {
+		return nil, fmt.Errorf("unsupported kline freq: %s", screen.Freq)
+	}
+	if len(screen.Conditions) == 0 {
+		return nil, fmt.Errorf("screen %s has no conditions", screen.Name)
+	}
+
+	compiled := &Compiled{screen: screen, minBars: 1}
+	for i, text := range screen.Conditions {
+		cond, err := ParseCondition(text)
+		if err != nil {
+			return nil, fmt.Errorf("condition %d %q: %v", i+1, text, err)
+		}
+		compiled.conditions = append(compiled.conditions, cond)
+		compiled.minBars = max(compiled.minBars, cond.minBars)
+	}
+
+	if strings.TrimSpace(screen.Score) != "" {
+		score, err := parseValue(screen.Score)
+		if err != nil {
+			return nil, fmt.Errorf("score %q: %v", screen.Score, err)
+		}
+		compiled.score = score
+		compiled.minBars = max(compiled.minBars, score.window())
+	}
+
+	return compiled, nil
+}
+
+// Screen 编译前的选股条件(已填充默认值)
+func (c *Compiled) Screen() Screen {
+	return c.screen
+}
+
+// Strategy 转换为可由执行引擎运行的策略
+func (c *Compiled) Strategy(filePath string) engine.Strategy {
+	return engine.Strategy{
+		ID:          c.screen.ID,
+		Name:        c.screen.Name,
+		Description: c.screen.Description,
+		FilePath:    filePath,
+		Type:        engine.StrategyTypeScreen,
+		Evaluator:   c,
+	}
+}
+
+// CheckBacktest 实现engine.BacktestChecker, 条件或评分使用了实时行情字段(如pe)时不能回测
+func (c *Compiled) CheckBacktest() error {
+	var operands []node
+	for _, cond := range c.conditions {
+		operands = append(operands, cond.valueOps...)
+	}
+	if c.score != nil {
+		collectValues(c.score, &operands)
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, operand := range operands {
+		if f, ok := operand.(*fieldNode); ok && f.field.realtime != nil && !seen[f.name] {
+			seen[f.name] = true
+			fields = append(fields, f.name)
+		}
+	}
+	if len(fields) > 0 {
+		return fmt.Errorf("realtime fields are not available in backtest: %s", strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// Evaluate 实现engine.Evaluator, 按顺序判断各条件, 全部满足时返回买入信号
+func (c *Compiled) Evaluate(stock types.Index, source engine.DataSource) (*engine.StockSignal, error) {
+	bars, err := source.KLineData(stock.Code, c.screen.Freq)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) < c.minBars {
+		return nil, nil
+	}
+
+	d := newStockData(stock, source, bars)
+	reasons := make([]string, 0, len(c.conditions))
+	values := make(map[string]interface{})
+	for _, cond := range c.conditions {
+		ok, err := cond.eval(d)
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %v", cond.Text, err)
+		}
+		if !ok {
+			return nil, nil
+		}
+
+		// 信号原因中列出条件涉及的字段和指标的数值
+		terms := make([]string, 0, len(cond.valueOps))
+		for _, operand := range cond.valueOps {
+			value, err := operand.eval(d)
+			if err != nil || !finite(value) {
+				continue
+			}
+			name := operand.String()
+			values[name] = value
+			terms = append(terms, fmt.Sprintf("%s=%.2f", name, value))
+		}
+		reason := cond.String()
+		if len(terms) > 0 {
+			reason += " [" + strings.Join(terms, ", ") + "]"
+		}
+		reasons = append(reasons, reason)
+	}
+
+	last := bars[len(bars)-1]
+	signal := &engine.StockSignal{
+		Code:     stock.Code,
+		Name:     stock.Name,
+		Price:    last.Close,
+		Turnover: last.Turnover,
+		Change:   last.Change,
+		Reason:   "满足条件: " + strings.Join(reasons, "; "),
+		Tags:     c.screen.Tags,
+		Fields:   values,
+	}
+	if c.score != nil {
+		score, err := c.score.eval(d)
+		if err != nil {
+			return nil, fmt.Errorf("score %q: %v", c.screen.Score, err)
+		}
+		if finite(score) {
+			signal.Score = score
+		}
+	}
+
+	return signal, nil
+}
+
+// Check 判断单只股票当前是否满足条件, 返回条件涉及的字段和指标的数值; freq为空时使用日线(后复权), K线不足时不满足
+func (c *Condition) Check(stock types.Index, source engine.DataSource, freq types.KLineFreq) (bool, map[string]float64, error) {
+	if freq == "" {
+		freq = types.FREQ_DAILY_HFQ
+	}
+	bars, err := source.KLineData(stock.Code, freq)
+	if err != nil {
+		return false, nil, err
+	}
+	values := make(map[string]float64)
+	if len(bars) < max(c.minBars, 1) {
+		return false, values, nil
+	}
+
+	d := newStockData(stock, source, bars)
+	ok, err := c.eval(d)
+	if err != nil {
+		return false, nil, err
+	}
+	for _, operand := range c.valueOps {
+		if value, err := operand.eval(d); err == nil && finite(value) {
+			values[operand.String()] = value
+		}
+	}
+	return ok, values, nil
+}
+
+// newStockData 创建单只股票单次求值的数据
+func newStockData(stock types.Index, source engine.DataSource, bars []types.KLineData) *stockData {
+	return &stockData{
+		stock:  stock,
+		source: source,
+		bars:   bars,
+		series: indicators.NewOHLCV(bars),
+		values: make(map[string]float64),
+	}
+}
+
+// Load 从JSON文件加载选股条件
+func Load(filePath string) (*Screen, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var screen Screen
+	if err := json.Unmarshal(data, &screen); err != nil {
+		return nil, fmt.Errorf("invalid screen file %s: %v", filePath, err)
+	}
+	return &screen, nil
+}
+
+// Save 将选股条件保存为JSON文件
+func Save(filePath string, screen Screen) error {
+	data, err := json.MarshalIndent(screen, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
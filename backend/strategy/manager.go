@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,7 +17,11 @@ import (
 
 	"stock-helper-svelte/backend/api"
 	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/screener"
+	"stock-helper-svelte/backend/universe"
+	"stock-helper-svelte/backend/watchlist"
 
+	"github.com/tidwall/buntdb"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -28,12 +33,27 @@ type ExecutionUpdater interface {
 
 // Manager 策略管理器
 type Manager struct {
-	basePath  string               // 策略文件基础路径
-	apiClient *api.Client          // API客户端
-	ctx       context.Context      // 上下文
-	engine    *engine.Engine       // 执行引擎
-	mutex     sync.RWMutex         // 读写锁
-	signals   []engine.StockSignal // 当前执行的信号
+	basePath  string                 // 策略文件基础路径
+	apiClient *api.Client            // API客户端
+	ctx       context.Context        // 上下文
+	engine    *engine.Engine         // 执行引擎
+	mutex     sync.RWMutex           // 读写锁
+	signals   []engine.StockSignal   // 当前执行的信号
+	positions []engine.Position      // 当前执行开始时的持仓
+	tracking  bool                   // 当前执行是否跟踪持仓(策略定义了check_exit)
+	params    map[string]interface{} // 当前执行使用的策略参数
+	universe  *universe.Universe     // 当前执行的股票池, 为空表示默认股票池
+	backtest  bool                   // 当前运行是否为回测
+	batch     map[int]*batchRun      // 当前(或最近一次)批量执行的各策略, 按策略ID索引
+	batching  bool                   // 当前运行是否为批量执行
+	sources   universe.Sources       // 解析股票池的数据来源
+}
+
+// ExecuteOptions 策略执行选项
+type ExecuteOptions struct {
+	Params map[string]interface{} `json:"params"` // 参数覆盖值, 未指定的参数使用声明的默认值
+
+	Universe *universe.Universe `json:"universe,omitempty"` // 运行的股票池, 为空时使用默认股票池(仅实盘选股)
 }
 
 // statusUpdater 实现 engine.StatusUpdater 接口
@@ -57,8 +77,45 @@ func (s *statusUpdater) AddSignal(signal engine.StockSignal) {
 	runtime.EventsEmit(s.ctx, "engine:signal", signal)
 }
 
-// NewManager 创建新的策略管理器
-func NewManager(basePath string, apiClient *api.Client, ctx context.Context) *Manager {
+// batchRun 批量执行中单个策略的信号、持仓和参数
+type batchRun struct {
+	strategy  *engine.Strategy
+	signals   []engine.StockSignal
+	positions []engine.Position
+	tracking  bool
+	params    map[string]interface{}
+}
+
+// BatchSignal 批量执行中某个策略发出的信号
+type BatchSignal struct {
+	StrategyID int                `json:"strategyId"`
+	Signal     engine.StockSignal `json:"signal"`
+}
+
+// batchUpdater 批量执行中单个策略的状态更新器, 信号记入该策略自己的信号列表
+type batchUpdater struct {
+	ctx        context.Context
+	manager    *Manager
+	strategyID int
+}
+
+func (s *batchUpdater) UpdateStatus(status engine.ExecutionStatus) {
+	runtime.EventsEmit(s.ctx, "engine:batch:status", status)
+}
+
+func (s *batchUpdater) UpdateProgress(processedStocks int, currentStock string) {}
+
+func (s *batchUpdater) AddSignal(signal engine.StockSignal) {
+	s.manager.mutex.Lock()
+	if run, ok := s.manager.batch[s.strategyID]; ok {
+		run.signals = append(run.signals, signal)
+	}
+	s.manager.mutex.Unlock()
+	runtime.EventsEmit(s.ctx, "engine:batch:signal", BatchSignal{StrategyID: s.strategyID, Signal: signal})
+}
+
+// NewManager 创建新的策略管理器, db不为空时用于保存实盘选股的断点和读取自选股列表
+func NewManager(basePath string, apiClient *api.Client, db *buntdb.DB, ctx context.Context) *Manager {
 	manager := &Manager{
 		basePath:  basePath,
 		apiClient: apiClient,
@@ -82,6 +139,12 @@ func NewManager(basePath string, apiClient *api.Client, ctx context.Context) *Ma
 		APIClient:        apiClient,
 		Context:          ctx,
 	}
+	if db != nil {
+		config.Checkpoints = engine.NewCheckpointStore(db)
+		config.Universe.Watchlist = watchlist.NewStore(db).Codes
+	}
+	config.Universe.Record = manager.recordCodes
+	manager.sources = config.Universe
 
 	// 创建执行引擎
 	eng, err := engine.NewEngine(config, updater)
@@ -109,18 +172,89 @@ func (m *Manager) GetCurrentStatus() engine.ExecutionStatus {
 }
 
 // ExecuteStrategy 执行策略
-func (m *Manager) ExecuteStrategy(strategy *engine.Strategy) error {
+func (m *Manager) ExecuteStrategy(strategy *engine.Strategy, options ExecuteOptions) error {
+	if m.engine == nil {
+		return fmt.Errorf("engine not initialized")
+	}
+
+	params, err := engine.ResolveParams(strategy.Params, options.Params)
+	if err != nil {
+		return err
+	}
+
+	// 加载上次执行后的持仓, 供策略的check_exit判断卖出
+	positions, tracking := m.openPositions(strategy)
+
+	return m.execute(strategy, make([]engine.StockSignal, 0), tracking, engine.RunOptions{Positions: positions, Params: params, Universe: options.Universe})
+}
+
+// GetInterruptedRun 获取上次中断(如应用关闭)的实盘选股断点, 没有中断的运行时返回nil
+func (m *Manager) GetInterruptedRun() (*engine.Checkpoint, error) {
+	if m.engine == nil {
+		return nil, nil
+	}
+	return m.engine.LoadCheckpoint()
+}
+
+// ResumeInterruptedRun 从断点继续上次中断的实盘选股, 跳过已处理的股票,
+// 沿用原运行的开始时间、参数和持仓, 结果保存到原运行对应的执行记录文件
+func (m *Manager) ResumeInterruptedRun() error {
 	if m.engine == nil {
 		return fmt.Errorf("engine not initialized")
 	}
 
+	checkpoint, err := m.engine.LoadCheckpoint()
+	if err != nil {
+		return fmt.Errorf("加载断点失败: %v", err)
+	}
+	if checkpoint == nil {
+		return fmt.Errorf("no interrupted run to resume")
+	}
+
+	strategy, err := m.GetStrategyByID(checkpoint.StrategyID)
+	if err != nil {
+		return err
+	}
+
+	signals := make([]engine.StockSignal, 0, len(checkpoint.Signals))
+	signals = append(signals, checkpoint.Signals...)
+	return m.execute(strategy, signals, engine.DefinesExitHook(strategy), engine.RunOptions{
+		Positions: checkpoint.Positions,
+		Params:    checkpoint.Params,
+		Resume:    checkpoint,
+		Universe:  checkpoint.Universe,
+	})
+}
+
+// DiscardInterruptedRun 丢弃上次中断的实盘选股断点
+func (m *Manager) DiscardInterruptedRun() error {
+	if m.engine == nil {
+		return nil
+	}
+	return m.engine.ClearCheckpoint()
+}
+
+// SaveCheckpoint 立即保存正在运行的实盘选股的断点, 供应用关闭前调用
+func (m *Manager) SaveCheckpoint() error {
+	if m == nil || m.engine == nil {
+		return nil
+	}
+	return m.engine.SaveCheckpoint()
+}
+
+// execute 以给定的初始信号执行策略, 执行完成或被中止时保存执行记录, tracking为true时记录执行后的持仓
+func (m *Manager) execute(strategy *engine.Strategy, signals []engine.StockSignal, tracking bool, options engine.RunOptions) error {
 	// 重置信号列表
 	m.mutex.Lock()
-	m.signals = make([]engine.StockSignal, 0)
+	m.signals = signals
+	m.positions = options.Positions
+	m.tracking = tracking
+	m.params = options.Params
+	m.universe = options.Universe
 	m.mutex.Unlock()
 
 	// 执行策略
-	err := m.engine.Execute(strategy)
+	err := m.engine.Execute(strategy, options)
 
 	// 获取当前状态
 	status := m.engine.GetStatus()
@@ -129,55 +263,289 @@ func (m *Manager) ExecuteStrategy(strategy *engine.Strategy) error {
 	// 如果执行完成或被中止，保存结果
 	if status.Status == engine.StatusCompleted || status.Status == engine.StatusStopped {
 		fmt.Printf("准备保存执行结果...\n")
+		if saveErr := m.saveExecutionResult(strategy.ID, strategy.Name, status); saveErr != nil {
+			return saveErr
+		}
+	}
+
+	return err
+}
+
+// DebugStrategy 在独立的Lua状态中对单只股票试运行策略, 返回api调用、日志和信号的跟踪记录, 不保存执行记录
+func (m *Manager) DebugStrategy(strategy *engine.Strategy, code string, options ExecuteOptions) (*engine.DebugTrace, error) {
+	if m.apiClient == nil {
+		return nil, fmt.Errorf("API client not initialized")
+	}
+
+	params, err := engine.ResolveParams(strategy.Params, options.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	indices, err := m.apiClient.Market.GetIndexList(m.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取股票列表失败: %v", err)
+	}
+	for _, stock := range indices {
+		if stock.Code != code {
+			continue
+		}
+		// 持有该股票时同时运行check_exit
+		positions, _ := m.openPositions(strategy)
+		return engine.DebugStrategy(m.ctx, strategy, stock, m.apiClient, engine.RunOptions{Positions: positions, Params: params}), nil
+	}
+
+	return nil, fmt.Errorf("stock not found: %s", code)
+}
+
+// ExecuteBatch 在一次遍历中对同一股票池(为空时使用默认股票池)执行多个策略, 每只股票的数据只获取一次;
+// 各策略使用默认参数和各自的持仓, 分别收集信号并保存各自的执行记录
+func (m *Manager) ExecuteBatch(strategies []*engine.Strategy, u *universe.Universe) error {
+	if m.engine == nil {
+		return fmt.Errorf("engine not initialized")
+	}
+
+	runs := make([]engine.BatchRun, 0, len(strategies))
+	batch := make(map[int]*batchRun, len(strategies))
+	for _, strategy := range strategies {
+		params, err := engine.ResolveParams(strategy.Params, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %v", strategy.Name, err)
+		}
+		positions, tracking := m.openPositions(strategy)
+
+		batch[strategy.ID] = &batchRun{
+			strategy:  strategy,
+			signals:   make([]engine.StockSignal, 0),
+			positions: positions,
+			tracking:  tracking,
+			params:    params,
+		}
+		runs = append(runs, engine.BatchRun{
+			Strategy: strategy,
+			Options:  engine.RunOptions{Positions: positions, Params: params},
+			Updater:  &batchUpdater{ctx: m.ctx, manager: m, strategyID: strategy.ID},
+		})
+	}
+
+	// 重置批量执行的信号列表, 停止时由本方法保存执行记录
+	m.mutex.Lock()
+	m.batch = batch
+	m.batching = true
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		m.batching = false
+		m.mutex.Unlock()
+	}()
+
+	err := m.engine.ExecuteBatch(runs, u)
+
+	// 保存执行完成或被中止的各策略结果, 某个策略保存失败不影响其余策略
+	errs := []error{err}
+	for _, status := range m.engine.GetBatchStatus() {
+		fmt.Printf("策略 %d 批量执行完成，状态: %s\n", status.StrategyId, status.Status)
+		if status.Status != engine.StatusCompleted && status.Status != engine.StatusStopped {
+			continue
+		}
 
-		// 获取当前信号列表的副本
 		m.mutex.RLock()
-		signals := make([]engine.StockSignal, len(m.signals))
-		copy(signals, m.signals)
+		run := batch[status.StrategyId]
+		signals := make([]engine.StockSignal, len(run.signals))
+		copy(signals, run.signals)
 		m.mutex.RUnlock()
 
-		// 创建执行结果
-		result := &engine.ExecutionResult{
-			StrategyID:      strategy.ID,
-			StrategyName:    strategy.Name,
-			ExecutionTime:   status.StartTime,
-			CompletionTime:  time.Now(),
-			TotalStocks:     status.TotalStocks,
-			ProcessedStocks: status.ProcessedCount,
-			Signals:         signals,
-		}
-
-		// 生成安全的文件名
-		safeName := regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(strategy.Name, "_")
-		fileName := fmt.Sprintf("strategy_%s_%s.json",
-			safeName,
-			status.StartTime.Format("20060102_150405"))
-
-		// 保存结果
-		recordDir, err := m.getRecordDir()
-		if err != nil {
-			fmt.Printf("获取记录目录失败: %v\n", err)
-			return fmt.Errorf("保存结果失败: %v", err)
+		failures := m.engine.GetBatchFailures(status.StrategyId)
+		if saveErr := m.writeExecutionResult(run.strategy.ID, run.strategy.Name, status, signals, run.positions, run.tracking, run.params, u, failures); saveErr != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", run.strategy.Name, saveErr))
 		}
-		fmt.Printf("记录目录: %s\n", recordDir)
+	}
 
-		filePath := filepath.Join(recordDir, fileName)
-		fmt.Printf("准备写入文件: %s\n", filePath)
+	return errors.Join(errs...)
+}
 
-		data, err := json.MarshalIndent(result, "", "  ")
-		if err != nil {
-			fmt.Printf("序列化结果失败: %v\n", err)
-			return fmt.Errorf("序列化结果失败: %v", err)
+// GetBatchStatus 获取当前(或最近一次)批量执行中各策略的状态
+func (m *Manager) GetBatchStatus() []engine.ExecutionStatus {
+	if m == nil || m.engine == nil {
+		return []engine.ExecutionStatus{}
+	}
+	return m.engine.GetBatchStatus()
+}
+
+// GetBatchSignals 获取当前(或最近一次)批量执行中指定策略收集到的信号
+func (m *Manager) GetBatchSignals(strategyID int) []engine.StockSignal {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	run, ok := m.batch[strategyID]
+	if !ok {
+		return []engine.StockSignal{}
+	}
+	return run.signals
+}
+
+// GetBatchFailures 获取当前(或最近一次)批量执行中指定策略处理失败的股票
+func (m *Manager) GetBatchFailures(strategyID int) engine.FailureReport {
+	if m == nil || m.engine == nil {
+		return engine.FailureReport{Groups: []engine.FailureGroup{}, Failures: []engine.StockFailure{}}
+	}
+	return m.engine.GetBatchFailures(strategyID)
+}
+
+// saveExecutionResult 将当前信号和持仓保存为执行记录
+func (m *Manager) saveExecutionResult(strategyID int, strategyName string, status engine.ExecutionStatus) error {
+	// 获取当前信号列表和持仓的副本
+	m.mutex.RLock()
+	signals := make([]engine.StockSignal, len(m.signals))
+	copy(signals, m.signals)
+	positions, tracking := m.positions, m.tracking
+	params := m.params
+	u := m.universe
+	m.mutex.RUnlock()
+
+	return m.writeExecutionResult(strategyID, strategyName, status, signals, positions, tracking, params, u, m.engine.GetFailures())
+}
+
+// writeExecutionResult 将一次执行的信号、持仓、参数和失败汇总写入执行记录, tracking为false时不记录持仓
+func (m *Manager) writeExecutionResult(strategyID int, strategyName string, status engine.ExecutionStatus, signals []engine.StockSignal, positions []engine.Position, tracking bool, params map[string]interface{}, u *universe.Universe, failures engine.FailureReport) error {
+	// 创建执行结果
+	result := &engine.ExecutionResult{
+		StrategyID:      strategyID,
+		StrategyName:    strategyName,
+		ExecutionTime:   status.StartTime,
+		CompletionTime:  time.Now(),
+		TotalStocks:     status.TotalStocks,
+		ProcessedStocks: status.ProcessedCount,
+		Signals:         signals,
+		Tags:            engine.SignalTags(signals),
+		Params:          params,
+		Failures:        &failures,
+		Universe:        u,
+	}
+	if tracking {
+		result.Positions = updatePositions(positions, signals, status.StartTime.Format("2006-01-02"))
+	}
+
+	filePath, err := m.writeRecord(recordFileName("strategy", strategyName, status.StartTime), result)
+	if err != nil {
+		fmt.Printf("保存执行结果失败: %v\n", err)
+		return fmt.Errorf("保存结果失败: %v", err)
+	}
+	fmt.Printf("执行结果已保存到: %s\n", filePath)
+
+	return nil
+}
+
+// updatePositions 根据本次信号更新持仓: 移除发出卖出信号的持仓, 加入新的买入信号
+func updatePositions(positions []engine.Position, signals []engine.StockSignal, date string) []engine.Position {
+	exited := make(map[string]bool)
+	for _, signal := range signals {
+		if signal.IsExit() {
+			exited[signal.Code] = true
 		}
+	}
 
-		if err := os.WriteFile(filePath, data, 0644); err != nil {
-			fmt.Printf("写入文件失败: %v\n", err)
-			return fmt.Errorf("写入结果文件失败: %v", err)
+	held := make(map[string]bool)
+	result := make([]engine.Position, 0, len(positions))
+	for _, pos := range positions {
+		if exited[pos.Code] {
+			continue
 		}
-		fmt.Printf("执行结果已保存到: %s\n", filePath)
+		held[pos.Code] = true
+		result = append(result, pos)
 	}
 
-	return err
+	for _, signal := range signals {
+		if signal.IsExit() || held[signal.Code] || exited[signal.Code] {
+			continue
+		}
+		entryDate := signal.Date
+		if entryDate == "" {
+			entryDate = date
+		}
+		held[signal.Code] = true
+		result = append(result, engine.Position{
+			Code:       signal.Code,
+			Name:       signal.Name,
+			EntryDate:  entryDate,
+			EntryPrice: signal.Price,
+		})
+	}
+
+	return result
+}
+
+// openPositions 定义了check_exit的策略返回上次执行后的持仓, 并跟踪本次执行后的持仓;
+// 其余策略不会发出卖出信号, 持仓只增不减, 因此不跟踪
+func (m *Manager) openPositions(strategy *engine.Strategy) ([]engine.Position, bool) {
+	if !engine.DefinesExitHook(strategy) {
+		return nil, false
+	}
+	return m.loadOpenPositions(strategy.ID), true
+}
+
+// loadOpenPositions 从该策略最近一次执行记录中加载持仓
+func (m *Manager) loadOpenPositions(strategyID int) []engine.Position {
+	records, err := m.GetExecutionRecords()
+	if err != nil {
+		return nil
+	}
+
+	for _, record := range records {
+		if record.StrategyID != strategyID {
+			continue
+		}
+		result, err := m.GetExecutionRecord(record.FileName)
+		if err != nil {
+			fmt.Printf("Warning: 加载持仓失败: %v\n", err)
+			return nil
+		}
+		return result.Positions
+	}
+
+	return nil
+}
+
+// beginBacktest 标记当前运行为回测(停止时不保存执行记录), 返回结束标记的函数
+func (m *Manager) beginBacktest() func() {
+	m.mutex.Lock()
+	m.backtest = true
+	m.mutex.Unlock()
+	return func() {
+		m.mutex.Lock()
+		m.backtest = false
+		m.mutex.Unlock()
+	}
+}
+
+// Backtest 回测策略并保存回测结果
+func (m *Manager) Backtest(strategy *engine.Strategy, config engine.BacktestConfig) (*engine.BacktestResult, error) {
+	if m.engine == nil {
+		return nil, fmt.Errorf("engine not initialized")
+	}
+
+	params, err := engine.ResolveParams(strategy.Params, config.Params)
+	if err != nil {
+		return nil, err
+	}
+	config.Params = params
+
+	defer m.beginBacktest()()
+
+	result, err := m.engine.Backtest(strategy, config, nil)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("策略回测完成，状态: %s, 信号数: %d\n", result.Status, len(result.Signals))
+
+	fileName := recordFileName("backtest", strategy.Name, result.ExecutionTime)
+	filePath, err := m.writeRecord(fileName, result)
+	if err != nil {
+		fmt.Printf("保存回测结果失败: %v\n", err)
+		return result, fmt.Errorf("保存回测结果失败: %v", err)
+	}
+	fmt.Printf("回测结果已保存到: %s\n", filePath)
+
+	return result, nil
 }
 
 // Pause 暂停执行
@@ -199,53 +567,19 @@ func (m *Manager) Stop() {
 	if m.engine != nil {
 		m.engine.Stop()
 
+		// 回测结果由Backtest自行保存, 批量执行结果由ExecuteBatch自行保存
+		m.mutex.RLock()
+		savesOwnResult := m.backtest || m.batching
+		m.mutex.RUnlock()
+		if savesOwnResult {
+			return
+		}
+
 		// 等待状态变为已停止
 		for {
 			status := m.engine.GetStatus()
 			if status.Status == engine.StatusStopped {
-				// 获取当前信号列表的副本
-				m.mutex.RLock()
-				signals := make([]engine.StockSignal, len(m.signals))
-				copy(signals, m.signals)
-				m.mutex.RUnlock()
-
-				// 创建执行结果
-				result := &engine.ExecutionResult{
-					StrategyID:      status.StrategyId,
-					StrategyName:    m.getStrategyName(status.StrategyId),
-					ExecutionTime:   status.StartTime,
-					CompletionTime:  time.Now(),
-					TotalStocks:     status.TotalStocks,
-					ProcessedStocks: status.ProcessedCount,
-					Signals:         signals,
-				}
-
-				// 生成安全的文件名
-				safeName := regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(result.StrategyName, "_")
-				fileName := fmt.Sprintf("strategy_%s_%s.json",
-					safeName,
-					status.StartTime.Format("20060102_150405"))
-
-				// 保存结果
-				recordDir, err := m.getRecordDir()
-				if err != nil {
-					fmt.Printf("获取记录目录失败: %v\n", err)
-					return
-				}
-
-				filePath := filepath.Join(recordDir, fileName)
-				data, err := json.MarshalIndent(result, "", "  ")
-				if err != nil {
-					fmt.Printf("序列化结果失败: %v\n", err)
-					return
-				}
-
-				if err := os.WriteFile(filePath, data, 0644); err != nil {
-					fmt.Printf("写入结果文件失败: %v\n", err)
-					return
-				}
-
-				fmt.Printf("执行结果已保存到: %s\n", filePath)
+				m.saveExecutionResult(status.StrategyId, m.getStrategyName(status.StrategyId), status)
 				break
 			}
 			time.Sleep(100 * time.Millisecond)
@@ -269,6 +603,22 @@ func (m *Manager) GetCurrentSignals() []engine.StockSignal {
 	return m.signals
 }
 
+// GetCurrentFailures 获取当前执行中处理失败的股票
+func (m *Manager) GetCurrentFailures() engine.FailureReport {
+	if m == nil || m.engine == nil {
+		return engine.FailureReport{Groups: []engine.FailureGroup{}, Failures: []engine.StockFailure{}}
+	}
+	return m.engine.GetFailures()
+}
+
+// failedCount 记录中处理失败的股票数, 旧记录没有失败汇总时为0
+func failedCount(report *engine.FailureReport) int {
+	if report == nil {
+		return 0
+	}
+	return report.Total
+}
+
 // Close 关闭管理器
 func (m *Manager) Close() error {
 	if m.engine != nil {
@@ -294,6 +644,8 @@ func (m *Manager) parseStrategyMeta(filePath string) (*engine.StrategyMeta, erro
 	idRegex := regexp.MustCompile(`--\s*@id:\s*(\d+)`)
 	nameRegex := regexp.MustCompile(`--\s*@name:\s*(.+)`)
 	descRegex := regexp.MustCompile(`--\s*@description:\s*(.+)`)
+	paramRegex := regexp.MustCompile(`--\s*@param:?\s+(\w+)\s+(\w+)\s+(\S+)\s+(\S+)\s+(\S+)\s*(.*)`)
+	composeRegex := regexp.MustCompile(`--\s*@compose:?\s*(.+)`)
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -324,6 +676,22 @@ func (m *Manager) parseStrategyMeta(filePath string) (*engine.StrategyMeta, erro
 			meta.Description = strings.TrimSpace(matches[1])
 			continue
 		}
+
+		// 解析参数声明
+		if matches := paramRegex.FindStringSubmatch(line); len(matches) > 1 {
+			param, err := engine.ParseStrategyParam(matches[1], matches[2], matches[3], matches[4], matches[5], matches[6])
+			if err != nil {
+				return nil, fmt.Errorf("invalid strategy param in %s: %v", filePath, err)
+			}
+			meta.Params = append(meta.Params, param)
+			continue
+		}
+
+		// 解析组合表达式
+		if matches := composeRegex.FindStringSubmatch(line); len(matches) > 1 {
+			meta.Compose = strings.TrimSpace(matches[1])
+			continue
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -357,15 +725,138 @@ func (m *Manager) GetStrategies() []engine.Strategy {
 			continue
 		}
 
-		strategies = append(strategies, engine.Strategy{
-			ID:          meta.ID,
-			Name:        meta.Name,
-			Description: meta.Description,
-			FilePath:    file,
-		})
+		strategies = append(strategies, luaStrategy(meta, file))
+	}
+
+	// 声明式选股条件与Lua策略一同列出
+	strategies = append(strategies, m.loadScreens(strategies)...)
+
+	// 组合策略关联子策略, 无效的组合策略不列出
+	valid := make([]engine.Strategy, 0, len(strategies))
+	for i := range strategies {
+		if strategies[i].Compose != "" {
+			if err := resolveComposite(&strategies[i], strategies); err != nil {
+				fmt.Printf("Warning: 组合策略 %s 无效: %v\n", strategies[i].Name, err)
+				continue
+			}
+		}
+		valid = append(valid, strategies[i])
+	}
+
+	return valid
+}
+
+// luaStrategy 根据Lua文件的元数据创建策略
+func luaStrategy(meta *engine.StrategyMeta, file string) engine.Strategy {
+	strategyType := engine.StrategyTypeLua
+	if meta.Compose != "" {
+		strategyType = engine.StrategyTypeComposite
+	}
+	return engine.Strategy{
+		ID:          meta.ID,
+		Name:        meta.Name,
+		Description: meta.Description,
+		FilePath:    file,
+		Type:        strategyType,
+		Params:      meta.Params,
+		Compose:     meta.Compose,
+	}
+}
+
+// loadScreens 加载策略目录中的声明式选股条件(*.json), 无效或ID重复的不列出
+func (m *Manager) loadScreens(existing []engine.Strategy) []engine.Strategy {
+	files, err := filepath.Glob(filepath.Join(m.basePath, "*.json"))
+	if err != nil {
+		return nil
+	}
+
+	used := make(map[int]bool, len(existing))
+	for _, s := range existing {
+		used[s.ID] = true
+	}
+
+	var screens []engine.Strategy
+	for _, file := range files {
+		screen, err := screener.Load(file)
+		if err != nil {
+			fmt.Printf("Warning: 无法加载选股条件 %s: %v\n", file, err)
+			continue
+		}
+		compiled, err := screener.Compile(*screen)
+		if err != nil {
+			fmt.Printf("Warning: 选股条件 %s 无效: %v\n", file, err)
+			continue
+		}
+		if used[screen.ID] {
+			fmt.Printf("Warning: 选股条件 %s 的ID %d 与其他策略重复\n", file, screen.ID)
+			continue
+		}
+		used[screen.ID] = true
+		screens = append(screens, compiled.Strategy(file))
+	}
+
+	return screens
+}
+
+// SaveScreen 校验并保存声明式选股条件, ID相同的选股条件会被覆盖
+func (m *Manager) SaveScreen(screen screener.Screen) (*engine.Strategy, error) {
+	compiled, err := screener.Compile(screen)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath := filepath.Join(m.basePath, fmt.Sprintf("screen_%d.json", screen.ID))
+	for _, s := range m.GetStrategies() {
+		if s.ID != screen.ID {
+			continue
+		}
+		if s.Type != engine.StrategyTypeScreen {
+			return nil, fmt.Errorf("策略ID %d 已被策略 %s 使用", screen.ID, s.Name)
+		}
+		filePath = s.FilePath
+	}
+
+	if err := screener.Save(filePath, compiled.Screen()); err != nil {
+		return nil, fmt.Errorf("保存选股条件失败: %v", err)
+	}
+
+	strategy := compiled.Strategy(filePath)
+	return &strategy, nil
+}
+
+// resolveComposite 根据组合表达式关联子策略, 子策略的参数以 "ID.NAME" 的形式作为组合策略的参数
+func resolveComposite(composite *engine.Strategy, strategies []engine.Strategy) error {
+	expr, err := engine.ParseCompose(composite.Compose)
+	if err != nil {
+		return err
+	}
+
+	composite.Components = nil
+	composite.Params = nil
+	for _, id := range expr.IDs() {
+		var component *engine.Strategy
+		for i := range strategies {
+			if strategies[i].ID == id {
+				component = &strategies[i]
+				break
+			}
+		}
+		if component == nil {
+			return fmt.Errorf("strategy not found: %d", id)
+		}
+		if component.Compose != "" {
+			return fmt.Errorf("strategy %d is also a composite strategy", id)
+		}
+
+		copied := *component
+		composite.Components = append(composite.Components, &copied)
+		for _, param := range component.Params {
+			param.Name = engine.ComponentParamName(id, param.Name)
+			composite.Params = append(composite.Params, param)
+		}
 	}
 
-	return strategies
+	return nil
 }
 
 // GetStrategyByID 根据ID获取策略
@@ -396,6 +887,110 @@ func (m *Manager) getRecordDir() (string, error) {
 	return recordDir, nil
 }
 
+// recordFileName 生成记录文件名
+func recordFileName(prefix, strategyName string, startTime time.Time) string {
+	safeName := regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(strategyName, "_")
+	return fmt.Sprintf("%s_%s_%s.json", prefix, safeName, startTime.Format("20060102_150405"))
+}
+
+// isRecordFileName 是否为指定前缀的记录文件名; 不允许包含路径分隔符或"..", 避免访问记录目录之外的文件
+func isRecordFileName(fileName, prefix string) bool {
+	if strings.ContainsAny(fileName, `/\:`) || strings.Contains(fileName, "..") || filepath.Base(fileName) != fileName {
+		return false
+	}
+	return strings.HasPrefix(fileName, prefix+"_") && strings.HasSuffix(fileName, ".json")
+}
+
+// writeRecord 将记录序列化写入记录目录, 返回文件路径
+func (m *Manager) writeRecord(fileName string, v interface{}) (string, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %v", err)
+	}
+
+	filePath := filepath.Join(recordDir, fileName)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return "", fmt.Errorf("写入结果文件失败: %v", err)
+	}
+
+	return filePath, nil
+}
+
+// GetBacktestRecords 获取回测记录列表
+func (m *Manager) GetBacktestRecords() ([]engine.ExecutionRecord, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(recordDir, "backtest_*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取记录文件: %v", err)
+	}
+
+	var records []engine.ExecutionRecord
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Warning: 无法读取文件 %s: %v\n", file, err)
+			continue
+		}
+
+		var result engine.BacktestResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			fmt.Printf("Warning: 无法解析文件 %s: %v\n", file, err)
+			continue
+		}
+
+		records = append(records, engine.ExecutionRecord{
+			FileName:       filepath.Base(file),
+			StrategyID:     result.StrategyID,
+			StrategyName:   result.StrategyName,
+			ExecutionTime:  result.ExecutionTime,
+			SignalCount:    len(result.Signals),
+			ProcessedCount: result.ProcessedStocks,
+			TotalStocks:    result.TotalStocks,
+			FailedCount:    failedCount(result.Failures),
+		})
+	}
+
+	// 按执行时间降序排序
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ExecutionTime.After(records[j].ExecutionTime)
+	})
+
+	return records, nil
+}
+
+// GetBacktestRecord 获取回测记录内容
+func (m *Manager) GetBacktestRecord(fileName string) (*engine.BacktestResult, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isRecordFileName(fileName, "backtest") {
+		return nil, fmt.Errorf("无效的文件名格式")
+	}
+
+	data, err := os.ReadFile(filepath.Join(recordDir, fileName))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取记录文件: %v", err)
+	}
+
+	var result engine.BacktestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("无法解析记录文件: %v", err)
+	}
+
+	return &result, nil
+}
+
 // GetExecutionRecords 获取执行记录列表
 func (m *Manager) GetExecutionRecords() ([]engine.ExecutionRecord, error) {
 	recordDir, err := m.getRecordDir()
@@ -430,6 +1025,7 @@ func (m *Manager) GetExecutionRecords() ([]engine.ExecutionRecord, error) {
 			SignalCount:    len(result.Signals),
 			ProcessedCount: result.ProcessedStocks,
 			TotalStocks:    result.TotalStocks,
+			FailedCount:    failedCount(result.Failures),
 		}
 		records = append(records, record)
 	}
@@ -449,7 +1045,7 @@ func (m *Manager) GetExecutionRecord(fileName string) (*engine.ExecutionResult,
 		return nil, err
 	}
 
-	if !strings.HasPrefix(fileName, "strategy_") || !strings.HasSuffix(fileName, ".json") {
+	if !isRecordFileName(fileName, "strategy") {
 		return nil, fmt.Errorf("无效的文件名格式")
 	}
 
@@ -467,6 +1063,59 @@ func (m *Manager) GetExecutionRecord(fileName string) (*engine.ExecutionResult,
 	return &result, nil
 }
 
+// QueryExecutionRecord 获取执行记录, 信号按查询条件筛选和排序
+func (m *Manager) QueryExecutionRecord(fileName string, query engine.SignalQuery) (*engine.ExecutionResult, error) {
+	result, err := m.GetExecutionRecord(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 兼容没有保存标签汇总的旧记录
+	if result.Tags == nil {
+		result.Tags = engine.SignalTags(result.Signals)
+	}
+	result.Signals = engine.QuerySignals(result.Signals, query)
+
+	return result, nil
+}
+
+// recordCodes 获取执行记录中发出信号的股票代码, 供以执行记录为来源的股票池使用
+func (m *Manager) recordCodes(fileName string) ([]string, error) {
+	result, err := m.GetExecutionRecord(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, len(result.Signals))
+	seen := make(map[string]bool, len(result.Signals))
+	for _, signal := range result.Signals {
+		if !seen[signal.Code] {
+			seen[signal.Code] = true
+			codes = append(codes, signal.Code)
+		}
+	}
+	return codes, nil
+}
+
+// LatestRecordUniverse 以策略最近一次执行记录中发出信号的股票作为股票池, 没有执行记录时返回错误
+func (m *Manager) LatestRecordUniverse(strategyID int) (*universe.Universe, error) {
+	records, err := m.GetExecutionRecords()
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.StrategyID == strategyID {
+			return &universe.Universe{Source: universe.SourceRecord, Name: record.FileName}, nil
+		}
+	}
+	return nil, fmt.Errorf("strategy %d has no execution records", strategyID)
+}
+
+// UniverseSources 解析股票池时使用的自选股列表和执行记录来源(不含API客户端), 供引擎之外的功能(如盘中监控)使用
+func (m *Manager) UniverseSources() universe.Sources {
+	return m.sources
+}
+
 // DeleteExecutionRecord 删除执行记录
 func (m *Manager) DeleteExecutionRecord(fileName string) error {
 	recordDir, err := m.getRecordDir()
@@ -474,7 +1123,7 @@ func (m *Manager) DeleteExecutionRecord(fileName string) error {
 		return err
 	}
 
-	if !strings.HasPrefix(fileName, "strategy_") || !strings.HasSuffix(fileName, ".json") {
+	if !isRecordFileName(fileName, "strategy") {
 		return fmt.Errorf("无效的文件名格式")
 	}
 
@@ -0,0 +1,547 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-helper-svelte/backend/engine"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 参数采样方式
+const (
+	SamplingGrid           = "grid"   // 网格遍历
+	SamplingRandom         = "random" // 随机采样
+	SamplingLatinHypercube = "lhs"    // 拉丁超立方采样
+)
+
+// 优化目标
+const (
+	ObjectiveHitRate    = "hitRate"    // 胜率
+	ObjectiveMeanReturn = "meanReturn" // 平均收益率
+	ObjectiveSharpe     = "sharpe"     // 夏普比率
+)
+
+const (
+	maxOptimizeTrials    = 500 // 单次优化允许的最大参数组合数
+	defaultOptimizeTrial = 20  // 随机和拉丁超立方采样的默认采样数
+)
+
+// ParamRange 参数搜索范围
+type ParamRange struct {
+	Name   string        `json:"name"`   // 参数名
+	Min    *float64      `json:"min"`    // 最小值, 为空使用参数声明的最小值
+	Max    *float64      `json:"max"`    // 最大值, 为空使用参数声明的最大值
+	Step   float64       `json:"step"`   // 步长, 整数参数默认1, 浮点参数默认将区间4等分
+	Values []interface{} `json:"values"` // 候选值, 设置后忽略Min/Max/Step(布尔和字符串参数必须设置)
+}
+
+// OptimizeConfig 参数优化配置
+type OptimizeConfig struct {
+	Backtest     engine.BacktestConfig `json:"backtest"`     // 回测配置, 其中Params为不参与搜索的固定参数
+	Ranges       []ParamRange          `json:"ranges"`       // 参与搜索的参数范围
+	Sampling     string                `json:"sampling"`     // 采样方式: grid, random, lhs
+	Samples      int                   `json:"samples"`      // 采样数(random和lhs)
+	Objective    string                `json:"objective"`    // 优化目标: hitRate, meanReturn, sharpe
+	Horizon      int                   `json:"horizon"`      // 优化目标的统计周期(交易日), 默认5
+	MinSignals   int                   `json:"minSignals"`   // 有效信号数少于此值的组合排在最后
+	Seed         int64                 `json:"seed"`         // 随机种子, 0表示使用当前时间
+	LookbackBars int                   `json:"lookbackBars"` // K线缓存保留的回看根数, 不大于0时保留完整历史(默认); 截取会改变指标预热值, 完整历史回测时信号可能不同
+}
+
+// OptimizeTrial 一组参数的回测表现
+type OptimizeTrial struct {
+	Rank        int                    `json:"rank"`            // 排名
+	Params      map[string]interface{} `json:"params"`          // 实际使用的全部参数
+	Objective   float64                `json:"objective"`       // 优化目标值
+	SignalCount int                    `json:"signalCount"`     // 目标周期内的有效信号数
+	HitRate     float64                `json:"hitRate"`         // 目标周期胜率(%)
+	MeanReturn  float64                `json:"meanReturn"`      // 目标周期平均收益率(%)
+	Sharpe      float64                `json:"sharpe"`          // 目标周期夏普比率
+	AvgDrawdown float64                `json:"avgDrawdown"`     // 平均回撤(%)
+	Horizons    []engine.HorizonStats  `json:"horizons"`        // 各周期统计
+	Status      string                 `json:"status"`          // 回测结束状态
+	Error       string                 `json:"error,omitempty"` // 回测失败原因
+}
+
+// OptimizeResult 参数优化结果
+type OptimizeResult struct {
+	StrategyID     int             `json:"strategyId"`     // 策略ID
+	StrategyName   string          `json:"strategyName"`   // 策略名称
+	Config         OptimizeConfig  `json:"config"`         // 优化配置
+	Status         string          `json:"status"`         // 结束状态
+	StartTime      time.Time       `json:"startTime"`      // 开始时间
+	CompletionTime time.Time       `json:"completionTime"` // 完成时间
+	Trials         []OptimizeTrial `json:"trials"`         // 按目标值排序的各组参数表现
+	Best           *OptimizeTrial  `json:"best"`           // 最优参数组合
+}
+
+// OptimizeProgress 参数优化进度
+type OptimizeProgress struct {
+	Completed int                    `json:"completed"` // 已完成组合数
+	Total     int                    `json:"total"`     // 总组合数
+	Params    map[string]interface{} `json:"params"`    // 最近完成的参数组合
+	Best      *OptimizeTrial         `json:"best"`      // 当前最优组合
+}
+
+// paramDimension 单个参数的搜索维度
+type paramDimension struct {
+	param  engine.StrategyParam
+	values []interface{} // 离散候选值(网格点或指定的候选值)
+	min    float64
+	max    float64
+	step   float64 // 网格步长
+	grain  float64 // 采样值对齐的粒度, 0表示不对齐
+	fixed  bool    // 是否为指定的候选值
+}
+
+// Optimize 在参数空间上逐组回测策略, 按优化目标排序并保存优化结果
+func (m *Manager) Optimize(strategy *engine.Strategy, config OptimizeConfig) (*OptimizeResult, error) {
+	candidates, err := m.prepareOptimize(strategy, &config)
+	if err != nil {
+		return nil, err
+	}
+	defer m.beginBacktest()()
+
+	// 所有组合共享同一份K线缓存, 避免重复拉取全市场数据
+	cache := engine.NewKLineCache(config.Backtest.StartDate, config.Backtest.EndDate, config.LookbackBars)
+	result, err := m.optimize(strategy, config, candidates, cache, func(progress OptimizeProgress) {
+		runtime.EventsEmit(m.ctx, "optimize:progress", progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("参数优化完成，组合数: %d, 缓存K线序列: %d\n", len(result.Trials), cache.Len())
+
+	filePath, err := m.writeRecord(recordFileName("optimize", strategy.Name, result.StartTime), result)
+	if err != nil {
+		fmt.Printf("保存优化结果失败: %v\n", err)
+		return result, fmt.Errorf("保存优化结果失败: %v", err)
+	}
+	fmt.Printf("优化结果已保存到: %s\n", filePath)
+
+	return result, nil
+}
+
+// prepareOptimize 验证优化配置并生成参数组合
+func (m *Manager) prepareOptimize(strategy *engine.Strategy, config *OptimizeConfig) ([]map[string]interface{}, error) {
+	if m.engine == nil {
+		return nil, fmt.Errorf("engine not initialized")
+	}
+
+	if err := normalizeOptimizeConfig(config); err != nil {
+		return nil, err
+	}
+	if m.engine.IsRunning() {
+		return nil, engine.ErrAlreadyRunning()
+	}
+	if _, err := engine.ResolveParams(strategy.Params, config.Backtest.Params); err != nil {
+		return nil, err
+	}
+
+	return sampleParams(strategy.Params, *config)
+}
+
+// optimize 在回测区间上逐组回测参数组合, 返回排序后的结果(不保存)
+func (m *Manager) optimize(strategy *engine.Strategy, config OptimizeConfig, candidates []map[string]interface{}, cache *engine.KLineCache, onProgress func(OptimizeProgress)) (*OptimizeResult, error) {
+	result := &OptimizeResult{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Config:       config,
+		Status:       engine.StatusCompleted,
+		StartTime:    time.Now(),
+		Trials:       make([]OptimizeTrial, 0, len(candidates)),
+	}
+
+	var best *OptimizeTrial
+	onProgress(OptimizeProgress{Total: len(candidates)})
+	for i, candidate := range candidates {
+		trial, _, err := m.runTrial(strategy, config, candidate, cache)
+		if err != nil {
+			return nil, err
+		}
+		result.Trials = append(result.Trials, trial)
+
+		if trial.Error == "" && trial.SignalCount >= config.MinSignals && (best == nil || trial.Objective > best.Objective) {
+			copied := trial
+			best = &copied
+		}
+
+		onProgress(OptimizeProgress{
+			Completed: i + 1,
+			Total:     len(candidates),
+			Params:    trial.Params,
+			Best:      best,
+		})
+
+		if trial.Status == engine.StatusStopped {
+			result.Status = engine.StatusStopped
+			break
+		}
+	}
+
+	rankTrials(result.Trials, config.MinSignals)
+	if len(result.Trials) > 0 && result.Trials[0].Error == "" && result.Trials[0].SignalCount >= config.MinSignals {
+		result.Best = &result.Trials[0]
+	}
+	result.CompletionTime = time.Now()
+
+	return result, nil
+}
+
+// runTrial 以一组参数回测策略并提取优化目标
+// 与参数无关的错误(如回测配置无效、引擎被占用)作为error返回以终止优化, 其余失败记录在结果中
+func (m *Manager) runTrial(strategy *engine.Strategy, config OptimizeConfig, candidate map[string]interface{}, cache *engine.KLineCache) (OptimizeTrial, *engine.BacktestResult, error) {
+	overrides := make(map[string]interface{}, len(config.Backtest.Params)+len(candidate))
+	for name, value := range config.Backtest.Params {
+		overrides[name] = value
+	}
+	for name, value := range candidate {
+		overrides[name] = value
+	}
+
+	trial := OptimizeTrial{Params: overrides}
+	params, err := engine.ResolveParams(strategy.Params, overrides)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial, nil, nil
+	}
+	trial.Params = params
+
+	backtestConfig := config.Backtest
+	backtestConfig.Params = params
+	backtest, err := m.engine.Backtest(strategy, backtestConfig, cache)
+	if err != nil {
+		if engineErr, ok := err.(*engine.EngineError); ok && (engineErr.Code == engine.ErrInvalidConfig || engineErr.Code == engine.ErrEngineAlreadyRunning) {
+			return trial, nil, err
+		}
+		trial.Error = err.Error()
+		return trial, nil, nil
+	}
+
+	trial.Status = backtest.Status
+	applyObjective(&trial, backtest, config)
+
+	return trial, backtest, nil
+}
+
+// applyObjective 从回测统计中提取目标周期的表现和优化目标值
+func applyObjective(trial *OptimizeTrial, backtest *engine.BacktestResult, config OptimizeConfig) {
+	trial.Horizons = backtest.Horizons
+	trial.AvgDrawdown = backtest.AvgDrawdown
+	for _, stats := range backtest.Horizons {
+		if stats.Days != config.Horizon {
+			continue
+		}
+		trial.SignalCount = stats.Count
+		trial.HitRate = stats.HitRate
+		trial.MeanReturn = stats.MeanReturn
+		trial.Sharpe = stats.Sharpe
+	}
+
+	switch config.Objective {
+	case ObjectiveHitRate:
+		trial.Objective = trial.HitRate
+	case ObjectiveMeanReturn:
+		trial.Objective = trial.MeanReturn
+	case ObjectiveSharpe:
+		trial.Objective = trial.Sharpe
+	}
+}
+
+// rankTrials 按目标值降序排序, 失败或信号不足的组合排在最后
+func rankTrials(trials []OptimizeTrial, minSignals int) {
+	valid := func(t OptimizeTrial) bool {
+		return t.Error == "" && t.SignalCount >= minSignals
+	}
+	sort.SliceStable(trials, func(i, j int) bool {
+		if valid(trials[i]) != valid(trials[j]) {
+			return valid(trials[i])
+		}
+		return trials[i].Objective > trials[j].Objective
+	})
+	for i := range trials {
+		trials[i].Rank = i + 1
+	}
+}
+
+// normalizeOptimizeConfig 填充默认值并验证优化配置
+func normalizeOptimizeConfig(config *OptimizeConfig) error {
+	if len(config.Ranges) == 0 {
+		return fmt.Errorf("至少需要指定一个参数搜索范围")
+	}
+	if config.Sampling == "" {
+		config.Sampling = SamplingGrid
+	}
+	if config.Objective == "" {
+		config.Objective = ObjectiveHitRate
+	}
+	if config.Horizon == 0 {
+		config.Horizon = 5
+	}
+	if config.Samples <= 0 {
+		config.Samples = defaultOptimizeTrial
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+
+	switch config.Sampling {
+	case SamplingGrid, SamplingRandom, SamplingLatinHypercube:
+	default:
+		return fmt.Errorf("不支持的采样方式: %s", config.Sampling)
+	}
+	switch config.Objective {
+	case ObjectiveHitRate, ObjectiveMeanReturn, ObjectiveSharpe:
+	default:
+		return fmt.Errorf("不支持的优化目标: %s", config.Objective)
+	}
+
+	validHorizon := false
+	for _, days := range engine.BacktestHorizons {
+		if days == config.Horizon {
+			validHorizon = true
+		}
+	}
+	if !validHorizon {
+		return fmt.Errorf("不支持的统计周期: %d", config.Horizon)
+	}
+	if config.Samples > maxOptimizeTrials {
+		return fmt.Errorf("采样数 %d 超过上限 %d", config.Samples, maxOptimizeTrials)
+	}
+
+	return nil
+}
+
+// sampleParams 根据采样方式生成去重后的参数组合
+func sampleParams(declared []engine.StrategyParam, config OptimizeConfig) ([]map[string]interface{}, error) {
+	dims, err := buildDimensions(declared, config.Ranges)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(config.Seed))
+	var candidates []map[string]interface{}
+	switch config.Sampling {
+	case SamplingGrid:
+		total := 1
+		for _, dim := range dims {
+			total *= len(dim.values)
+			if total > maxOptimizeTrials {
+				return nil, fmt.Errorf("参数组合数超过上限 %d, 请缩小范围或增大步长", maxOptimizeTrials)
+			}
+		}
+		candidates = gridSample(dims)
+
+	case SamplingRandom:
+		for i := 0; i < config.Samples; i++ {
+			candidate := make(map[string]interface{}, len(dims))
+			for _, dim := range dims {
+				candidate[dim.param.Name] = dim.at(rng.Float64())
+			}
+			candidates = append(candidates, candidate)
+		}
+
+	case SamplingLatinHypercube:
+		// 每个维度等分为Samples个区间, 每个区间恰好采样一次
+		perms := make([][]int, len(dims))
+		for d := range dims {
+			perms[d] = rng.Perm(config.Samples)
+		}
+		for i := 0; i < config.Samples; i++ {
+			candidate := make(map[string]interface{}, len(dims))
+			for d, dim := range dims {
+				u := (float64(perms[d][i]) + rng.Float64()) / float64(config.Samples)
+				candidate[dim.param.Name] = dim.at(u)
+			}
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return dedupeCandidates(candidates), nil
+}
+
+// buildDimensions 根据参数声明和搜索范围构建搜索维度
+func buildDimensions(declared []engine.StrategyParam, ranges []ParamRange) ([]paramDimension, error) {
+	index := make(map[string]engine.StrategyParam, len(declared))
+	for _, param := range declared {
+		index[param.Name] = param
+	}
+
+	dims := make([]paramDimension, 0, len(ranges))
+	seen := make(map[string]bool)
+	for _, r := range ranges {
+		param, ok := index[r.Name]
+		if !ok {
+			return nil, fmt.Errorf("策略未声明参数: %s", r.Name)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("参数重复: %s", r.Name)
+		}
+		seen[r.Name] = true
+
+		dim := paramDimension{param: param}
+		if len(r.Values) > 0 {
+			for _, value := range r.Values {
+				coerced, err := param.Coerce(value)
+				if err != nil {
+					return nil, fmt.Errorf("参数 %s 候选值无效: %v", r.Name, err)
+				}
+				dim.values = append(dim.values, coerced)
+			}
+			dim.fixed = true
+			dims = append(dims, dim)
+			continue
+		}
+
+		if !param.IsNumeric() {
+			return nil, fmt.Errorf("参数 %s 不是数值类型, 需要指定候选值", r.Name)
+		}
+
+		min, max := param.Min, param.Max
+		if r.Min != nil {
+			min = r.Min
+		}
+		if r.Max != nil {
+			max = r.Max
+		}
+		if min == nil || max == nil {
+			return nil, fmt.Errorf("参数 %s 缺少搜索范围", r.Name)
+		}
+		if *max < *min {
+			return nil, fmt.Errorf("参数 %s 的最大值小于最小值", r.Name)
+		}
+		dim.min, dim.max = *min, *max
+
+		dim.step = r.Step
+		if dim.step <= 0 {
+			if param.Type == engine.ParamTypeInt {
+				dim.step = 1
+			} else {
+				dim.step = (dim.max - dim.min) / 4
+			}
+		}
+		if r.Step > 0 || param.Type == engine.ParamTypeInt {
+			dim.grain = dim.step
+		}
+
+		if dim.step <= 0 {
+			dim.values = []interface{}{dim.min}
+		} else {
+			for i := 0; ; i++ {
+				value := dim.min + float64(i)*dim.step
+				if value > dim.max+1e-9 {
+					break
+				}
+				dim.values = append(dim.values, dim.snap(value))
+				if len(dim.values) > maxOptimizeTrials {
+					return nil, fmt.Errorf("参数 %s 的网格点过多, 请增大步长", r.Name)
+				}
+			}
+		}
+		dims = append(dims, dim)
+	}
+
+	return dims, nil
+}
+
+// at 将[0,1)上的采样点映射为参数值
+func (d paramDimension) at(u float64) interface{} {
+	if d.fixed {
+		idx := int(u * float64(len(d.values)))
+		if idx >= len(d.values) {
+			idx = len(d.values) - 1
+		}
+		return d.values[idx]
+	}
+	return d.snap(d.min + u*(d.max-d.min))
+}
+
+// snap 将数值对齐到采样粒度, 整数参数取整
+func (d paramDimension) snap(value float64) float64 {
+	if d.grain > 0 {
+		value = d.min + math.Round((value-d.min)/d.grain)*d.grain
+	}
+	if d.param.Type == engine.ParamTypeInt {
+		value = math.Round(value)
+	}
+	value = math.Round(value*1e8) / 1e8
+	return math.Max(d.min, math.Min(d.max, value))
+}
+
+// gridSample 生成全部网格组合
+func gridSample(dims []paramDimension) []map[string]interface{} {
+	candidates := []map[string]interface{}{{}}
+	for _, dim := range dims {
+		next := make([]map[string]interface{}, 0, len(candidates)*len(dim.values))
+		for _, base := range candidates {
+			for _, value := range dim.values {
+				candidate := make(map[string]interface{}, len(base)+1)
+				for name, v := range base {
+					candidate[name] = v
+				}
+				candidate[dim.param.Name] = value
+				next = append(next, candidate)
+			}
+		}
+		candidates = next
+	}
+	return candidates
+}
+
+// dedupeCandidates 去除取整后重复的参数组合, 保持原顺序
+func dedupeCandidates(candidates []map[string]interface{}) []map[string]interface{} {
+	seen := make(map[string]bool, len(candidates))
+	result := make([]map[string]interface{}, 0, len(candidates))
+	for _, candidate := range candidates {
+		names := make([]string, 0, len(candidate))
+		for name := range candidate {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		parts := make([]string, len(names))
+		for i, name := range names {
+			parts[i] = fmt.Sprintf("%s=%v", name, candidate[name])
+		}
+		key := strings.Join(parts, ",")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, candidate)
+	}
+	return result
+}
+
+// GetOptimizeRecord 获取参数优化记录内容
+func (m *Manager) GetOptimizeRecord(fileName string) (*OptimizeResult, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isRecordFileName(fileName, "optimize") {
+		return nil, fmt.Errorf("无效的文件名格式")
+	}
+
+	data, err := os.ReadFile(filepath.Join(recordDir, fileName))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取记录文件: %v", err)
+	}
+
+	var result OptimizeResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("无法解析记录文件: %v", err)
+	}
+
+	return &result, nil
+}
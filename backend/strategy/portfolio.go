@@ -0,0 +1,101 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"stock-helper-svelte/backend/api/types"
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/portfolio"
+)
+
+// loadRecordSignals 从执行记录或回测记录中加载信号, 返回信号和策略名称
+func (m *Manager) loadRecordSignals(fileName string) ([]engine.StockSignal, string, error) {
+	switch {
+	case strings.HasPrefix(fileName, "strategy_"):
+		result, err := m.GetExecutionRecord(fileName)
+		if err != nil {
+			return nil, "", err
+		}
+		// 实盘选股信号没有日期, 以执行日期作为信号日
+		date := result.ExecutionTime.Format("2006-01-02")
+		signals := make([]engine.StockSignal, len(result.Signals))
+		for i, signal := range result.Signals {
+			if signal.Date == "" {
+				signal.Date = date
+			}
+			signals[i] = signal
+		}
+		return signals, result.StrategyName, nil
+
+	case strings.HasPrefix(fileName, "backtest_"):
+		result, err := m.GetBacktestRecord(fileName)
+		if err != nil {
+			return nil, "", err
+		}
+		signals := make([]engine.StockSignal, len(result.Signals))
+		for i, signal := range result.Signals {
+			signals[i] = signal.StockSignal
+		}
+		return signals, result.StrategyName, nil
+
+	default:
+		return nil, "", fmt.Errorf("无效的文件名格式")
+	}
+}
+
+// SimulatePortfolio 基于执行记录或回测记录的信号进行组合模拟, 结果保存到记录目录
+func (m *Manager) SimulatePortfolio(fileName string, config portfolio.Config) (*portfolio.Result, error) {
+	signals, strategyName, err := m.loadRecordSignals(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	loader := func(ctx context.Context, code string, freq types.KLineFreq) ([]types.KLineData, error) {
+		return m.apiClient.Market.GetKLineData(ctx, code, freq)
+	}
+
+	result, err := portfolio.Simulate(m.ctx, signals, config, loader)
+	if err != nil {
+		return nil, fmt.Errorf("组合模拟失败: %v", err)
+	}
+	result.Source = fileName
+
+	filePath, err := m.writeRecord(recordFileName("portfolio", strategyName, time.Now()), result)
+	if err != nil {
+		fmt.Printf("保存组合模拟结果失败: %v\n", err)
+		return result, fmt.Errorf("保存组合模拟结果失败: %v", err)
+	}
+	fmt.Printf("组合模拟结果已保存到: %s\n", filePath)
+
+	return result, nil
+}
+
+// GetPortfolioRecord 获取组合模拟记录内容
+func (m *Manager) GetPortfolioRecord(fileName string) (*portfolio.Result, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isRecordFileName(fileName, "portfolio") {
+		return nil, fmt.Errorf("无效的文件名格式")
+	}
+
+	data, err := os.ReadFile(filepath.Join(recordDir, fileName))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取记录文件: %v", err)
+	}
+
+	var result portfolio.Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("无法解析记录文件: %v", err)
+	}
+
+	return &result, nil
+}
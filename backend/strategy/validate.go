@@ -0,0 +1,159 @@
+package strategy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"stock-helper-svelte/backend/engine"
+	"stock-helper-svelte/backend/screener"
+)
+
+// ValidationResult 策略检查结果
+type ValidationResult struct {
+	StrategyID  int                 `json:"strategyId"`
+	Name        string              `json:"name"`
+	FilePath    string              `json:"filePath"`
+	Type        string              `json:"type"`
+	Valid       bool                `json:"valid"` // 没有error级别的诊断
+	Diagnostics []engine.Diagnostic `json:"diagnostics"`
+}
+
+// strategyFile 策略目录中的一个策略文件及其元数据
+type strategyFile struct {
+	path     string
+	strategy engine.Strategy
+	screen   *screener.Screen // 声明式选股条件, Lua策略为nil
+}
+
+// ValidateStrategy 执行前检查策略: 元数据在策略目录中是否唯一, Lua策略能否在沙箱中编译加载、
+// 是否定义了process_stock(或score_stock/select)以及引用的api成员是否存在
+func (m *Manager) ValidateStrategy(id int) (*ValidationResult, error) {
+	files := m.strategyFiles()
+
+	var target *strategyFile
+	for i := range files {
+		if files[i].strategy.ID == id {
+			target = &files[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("strategy not found: %d", id)
+	}
+
+	strategy := target.strategy
+	result := &ValidationResult{
+		StrategyID:  strategy.ID,
+		Name:        strategy.Name,
+		FilePath:    target.path,
+		Type:        strategy.Type,
+		Diagnostics: make([]engine.Diagnostic, 0),
+	}
+
+	// 元数据唯一性
+	for _, other := range files {
+		if other.path == target.path {
+			continue
+		}
+		if other.strategy.ID == strategy.ID {
+			result.Diagnostics = append(result.Diagnostics, engine.Diagnostic{
+				Line:     headerLine(target.path, "@id"),
+				Severity: engine.DiagnosticError,
+				Message:  fmt.Sprintf("策略ID %d 与 %s 重复", strategy.ID, filepath.Base(other.path)),
+			})
+		}
+		if other.strategy.Name == strategy.Name {
+			result.Diagnostics = append(result.Diagnostics, engine.Diagnostic{
+				Line:     headerLine(target.path, "@name"),
+				Severity: engine.DiagnosticError,
+				Message:  fmt.Sprintf("策略名称 %s 与 %s 重复", strategy.Name, filepath.Base(other.path)),
+			})
+		}
+	}
+
+	switch strategy.Type {
+	case engine.StrategyTypeScreen:
+		if _, err := screener.Compile(*target.screen); err != nil {
+			result.Diagnostics = append(result.Diagnostics, engine.Diagnostic{Severity: engine.DiagnosticError, Message: err.Error()})
+		}
+	case engine.StrategyTypeComposite:
+		strategies := make([]engine.Strategy, 0, len(files))
+		for _, file := range files {
+			strategies = append(strategies, file.strategy)
+		}
+		if err := resolveComposite(&strategy, strategies); err != nil {
+			result.Diagnostics = append(result.Diagnostics, engine.Diagnostic{
+				Line:     headerLine(target.path, "@compose"),
+				Severity: engine.DiagnosticError,
+				Message:  fmt.Sprintf("组合表达式无效: %v", err),
+			})
+		}
+		result.Diagnostics = append(result.Diagnostics, engine.LintStrategy(&strategy)...)
+	default:
+		result.Diagnostics = append(result.Diagnostics, engine.LintStrategy(&strategy)...)
+	}
+
+	result.Valid = true
+	for _, d := range result.Diagnostics {
+		if d.Severity == engine.DiagnosticError {
+			result.Valid = false
+			break
+		}
+	}
+	return result, nil
+}
+
+// strategyFiles 列出策略目录中全部可解析元数据的策略文件, 不去除ID重复或无效的组合策略
+func (m *Manager) strategyFiles() []strategyFile {
+	var files []strategyFile
+
+	luaFiles, _ := filepath.Glob(filepath.Join(m.basePath, "*.lua"))
+	for _, file := range luaFiles {
+		meta, err := m.parseStrategyMeta(file)
+		if err != nil {
+			continue
+		}
+		files = append(files, strategyFile{path: file, strategy: luaStrategy(meta, file)})
+	}
+
+	screenFiles, _ := filepath.Glob(filepath.Join(m.basePath, "*.json"))
+	for _, file := range screenFiles {
+		screen, err := screener.Load(file)
+		if err != nil {
+			continue
+		}
+		files = append(files, strategyFile{path: file, strategy: engine.Strategy{
+			ID:          screen.ID,
+			Name:        screen.Name,
+			Description: screen.Description,
+			FilePath:    file,
+			Type:        engine.StrategyTypeScreen,
+		}, screen: screen})
+	}
+
+	return files
+}
+
+// headerLine 获取文件头注释中元数据标记所在的行号, 未找到时返回0
+func headerLine(filePath, tag string) int {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, "--") {
+			break
+		}
+		if strings.Contains(text, tag) {
+			return line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,366 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"stock-helper-svelte/backend/engine"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 滚动窗口阶段
+const (
+	PhaseInSample    = "inSample"    // 样本内优化
+	PhaseOutOfSample = "outOfSample" // 样本外验证
+)
+
+// WalkForwardConfig 滚动前推分析配置
+type WalkForwardConfig struct {
+	Optimize          OptimizeConfig `json:"optimize"`          // 参数搜索配置, 其中Backtest的起止日期为整个分析区间
+	InSampleMonths    int            `json:"inSampleMonths"`    // 样本内窗口长度(月), 默认12
+	OutOfSampleMonths int            `json:"outOfSampleMonths"` // 样本外窗口长度(月), 默认3
+	StepMonths        int            `json:"stepMonths"`        // 窗口滚动步长(月), 默认等于样本外窗口长度
+	Anchored          bool           `json:"anchored"`          // 样本内窗口起点是否固定(扩展窗口)
+}
+
+// WalkForwardWindow 单个滚动窗口的结果
+type WalkForwardWindow struct {
+	Index            int            `json:"index"`            // 窗口序号(从1开始)
+	InSampleStart    string         `json:"inSampleStart"`    // 样本内开始日期
+	InSampleEnd      string         `json:"inSampleEnd"`      // 样本内结束日期
+	OutOfSampleStart string         `json:"outOfSampleStart"` // 样本外开始日期
+	OutOfSampleEnd   string         `json:"outOfSampleEnd"`   // 样本外结束日期
+	InSample         *OptimizeTrial `json:"inSample"`         // 样本内最优参数及表现, 为空表示没有满足条件的组合
+	OutOfSample      *OptimizeTrial `json:"outOfSample"`      // 最优参数在样本外的表现
+	Trials           int            `json:"trials"`           // 样本内回测的组合数
+}
+
+// WalkForwardSummary 拼接所有样本外窗口后的表现
+type WalkForwardSummary struct {
+	Windows               int                   `json:"windows"`               // 有样本外结果的窗口数
+	SignalCount           int                   `json:"signalCount"`           // 目标周期内的有效信号数
+	Objective             float64               `json:"objective"`             // 样本外优化目标值
+	HitRate               float64               `json:"hitRate"`               // 目标周期胜率(%)
+	MeanReturn            float64               `json:"meanReturn"`            // 目标周期平均收益率(%)
+	Sharpe                float64               `json:"sharpe"`                // 目标周期夏普比率
+	AvgDrawdown           float64               `json:"avgDrawdown"`           // 平均回撤(%)
+	MaxDrawdown           float64               `json:"maxDrawdown"`           // 最大回撤(%)
+	Horizons              []engine.HorizonStats `json:"horizons"`              // 各周期统计
+	MeanInSampleObjective float64               `json:"meanInSampleObjective"` // 各窗口样本内最优目标值的均值
+	Efficiency            float64               `json:"efficiency"`            // 前推效率: 样本外目标值 / 样本内目标值均值
+}
+
+// ParamStability 参数在各窗口间的稳定性
+type ParamStability struct {
+	Name       string        `json:"name"`       // 参数名
+	Values     []interface{} `json:"values"`     // 各窗口选出的参数值
+	Changes    int           `json:"changes"`    // 相邻窗口间的变化次数
+	MostCommon interface{}   `json:"mostCommon"` // 出现最多的取值
+	Frequency  float64       `json:"frequency"`  // 出现最多的取值所占比例(%)
+	Mean       float64       `json:"mean"`       // 均值(仅数值参数)
+	StdDev     float64       `json:"stdDev"`     // 标准差(仅数值参数)
+	CV         float64       `json:"cv"`         // 变异系数(仅数值参数)
+}
+
+// WalkForwardResult 滚动前推分析结果
+type WalkForwardResult struct {
+	StrategyID     int                 `json:"strategyId"`     // 策略ID
+	StrategyName   string              `json:"strategyName"`   // 策略名称
+	Config         WalkForwardConfig   `json:"config"`         // 分析配置
+	Status         string              `json:"status"`         // 结束状态
+	StartTime      time.Time           `json:"startTime"`      // 开始时间
+	CompletionTime time.Time           `json:"completionTime"` // 完成时间
+	Windows        []WalkForwardWindow `json:"windows"`        // 各窗口结果
+	OutOfSample    WalkForwardSummary  `json:"outOfSample"`    // 拼接后的样本外表现
+	Stability      []ParamStability    `json:"stability"`      // 参数稳定性
+}
+
+// WalkForwardProgress 滚动前推分析进度
+type WalkForwardProgress struct {
+	Window    int    `json:"window"`    // 当前窗口序号
+	Windows   int    `json:"windows"`   // 总窗口数
+	Phase     string `json:"phase"`     // 当前阶段: inSample, outOfSample
+	Completed int    `json:"completed"` // 当前窗口已完成的组合数
+	Total     int    `json:"total"`     // 当前窗口总组合数
+}
+
+// walkForwardWindow 窗口日期区间
+type walkForwardWindow struct {
+	inStart, inEnd, outStart, outEnd time.Time
+}
+
+// WalkForward 在滚动窗口上进行样本内优化和样本外验证, 并保存分析结果
+func (m *Manager) WalkForward(strategy *engine.Strategy, config WalkForwardConfig) (*WalkForwardResult, error) {
+	windows, err := buildWalkForwardWindows(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := m.prepareOptimize(strategy, &config.Optimize)
+	if err != nil {
+		return nil, err
+	}
+	defer m.beginBacktest()()
+
+	result := &WalkForwardResult{
+		StrategyID:   strategy.ID,
+		StrategyName: strategy.Name,
+		Config:       config,
+		Status:       engine.StatusCompleted,
+		StartTime:    time.Now(),
+		Windows:      make([]WalkForwardWindow, 0, len(windows)),
+	}
+
+	// 整个分析区间共享一份K线缓存
+	cache := engine.NewKLineCache(config.Optimize.Backtest.StartDate, config.Optimize.Backtest.EndDate, config.Optimize.LookbackBars)
+	var outOfSample []engine.BacktestSignal
+
+	for i, window := range windows {
+		current := WalkForwardWindow{
+			Index:            i + 1,
+			InSampleStart:    window.inStart.Format("2006-01-02"),
+			InSampleEnd:      window.inEnd.Format("2006-01-02"),
+			OutOfSampleStart: window.outStart.Format("2006-01-02"),
+			OutOfSampleEnd:   window.outEnd.Format("2006-01-02"),
+		}
+
+		// 样本内优化
+		inConfig := config.Optimize
+		inConfig.Backtest.StartDate = current.InSampleStart
+		inConfig.Backtest.EndDate = current.InSampleEnd
+		inConfig.Backtest.ScoreEndDate = current.InSampleEnd // 样本内信号只用样本内的价格计算收益
+		optimized, err := m.optimize(strategy, inConfig, candidates, cache, func(progress OptimizeProgress) {
+			runtime.EventsEmit(m.ctx, "walkforward:progress", WalkForwardProgress{
+				Window:    current.Index,
+				Windows:   len(windows),
+				Phase:     PhaseInSample,
+				Completed: progress.Completed,
+				Total:     progress.Total,
+			})
+		})
+		if err != nil {
+			return nil, err
+		}
+		current.Trials = len(optimized.Trials)
+		current.InSample = optimized.Best
+
+		if optimized.Status == engine.StatusStopped {
+			result.Status = engine.StatusStopped
+			result.Windows = append(result.Windows, current)
+			break
+		}
+
+		// 以样本内最优参数进行样本外验证
+		if current.InSample != nil {
+			runtime.EventsEmit(m.ctx, "walkforward:progress", WalkForwardProgress{
+				Window:  current.Index,
+				Windows: len(windows),
+				Phase:   PhaseOutOfSample,
+			})
+
+			outConfig := config.Optimize
+			outConfig.Backtest.StartDate = current.OutOfSampleStart
+			outConfig.Backtest.EndDate = current.OutOfSampleEnd
+			trial, backtest, err := m.runTrial(strategy, outConfig, current.InSample.Params, cache)
+			if err != nil {
+				return nil, err
+			}
+			current.OutOfSample = &trial
+			if backtest != nil {
+				outOfSample = append(outOfSample, backtest.Signals...)
+			}
+
+			if trial.Status == engine.StatusStopped {
+				result.Status = engine.StatusStopped
+				result.Windows = append(result.Windows, current)
+				break
+			}
+		}
+
+		result.Windows = append(result.Windows, current)
+	}
+
+	result.OutOfSample = summarizeWalkForward(result.Windows, outOfSample, config.Optimize)
+	result.Stability = paramStability(strategy.Params, config.Optimize.Ranges, result.Windows)
+	result.CompletionTime = time.Now()
+	fmt.Printf("滚动前推分析完成，窗口数: %d, 样本外信号数: %d\n", len(result.Windows), len(outOfSample))
+
+	filePath, err := m.writeRecord(recordFileName("walkforward", strategy.Name, result.StartTime), result)
+	if err != nil {
+		fmt.Printf("保存滚动前推分析结果失败: %v\n", err)
+		return result, fmt.Errorf("保存滚动前推分析结果失败: %v", err)
+	}
+	fmt.Printf("滚动前推分析结果已保存到: %s\n", filePath)
+
+	return result, nil
+}
+
+// buildWalkForwardWindows 填充默认值并按月划分样本内外窗口
+func buildWalkForwardWindows(config *WalkForwardConfig) ([]walkForwardWindow, error) {
+	if config.InSampleMonths <= 0 {
+		config.InSampleMonths = 12
+	}
+	if config.OutOfSampleMonths <= 0 {
+		config.OutOfSampleMonths = 3
+	}
+	if config.StepMonths <= 0 {
+		config.StepMonths = config.OutOfSampleMonths
+	}
+
+	start, err := time.Parse("2006-01-02", config.Optimize.Backtest.StartDate)
+	if err != nil {
+		return nil, engine.NewInvalidConfigError("StartDate", err)
+	}
+	end, err := time.Parse("2006-01-02", config.Optimize.Backtest.EndDate)
+	if err != nil {
+		return nil, engine.NewInvalidConfigError("EndDate", err)
+	}
+
+	var windows []walkForwardWindow
+	for i := 0; ; i++ {
+		window := walkForwardWindow{inStart: start.AddDate(0, i*config.StepMonths, 0)}
+		if config.Anchored {
+			window.inStart = start
+		}
+		window.inEnd = start.AddDate(0, i*config.StepMonths+config.InSampleMonths, -1)
+		window.outStart = window.inEnd.AddDate(0, 0, 1)
+		window.outEnd = window.outStart.AddDate(0, config.OutOfSampleMonths, -1)
+
+		if window.outStart.After(end) {
+			break
+		}
+		if window.outEnd.After(end) {
+			window.outEnd = end
+		}
+		windows = append(windows, window)
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("分析区间不足以划分样本内外窗口, 至少需要 %d 个月", config.InSampleMonths+1)
+	}
+
+	return windows, nil
+}
+
+// summarizeWalkForward 拼接样本外信号并计算整体表现
+func summarizeWalkForward(windows []WalkForwardWindow, signals []engine.BacktestSignal, config OptimizeConfig) WalkForwardSummary {
+	stitched := &engine.BacktestResult{Signals: signals}
+	engine.SummarizeBacktest(stitched)
+
+	var stats OptimizeTrial
+	applyObjective(&stats, stitched, config)
+
+	summary := WalkForwardSummary{
+		SignalCount: stats.SignalCount,
+		Objective:   stats.Objective,
+		HitRate:     stats.HitRate,
+		MeanReturn:  stats.MeanReturn,
+		Sharpe:      stats.Sharpe,
+		AvgDrawdown: stitched.AvgDrawdown,
+		MaxDrawdown: stitched.MaxDrawdown,
+		Horizons:    stitched.Horizons,
+	}
+
+	var sumInSample float64
+	for _, window := range windows {
+		if window.InSample == nil || window.OutOfSample == nil {
+			continue
+		}
+		summary.Windows++
+		sumInSample += window.InSample.Objective
+	}
+	if summary.Windows > 0 {
+		summary.MeanInSampleObjective = sumInSample / float64(summary.Windows)
+	}
+	if summary.MeanInSampleObjective > 0 {
+		summary.Efficiency = summary.Objective / summary.MeanInSampleObjective
+	}
+
+	return summary
+}
+
+// paramStability 统计参与搜索的参数在各窗口间的取值变化
+func paramStability(declared []engine.StrategyParam, ranges []ParamRange, windows []WalkForwardWindow) []ParamStability {
+	index := make(map[string]engine.StrategyParam, len(declared))
+	for _, param := range declared {
+		index[param.Name] = param
+	}
+
+	result := make([]ParamStability, 0, len(ranges))
+	for _, r := range ranges {
+		stability := ParamStability{Name: r.Name, Values: make([]interface{}, 0, len(windows))}
+		counts := make(map[string]int)
+		var numbers []float64
+		for _, window := range windows {
+			if window.InSample == nil {
+				continue
+			}
+			value := window.InSample.Params[r.Name]
+			if n := len(stability.Values); n > 0 && fmt.Sprint(stability.Values[n-1]) != fmt.Sprint(value) {
+				stability.Changes++
+			}
+			stability.Values = append(stability.Values, value)
+
+			key := fmt.Sprint(value)
+			counts[key]++
+			if counts[key] > counts[fmt.Sprint(stability.MostCommon)] || stability.MostCommon == nil {
+				stability.MostCommon = value
+			}
+			if number, ok := value.(float64); ok {
+				numbers = append(numbers, number)
+			}
+		}
+
+		if len(stability.Values) > 0 {
+			stability.Frequency = float64(counts[fmt.Sprint(stability.MostCommon)]) / float64(len(stability.Values)) * 100
+		}
+		if index[r.Name].IsNumeric() && len(numbers) > 0 {
+			var sum float64
+			for _, number := range numbers {
+				sum += number
+			}
+			stability.Mean = sum / float64(len(numbers))
+			var variance float64
+			for _, number := range numbers {
+				variance += (number - stability.Mean) * (number - stability.Mean)
+			}
+			stability.StdDev = math.Sqrt(variance / float64(len(numbers)))
+			if stability.Mean != 0 {
+				stability.CV = stability.StdDev / math.Abs(stability.Mean)
+			}
+		}
+
+		result = append(result, stability)
+	}
+
+	return result
+}
+
+// GetWalkForwardRecord 获取滚动前推分析记录内容
+func (m *Manager) GetWalkForwardRecord(fileName string) (*WalkForwardResult, error) {
+	recordDir, err := m.getRecordDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isRecordFileName(fileName, "walkforward") {
+		return nil, fmt.Errorf("无效的文件名格式")
+	}
+
+	data, err := os.ReadFile(filepath.Join(recordDir, fileName))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取记录文件: %v", err)
+	}
+
+	var result WalkForwardResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("无法解析记录文件: %v", err)
+	}
+
+	return &result, nil
+}
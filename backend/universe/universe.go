@@ -0,0 +1,269 @@
+package universe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"stock-helper-svelte/backend/api"
+	"stock-helper-svelte/backend/api/types"
+)
+
+// 股票池来源
+const (
+	SourceAll       = "all"       // 全部A股
+	SourceWatchlist = "watchlist" // 自选股列表, Name为列表名称
+	SourceIndex     = "index"     // 指数成分股, Name为指数代码
+	SourceRecord    = "record"    // 执行记录中发出信号的股票, Name为记录文件名
+	SourceCodes     = "codes"     // 指定的股票代码, 见Codes
+)
+
+// lookupConcurrency 逐只股票查询公司信息或实时行情时的并发数
+const lookupConcurrency = 16
+
+// Universe 策略运行的股票池: 先按来源选出股票, 再按规则排除
+type Universe struct {
+	Source string   `json:"source"`          // 来源, 见Source常量, 为空时为全部A股
+	Name   string   `json:"name,omitempty"`  // 自选股列表名称、指数代码或执行记录文件名
+	Codes  []string `json:"codes,omitempty"` // 指定的股票代码(source=codes)
+	Rules  Rules    `json:"rules"`           // 排除规则
+}
+
+// Rules 股票池的排除规则, 零值表示不排除任何股票
+type Rules struct {
+	ExcludeST        bool `json:"excludeST"`        // 排除ST和退市整理股票
+	ExcludeBSE       bool `json:"excludeBSE"`       // 排除北交所股票(8x/4x/92x)
+	MinListDays      int  `json:"minListDays"`      // 排除上市不满指定自然日数的新股, 0表示不限制
+	ExcludeSuspended bool `json:"excludeSuspended"` // 排除停牌股票(实时行情成交量为0)
+}
+
+// Sources 解析股票池时使用的数据来源
+type Sources struct {
+	Client    *api.Client
+	Watchlist func(name string) ([]string, error)     // 获取自选股列表中的股票代码, 为空时不支持watchlist来源
+	Record    func(fileName string) ([]string, error) // 获取执行记录中发出信号的股票代码, 为空时不支持record来源
+	AsOf      time.Time                               // 按上市天数排除新股的基准日期, 为零值时为当前时间; 回测时为回测开始日期, 避免前视偏差
+}
+
+// Default 默认股票池: 沪深主板、创业板和科创板, 排除ST和退市股票
+func Default() Universe {
+	return Universe{
+		Source: SourceAll,
+		Rules:  Rules{ExcludeST: true, ExcludeBSE: true},
+	}
+}
+
+// String 股票池的简短描述
+func (u Universe) String() string {
+	switch u.Source {
+	case "", SourceAll:
+		return "全部A股"
+	case SourceCodes:
+		return fmt.Sprintf("指定股票(%d只)", len(u.Codes))
+	default:
+		return fmt.Sprintf("%s:%s", u.Source, u.Name)
+	}
+}
+
+// Resolve 解析股票池, 返回按股票列表顺序排列的股票
+func (u Universe) Resolve(ctx context.Context, sources Sources) ([]types.Index, error) {
+	if sources.Client == nil {
+		return nil, fmt.Errorf("API client is required")
+	}
+
+	all, err := sources.Client.Market.GetIndexList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取股票列表失败: %v", err)
+	}
+
+	var stocks []types.Index
+	switch u.Source {
+	case "", SourceAll:
+		for _, stock := range all {
+			if IsAShare(stock.Code) {
+				stocks = append(stocks, stock)
+			}
+		}
+	case SourceWatchlist:
+		if sources.Watchlist == nil {
+			return nil, fmt.Errorf("watchlist universe is not supported")
+		}
+		codes, err := sources.Watchlist(u.Name)
+		if err != nil {
+			return nil, fmt.Errorf("获取自选股列表失败: %v", err)
+		}
+		stocks = pick(all, codes)
+	case SourceRecord:
+		if sources.Record == nil {
+			return nil, fmt.Errorf("record universe is not supported")
+		}
+		codes, err := sources.Record(u.Name)
+		if err != nil {
+			return nil, fmt.Errorf("获取执行记录失败: %v", err)
+		}
+		stocks = pick(all, codes)
+	case SourceCodes:
+		stocks = pick(all, u.Codes)
+	case SourceIndex:
+		if strings.TrimSpace(u.Name) == "" {
+			return nil, fmt.Errorf("index code is required")
+		}
+		stocks, err = constituents(ctx, sources.Client, all, u.Name)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown universe source: %s", u.Source)
+	}
+
+	asOf := sources.AsOf
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	return u.Rules.Apply(ctx, sources.Client, stocks, asOf), nil
+}
+
+// Apply 按规则排除股票, 上市天数按asOf计算; 需要逐只查询的规则(上市天数、停牌)查询失败时保留该股票
+func (r Rules) Apply(ctx context.Context, client *api.Client, stocks []types.Index, asOf time.Time) []types.Index {
+	filtered := make([]types.Index, 0, len(stocks))
+	for _, stock := range stocks {
+		if r.ExcludeST && IsST(stock.Name) {
+			continue
+		}
+		if r.ExcludeBSE && IsBSE(stock.Code) {
+			continue
+		}
+		filtered = append(filtered, stock)
+	}
+
+	if client == nil {
+		return filtered
+	}
+
+	// 排除新股
+	if r.MinListDays > 0 {
+		cutoff := asOf.AddDate(0, 0, -r.MinListDays)
+		filtered = filterConcurrent(ctx, filtered, func(stock types.Index) bool {
+			info, err := client.Company.GetCompanyInfo(ctx, stock.Code)
+			if err != nil {
+				return true
+			}
+			listDate, ok := parseDate(info.ListDate)
+			return !ok || !listDate.After(cutoff)
+		})
+	}
+
+	// 排除停牌股票
+	if r.ExcludeSuspended {
+		filtered = filterConcurrent(ctx, filtered, func(stock types.Index) bool {
+			data, err := client.Market.GetRealtimeData(ctx, stock.Code)
+			if err != nil {
+				return true
+			}
+			return data.Volume > 0
+		})
+	}
+
+	return filtered
+}
+
+// IsAShare 是否为A股代码: 沪深主板、创业板、科创板和北交所
+func IsAShare(code string) bool {
+	return strings.HasPrefix(code, "00") || // 深证主板
+		strings.HasPrefix(code, "30") || // 创业板
+		strings.HasPrefix(code, "60") || // 上证主板
+		strings.HasPrefix(code, "68") || // 科创板
+		IsBSE(code)
+}
+
+// IsBSE 是否为北交所代码
+func IsBSE(code string) bool {
+	return strings.HasPrefix(code, "8") || strings.HasPrefix(code, "4") || strings.HasPrefix(code, "92")
+}
+
+// IsST 是否为ST或退市整理股票
+func IsST(name string) bool {
+	return strings.Contains(strings.ToUpper(name), "ST") || strings.Contains(name, "退")
+}
+
+// pick 按代码从股票列表中选出股票, 保持股票列表的顺序, 列表中不存在的代码忽略
+func pick(all []types.Index, codes []string) []types.Index {
+	wanted := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		wanted[strings.TrimSpace(code)] = true
+	}
+
+	stocks := make([]types.Index, 0, len(codes))
+	for _, stock := range all {
+		if wanted[stock.Code] {
+			stocks = append(stocks, stock)
+			delete(wanted, stock.Code)
+		}
+	}
+	if len(wanted) > 0 {
+		fmt.Printf("Warning: 股票池中有%d个代码不在股票列表中\n", len(wanted))
+	}
+	return stocks
+}
+
+// constituents 获取指数的当前成分股, 成分股列表只获取一次, 按股票列表的顺序保留其中的A股
+func constituents(ctx context.Context, client *api.Client, all []types.Index, index string) ([]types.Index, error) {
+	members, err := client.Market.GetIndexConstituents(ctx, strings.TrimSpace(index))
+	if err != nil {
+		return nil, fmt.Errorf("获取指数成分股失败: %v", err)
+	}
+
+	codes := make([]string, 0, len(members))
+	for _, member := range members {
+		if IsAShare(member.Code) {
+			codes = append(codes, member.Code)
+		}
+	}
+	return pick(all, codes), nil
+}
+
+// filterConcurrent 并发判断每只股票是否保留, 结果保持原顺序; ctx取消后剩余股票不再查询并被排除
+func filterConcurrent(ctx context.Context, stocks []types.Index, keep func(stock types.Index) bool) []types.Index {
+	kept := make([]bool, len(stocks))
+	sem := make(chan struct{}, lookupConcurrency)
+	var wg sync.WaitGroup
+	for i, stock := range stocks {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, stock types.Index) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			kept[i] = keep(stock)
+		}(i, stock)
+	}
+	wg.Wait()
+
+	filtered := make([]types.Index, 0, len(stocks))
+	for i, stock := range stocks {
+		if kept[i] {
+			filtered = append(filtered, stock)
+		}
+	}
+	return filtered
+}
+
+// parseDate 解析日期, 支持yyyy-MM-dd、yyyyMMdd及带时间的格式
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) > 10 {
+		s = s[:10]
+	}
+	for _, layout := range []string{"2006-01-02", "20060102", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,319 @@
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"stock-helper-svelte/backend/engine"
+
+	"github.com/tidwall/buntdb"
+)
+
+// keyPrefix 自选股列表在数据库中的键前缀, 键为keyPrefix+列表名称
+const keyPrefix = "watchlist:"
+
+// Entry 自选股列表中的一只股票
+type Entry struct {
+	Code    string    `json:"code"`
+	Name    string    `json:"name"`
+	Note    string    `json:"note"`    // 备注
+	AddedAt time.Time `json:"addedAt"` // 加入时间
+}
+
+// Watchlist 自选股列表
+type Watchlist struct {
+	Name       string    `json:"name"`
+	Entries    []Entry   `json:"entries"` // 按用户排列的顺序
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+// Store 基于buntdb的自选股存储, 每次修改在单个写事务中完成
+type Store struct {
+	db *buntdb.DB
+}
+
+// NewStore 创建自选股存储
+func NewStore(db *buntdb.DB) *Store {
+	return &Store{db: db}
+}
+
+// List 获取全部自选股列表, 按创建时间排列
+func (s *Store) List() ([]Watchlist, error) {
+	lists := make([]Watchlist, 0)
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var decodeErr error
+		err := tx.AscendKeys(keyPrefix+"*", func(key, value string) bool {
+			var list Watchlist
+			if decodeErr = json.Unmarshal([]byte(value), &list); decodeErr != nil {
+				decodeErr = fmt.Errorf("invalid watchlist %s: %v", strings.TrimPrefix(key, keyPrefix), decodeErr)
+				return false
+			}
+			lists = append(lists, list)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return decodeErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(lists, func(i, j int) bool {
+		return lists[i].CreateTime.Before(lists[j].CreateTime)
+	})
+	return lists, nil
+}
+
+// Get 获取自选股列表
+func (s *Store) Get(name string) (*Watchlist, error) {
+	var list *Watchlist
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		list, err = load(tx, name)
+		return err
+	})
+	return list, err
+}
+
+// Codes 获取自选股列表中的股票代码, 可作为universe.Sources.Watchlist
+func (s *Store) Codes(name string) ([]string, error) {
+	list, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, len(list.Entries))
+	for i, entry := range list.Entries {
+		codes[i] = entry.Code
+	}
+	return codes, nil
+}
+
+// Create 创建空的自选股列表
+func (s *Store) Create(name string) (*Watchlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("watchlist name is required")
+	}
+
+	now := time.Now()
+	list := &Watchlist{Name: name, Entries: []Entry{}, CreateTime: now, UpdateTime: now}
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		if _, err := tx.Get(keyPrefix + name); err == nil {
+			return fmt.Errorf("watchlist already exists: %s", name)
+		}
+		return save(tx, list)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Rename 重命名自选股列表
+func (s *Store) Rename(name, newName string) (*Watchlist, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return nil, fmt.Errorf("watchlist name is required")
+	}
+
+	var list *Watchlist
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		var err error
+		if list, err = load(tx, name); err != nil {
+			return err
+		}
+		if newName == name {
+			return nil
+		}
+		if _, err := tx.Get(keyPrefix + newName); err == nil {
+			return fmt.Errorf("watchlist already exists: %s", newName)
+		}
+		if _, err := tx.Delete(keyPrefix + name); err != nil {
+			return err
+		}
+		list.Name = newName
+		list.UpdateTime = time.Now()
+		return save(tx, list)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Delete 删除自选股列表
+func (s *Store) Delete(name string) error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(keyPrefix + name)
+		if err == buntdb.ErrNotFound {
+			return fmt.Errorf("watchlist not found: %s", name)
+		}
+		return err
+	})
+}
+
+// Add 将股票追加到列表末尾, 已在列表中的股票保持原位置, 仅在新备注非空时更新备注
+func (s *Store) Add(name string, entries ...Entry) (*Watchlist, error) {
+	return s.modify(name, func(list *Watchlist) error {
+		index := indexOf(list.Entries)
+		now := time.Now()
+		for _, entry := range entries {
+			entry.Code = strings.TrimSpace(entry.Code)
+			if entry.Code == "" {
+				return fmt.Errorf("stock code is required")
+			}
+			if i, ok := index[entry.Code]; ok {
+				if entry.Note != "" {
+					list.Entries[i].Note = entry.Note
+				}
+				continue
+			}
+			if entry.AddedAt.IsZero() {
+				entry.AddedAt = now
+			}
+			index[entry.Code] = len(list.Entries)
+			list.Entries = append(list.Entries, entry)
+		}
+		return nil
+	})
+}
+
+// Remove 从列表中移除股票, 不在列表中的代码忽略
+func (s *Store) Remove(name string, codes ...string) (*Watchlist, error) {
+	return s.modify(name, func(list *Watchlist) error {
+		removed := make(map[string]bool, len(codes))
+		for _, code := range codes {
+			removed[code] = true
+		}
+		entries := list.Entries[:0]
+		for _, entry := range list.Entries {
+			if !removed[entry.Code] {
+				entries = append(entries, entry)
+			}
+		}
+		list.Entries = entries
+		return nil
+	})
+}
+
+// Reorder 按给定代码顺序重新排列列表, 未给出的股票按原顺序排在后面
+func (s *Store) Reorder(name string, codes []string) (*Watchlist, error) {
+	return s.modify(name, func(list *Watchlist) error {
+		index := indexOf(list.Entries)
+		placed := make(map[string]bool, len(codes))
+		entries := make([]Entry, 0, len(list.Entries))
+		for _, code := range codes {
+			i, ok := index[code]
+			if !ok {
+				return fmt.Errorf("stock %s is not in watchlist %s", code, name)
+			}
+			if placed[code] {
+				continue
+			}
+			placed[code] = true
+			entries = append(entries, list.Entries[i])
+		}
+		for _, entry := range list.Entries {
+			if !placed[entry.Code] {
+				entries = append(entries, entry)
+			}
+		}
+		list.Entries = entries
+		return nil
+	})
+}
+
+// SetNote 设置列表中股票的备注
+func (s *Store) SetNote(name, code, note string) (*Watchlist, error) {
+	return s.modify(name, func(list *Watchlist) error {
+		i, ok := indexOf(list.Entries)[code]
+		if !ok {
+			return fmt.Errorf("stock %s is not in watchlist %s", code, name)
+		}
+		list.Entries[i].Note = note
+		return nil
+	})
+}
+
+// Import 将执行记录中的买入信号加入列表(列表不存在时创建), 信号原因作为备注
+func (s *Store) Import(name string, signals []engine.StockSignal) (*Watchlist, error) {
+	entries := make([]Entry, 0, len(signals))
+	for _, signal := range signals {
+		if signal.IsExit() {
+			continue
+		}
+		entries = append(entries, Entry{Code: signal.Code, Name: signal.Name, Note: signal.Reason})
+	}
+
+	if _, err := s.Get(name); err != nil {
+		if _, err := s.Create(name); err != nil {
+			return nil, err
+		}
+	}
+	return s.Add(name, entries...)
+}
+
+// modify 在写事务中加载、修改并保存列表
+func (s *Store) modify(name string, update func(list *Watchlist) error) (*Watchlist, error) {
+	var list *Watchlist
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		var err error
+		if list, err = load(tx, name); err != nil {
+			return err
+		}
+		if err := update(list); err != nil {
+			return err
+		}
+		list.UpdateTime = time.Now()
+		return save(tx, list)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// load 在事务中加载列表
+func load(tx *buntdb.Tx, name string) (*Watchlist, error) {
+	value, err := tx.Get(keyPrefix + name)
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("watchlist not found: %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var list Watchlist
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, fmt.Errorf("invalid watchlist %s: %v", name, err)
+	}
+	if list.Entries == nil {
+		list.Entries = []Entry{}
+	}
+	return &list, nil
+}
+
+// save 在事务中保存列表
+func save(tx *buntdb.Tx, list *Watchlist) error {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	_, _, err = tx.Set(keyPrefix+list.Name, string(data), nil)
+	return err
+}
+
+// indexOf 按代码索引列表中的位置
+func indexOf(entries []Entry) map[string]int {
+	index := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		index[entry.Code] = i
+	}
+	return index
+}
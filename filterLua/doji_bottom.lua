@@ -1,9 +1,24 @@
 -- @id: 2
 -- @name: 十字星触底反转策略
 -- @description: 监测最近3天内的十字星形态，结合MACD和RSI确认触底信号，验证后续上涨趋势
+-- @param DOJI_DAYS int 3 1 10 检查十字星的天数范围
+-- @param DOJI_THRESHOLD float 0.008 0.001 0.05 开盘收盘价差阈值
+-- @param RSI_PERIOD int 14 2 60 RSI周期
+-- @param RSI_OVERSOLD float 40 10 60 RSI超卖阈值
+-- @param MACD_FAST int 12 2 50 MACD快线
+-- @param MACD_SLOW int 26 5 100 MACD慢线
+-- @param MACD_SIGNAL int 9 2 50 MACD信号线
+-- @param TREND_MA int 20 5 120 趋势均线
+-- @param TREND_MA_LONG int 60 20 250 长期趋势均线
+-- @param MIN_PERIODS int 60 20 250 最小所需数据周期
+-- @param VOLUME_RATIO float 1.0 0.1 5 成交量放大倍数
+-- @param PRICE_INCREASE float 0.015 0 0.1 十字星后上涨确认幅度
+-- @param BOTTOM_COMPARE_DAYS int 15 5 120 相对低点比较天数
+-- @param TREND_UP_THRESHOLD float 0.01 0 0.1 上升趋势判断阈值
+-- @param MIN_AMOUNT float 2000000 0 - 最小成交额要求(元)
 
 -- 策略参数
-local STRATEGY_PARAMS = {
+STRATEGY_PARAMS = {
     DOJI_DAYS = 3,        -- 检查十字星的天数范围
     DOJI_THRESHOLD = 0.008, -- 开盘收盘价差阈值（放宽到0.8%）
     RSI_PERIOD = 14,      -- RSI周期
@@ -208,6 +223,17 @@ function process_stock(stock)
         return
     end
 
+    -- 识别K线形态(十字星、涨停等)
+    local patterns, err = api.indicator.patterns(recent_kdata, {
+        dojiThreshold = STRATEGY_PARAMS.DOJI_THRESHOLD,
+        limitUpRate = api.indicator.limitUpRate(stock.code)
+    })
+    if err then
+        log(string.format("[ERROR] [%s] K线形态识别失败: %s", stock.code, tostring(err)))
+        return
+    end
+    indicators.patterns = patterns
+
     -- 分析交易信号
     log(string.format("[DEBUG] [%s] 开始分析交易信号", stock.code))
     local signal = analyze_trading_signal(stock.code, recent_kdata, indicators)
@@ -359,22 +385,6 @@ function calculate_indicators(prices)
     return indicators
 end
 
--- 检查是否形成十字星
-function is_doji(open, close, high, low)
-    -- 计算实体与影线比例
-    local body = math.abs(close - open)
-    local body_ratio = body / (high - low)
-    local price = (open + close) / 2
-    
-    local is_doji = (body / price) <= STRATEGY_PARAMS.DOJI_THRESHOLD and body_ratio <= 0.3
-    if is_doji then
-        print_log(LOG_LEVEL.DEBUG, nil, "发现十字星形态 - 实体比例: %.2f%%, 影线比例: %.2f%%", 
-            (body / price) * 100, body_ratio * 100)
-    end
-    
-    return is_doji
-end
-
 -- 检查数值是否有效
 function is_valid_number(n)
     return type(n) == "number" and n == n and n ~= math.huge and n ~= -math.huge
@@ -476,40 +486,15 @@ function is_relative_bottom(kdata, idx)
     return is_bottom
 end
 
--- 检查是否跳空高开或涨停
-function is_gap_up_or_limit_up(kdata, idx, stock_code)
-    -- 只检查涨停，不再检查跳空高开
-    -- 根据股票代码判断涨停幅度
-    local limit_up_rate = 0.098  -- 默认A股涨停9.8%
-    
-    -- 科创板(688开头)和创业板(300开头)涨停20%
-    if string.match(stock_code, "^688") or string.match(stock_code, "^300") then
-        limit_up_rate = 0.198
-    end
-    
-    -- 检查是否涨停
-    local prev_close = kdata[idx-1].close
-    local current_close = kdata[idx].close
-    
-    -- 防止除以0
-    if prev_close <= 0 then
-        return false
-    end
-    
-    local price_change = (current_close - prev_close) / prev_close
-    
-    -- 检查计算结果是否有效
-    if not is_valid_number(price_change) then
-        return false
-    end
-    
-    local is_limit_up = price_change >= limit_up_rate
-    
+-- 检查是否涨停
+function is_limit_up(indicators, idx)
+    local pattern = indicators.patterns[idx]
+    local is_limit_up = pattern ~= nil and pattern.limitUp > 0
+
     if is_limit_up then
-        print_log(LOG_LEVEL.DEBUG, nil, "涨停板 - 涨幅: %.2f%%, 涨停幅度: %.2f%%", 
-            price_change * 100, limit_up_rate * 100)
+        print_log(LOG_LEVEL.DEBUG, nil, "涨停板 - %s", pattern.time)
     end
-    
+
     return is_limit_up
 end
 
@@ -536,9 +521,9 @@ function check_bottom_signal(kdata, indicators, doji_idx, stock_code)
         reasons = {}
     }
     
-    -- 检查是否跳空高开或涨停
-    if is_gap_up_or_limit_up(kdata, doji_idx, stock_code) then
-        log(string.format("[DEBUG] [%s] 检测到跳空高开或涨停，不符合触底特征", stock_code))
+    -- 检查是否涨停
+    if is_limit_up(indicators, doji_idx) then
+        log(string.format("[DEBUG] [%s] 检测到涨停，不符合触底特征", stock_code))
         return result
     end
     
@@ -678,8 +663,9 @@ function analyze_trading_signal(stock_code, kdata, indicators)
             goto continue
         end
 
-        if is_doji(kdata[i].open, kdata[i].close, kdata[i].high, kdata[i].low) then
-            log(string.format("[DEBUG] [%s] 在%s发现十字星形态", stock_code, kdata[i].time))
+        local pattern = indicators.patterns[i]
+        if pattern and pattern.doji > 0 then
+            log(string.format("[DEBUG] [%s] 在%s发现十字星形态, 强度: %.2f", stock_code, kdata[i].time, pattern.doji))
             
             -- 检查触底信号
             local bottom_info = check_bottom_signal(kdata, indicators, i, stock_code)
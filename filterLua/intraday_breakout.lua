@@ -0,0 +1,94 @@
+-- @id: 7
+-- @name: 盘中突破提醒
+-- @description: 收盘后选出收盘价接近N日最高价的股票；盘中监控这些股票，实时价格放量突破N日最高价时提醒
+-- @param HIGH_DAYS int 20 5 120 最高价统计天数
+-- @param NEAR_PERCENT float 3 0 20 收盘价距N日最高价的最大距离(%)
+-- @param MIN_AMOUNT float 50000000 0 - 最近一日最小成交额(元)
+-- @param MIN_VOLUME_RATIO float 1.5 0 20 盘中突破时的最小量比
+
+-- 策略参数
+STRATEGY_PARAMS = {
+    HIGH_DAYS = 20,           -- 最高价统计天数
+    NEAR_PERCENT = 3,         -- 收盘价距N日最高价的最大距离(%)
+    MIN_AMOUNT = 50000000,    -- 最近一日最小成交额（5000万）
+    MIN_VOLUME_RATIO = 1.5    -- 盘中突破时的最小量比
+}
+
+-- kdata[last - HIGH_DAYS + 1 .. last]的最高价, 数据不足时返回nil
+local function highest_high(kdata, last)
+    if last < STRATEGY_PARAMS.HIGH_DAYS then
+        return nil
+    end
+    local high = 0
+    for i = last - STRATEGY_PARAMS.HIGH_DAYS + 1, last do
+        high = math.max(high, kdata[i].high)
+    end
+    return high
+end
+
+-- 收盘后选股: 收盘价接近N日最高价, 作为盘中监控的股票池
+function process_stock(stock)
+    local kdata = api.getKLineData(stock.code, "dh")
+    if not kdata or #kdata == 0 then
+        return
+    end
+
+    local last = kdata[#kdata]
+    local high = highest_high(kdata, #kdata)
+    if not high or high <= 0 or last.amount < STRATEGY_PARAMS.MIN_AMOUNT then
+        return
+    end
+
+    local distance = (high - last.close) / high * 100
+    if distance > STRATEGY_PARAMS.NEAR_PERCENT then
+        return
+    end
+
+    api.emit{
+        price = last.close,
+        turnover = last.turnover,
+        change = last.change,
+        score = -distance,
+        tags = {"breakout", "watch"},
+        reason = string.format("收盘价距%d日最高价%.2f仅%.2f%%", STRATEGY_PARAMS.HIGH_DAYS, high, distance),
+        fields = {
+            high = high
+        }
+    }
+end
+
+-- 盘中监控: 连续竞价时段实时价格放量突破此前N日最高价时提醒(同一只股票持续突破只提醒一次)
+function on_tick(stock, quote)
+    if quote.session ~= "trading" or quote.price <= 0 then
+        return
+    end
+
+    local kdata = api.getKLineData(stock.code, "dh")
+    if not kdata or #kdata == 0 then
+        return
+    end
+
+    -- 日线可能已包含当天的K线, 突破价只取此前的交易日
+    local last = #kdata
+    if string.sub(kdata[last].time, 1, 10) == string.sub(quote.time, 1, 10) then
+        last = last - 1
+    end
+    local high = highest_high(kdata, last)
+    if not high or quote.price <= high or quote.volumeRatio < STRATEGY_PARAMS.MIN_VOLUME_RATIO then
+        return
+    end
+
+    api.emit{
+        price = quote.price,
+        turnover = quote.turnover,
+        change = quote.changePercent,
+        score = (quote.price - high) / high * 100,
+        tags = {"breakout", "intraday"},
+        reason = string.format("%s 突破%d日最高价%.2f，现价%.2f，量比%.2f",
+            string.sub(quote.time, 12, 16), STRATEGY_PARAMS.HIGH_DAYS, high, quote.price, quote.volumeRatio),
+        fields = {
+            high = high,
+            volumeRatio = quote.volumeRatio
+        }
+    }
+end
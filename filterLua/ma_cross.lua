@@ -1,9 +1,22 @@
 -- @id: 1
 -- @name: MA交叉策略
 -- @description: 监测均线交叉信号，要求最近3-5天内出现金叉且之后无死叉，且价格在98日线之上，90天内有2-5个涨停，金叉时成交量放大，换手率大于5%，趋势向上
+-- @param SHORT_MA int 6 2 30 短期均线天数
+-- @param LONG_MA int 18 5 60 长期均线天数
+-- @param TREND_MA int 98 20 250 趋势均线天数
+-- @param MIN_DAYS int 3 1 30 金叉最少天数
+-- @param MAX_DAYS int 15 1 60 金叉最多天数
+-- @param MIN_PERIODS int 98 20 250 最小所需数据周期
+-- @param CHECK_DAYS int 90 20 250 涨停检查天数
+-- @param MIN_LIMIT_UP int 2 0 20 最小涨停数
+-- @param MAX_LIMIT_UP int 5 0 30 最大涨停数
+-- @param VOLUME_RATIO float 1.5 0.5 5 成交量放大倍数
+-- @param MIN_TURNOVER float 5 0 50 最小换手率(%)
+-- @param TREND_PERIOD int 20 5 120 趋势分析周期
+-- @param MIN_TREND float 0.2 -1 1 最小趋势强度要求
 
 -- 策略参数
-local STRATEGY_PARAMS = {
+STRATEGY_PARAMS = {
     SHORT_MA = 6,     -- 短期均线天数
     LONG_MA = 18,     -- 长期均线天数
     TREND_MA = 98,    -- 趋势均线天数
@@ -0,0 +1,4 @@
+-- @id: 4
+-- @name: MA交叉排除十字星触底
+-- @description: 组合策略示例：MA交叉策略选出、且十字星触底反转策略未选出的股票
+-- @compose: 1 AND NOT 2
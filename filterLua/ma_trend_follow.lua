@@ -1,9 +1,21 @@
 -- @id: 1245125
 -- @name: MA趋势跟踪策略
 -- @description: 监测均线金叉后N天内的持续上涨趋势，要求趋势保持向上且波动幅度在限定范围内
+-- @param SHORT_MA int 5 2 30 短期均线天数
+-- @param LONG_MA int 10 5 60 长期均线天数
+-- @param TREND_MA int 20 5 120 趋势均线天数
+-- @param CHECK_DAYS int 5 1 30 金叉后检查天数
+-- @param MIN_PERIODS int 30 10 250 最小所需数据周期
+-- @param MIN_TREND_SLOPE float 0.3 0 5 最小趋势斜率
+-- @param MAX_TREND_SLOPE float 2.0 0 10 最大趋势斜率
+-- @param MAX_DEVIATION float 0.02 0 0.2 最大偏离度(单日)
+-- @param MIN_UP_DAYS_RATIO float 0.8 0 1 上涨天数比例要求
+-- @param MIN_VOLUME_RATIO float 1.5 0.5 5 金叉时最小成交量放大倍数
+-- @param MIN_TURNOVER float 3.0 0 50 最小换手率(%)
+-- @param MAX_TURNOVER float 15.0 0 100 最大换手率(%)
 
 -- 策略参数
-local STRATEGY_PARAMS = {
+STRATEGY_PARAMS = {
     SHORT_MA = 5,      -- 短期均线天数
     LONG_MA = 10,      -- 长期均线天数
     TREND_MA = 20,     -- 趋势均线天数
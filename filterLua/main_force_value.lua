@@ -0,0 +1,146 @@
+-- @id: 6
+-- @name: 主力流入价值股
+-- @description: 站上均线且近N日主力资金持续净流入，市盈率、市净率合理，上年ROE达标，且近期没有限售解禁的股票
+-- @param MA_PERIOD int 20 5 120 均线天数
+-- @param INFLOW_DAYS int 5 1 30 主力净流入统计天数
+-- @param MIN_INFLOW_DAYS int 3 1 30 主力净流入天数下限
+-- @param MAX_PE float 30 0 - 最大市盈率
+-- @param MAX_PB float 5 0 - 最大市净率
+-- @param MIN_ROE float 10 - - 上年最小净资产收益率(%)
+-- @param UNLOCK_DAYS int 30 0 365 解禁回避天数
+
+-- 策略参数
+STRATEGY_PARAMS = {
+    MA_PERIOD = 20,       -- 均线天数
+    INFLOW_DAYS = 5,      -- 主力净流入统计天数
+    MIN_INFLOW_DAYS = 3,  -- 统计期内主力净流入的最少天数
+    MAX_PE = 30,          -- 最大市盈率
+    MAX_PB = 5,           -- 最大市净率
+    MIN_ROE = 10,         -- 上年最小净资产收益率(%)
+    UNLOCK_DAYS = 30      -- 未来多少天内有解禁则回避
+}
+
+-- 日期(yyyy-MM-dd)转换为时间戳
+local function to_time(date)
+    local y, m, d = string.match(date or "", "(%d+)-(%d+)-(%d+)")
+    if not y then
+        return nil
+    end
+    return os.time{year = tonumber(y), month = tonumber(m), day = tonumber(d), hour = 0}
+end
+
+-- 近N日主力净流入的天数和合计金额
+local function main_force_inflow(code)
+    local flows, err = api.market.getCapitalFlow(code)
+    if not flows then
+        log(string.format("[WARN] %s 获取资金流向失败: %s", code, tostring(err)))
+        return 0, 0
+    end
+
+    -- 按日期升序排列后取最近N日
+    table.sort(flows, function(a, b) return a.time < b.time end)
+    local days, total = 0, 0
+    for i = math.max(1, #flows - STRATEGY_PARAMS.INFLOW_DAYS + 1), #flows do
+        local inflow = flows[i].mainForceNetInflow
+        total = total + inflow
+        if inflow > 0 then
+            days = days + 1
+        end
+    end
+    return days, total
+end
+
+-- 指定日期之后若干天内是否有限售解禁
+local function has_upcoming_unlock(code, date)
+    local unlocks = api.company.getShareUnlocks(code)
+    local from = to_time(date)
+    if not unlocks or not from then
+        return false
+    end
+
+    local to = from + STRATEGY_PARAMS.UNLOCK_DAYS * 86400
+    for _, unlock in ipairs(unlocks) do
+        local t = to_time(unlock.unlockDate)
+        if t and t >= from and t <= to then
+            return true
+        end
+    end
+    return false
+end
+
+-- 最近一期已发布年报的净资产收益率: 上年年报在次年4月底前才发布, 未发布(回测时不可用)则取前年年报
+local function last_year_roe(code, date)
+    local year = tonumber(string.sub(date, 1, 4))
+    for report_year = year - 1, year - 2, -1 do
+        local data = api.financial.getStockFinancialData(code, report_year, 4)
+        if data and data.profitability then
+            return data.profitability.jzcsy
+        end
+    end
+    return nil
+end
+
+-- 处理单个股票
+function process_stock(stock)
+    local kdata = api.getKLineData(stock.code, "dh")
+    if not kdata or #kdata < STRATEGY_PARAMS.MA_PERIOD then
+        return
+    end
+
+    -- 技术面: 收盘价站上均线
+    local prices = {}
+    for _, k in ipairs(kdata) do
+        table.insert(prices, k.close)
+    end
+    local ma = api.indicator.calculateMA(prices, "sma", STRATEGY_PARAMS.MA_PERIOD)
+    local last = kdata[#kdata]
+    if not ma or last.close <= ma[#ma] then
+        return
+    end
+
+    -- 资金面: 主力持续净流入
+    local inflow_days, inflow_total = main_force_inflow(stock.code)
+    if inflow_days < STRATEGY_PARAMS.MIN_INFLOW_DAYS or inflow_total <= 0 then
+        return
+    end
+
+    -- 估值: 实时市盈率、市净率(回测时无实时数据, 跳过估值检查)
+    local realtime = api.market.getRealtimeData(stock.code)
+    if realtime then
+        if realtime.pe <= 0 or realtime.pe > STRATEGY_PARAMS.MAX_PE or realtime.pb > STRATEGY_PARAMS.MAX_PB then
+            return
+        end
+    end
+
+    -- 基本面: 上年ROE达标
+    local date = string.sub(last.time, 1, 10)
+    local roe = last_year_roe(stock.code, date)
+    if not roe or roe < STRATEGY_PARAMS.MIN_ROE then
+        return
+    end
+
+    -- 解禁: 近期没有限售股解禁
+    if has_upcoming_unlock(stock.code, date) then
+        return
+    end
+
+    local reason = string.format("站上%d日均线，近%d日主力净流入%d天共%.0f万元，上年ROE %.2f%%",
+        STRATEGY_PARAMS.MA_PERIOD, STRATEGY_PARAMS.INFLOW_DAYS, inflow_days, inflow_total / 10000, roe)
+    if realtime then
+        reason = reason .. string.format("，PE %.2f，PB %.2f", realtime.pe, realtime.pb)
+    end
+
+    api.emit{
+        price = last.close,
+        turnover = last.turnover,
+        change = last.change,
+        score = inflow_total / 10000,
+        tags = {"mainForce", "value"},
+        reason = reason,
+        fields = {
+            inflowDays = inflow_days,
+            inflowTotal = inflow_total,
+            roe = roe
+        }
+    }
+end
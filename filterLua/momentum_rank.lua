@@ -0,0 +1,54 @@
+-- @id: 3
+-- @name: 动量排名策略
+-- @description: 按N日涨幅对全部股票打分排序，选出动量最强的前N只股票（两阶段执行：score_stock评分，select统一选股）
+-- @param MOMENTUM_DAYS int 60 5 250 动量计算天数
+-- @param TOP_N int 20 1 200 选取的股票数量
+-- @param MIN_AMOUNT float 50000000 0 - 最近一日最小成交额(元)
+
+-- 策略参数
+STRATEGY_PARAMS = {
+    MOMENTUM_DAYS = 60,     -- 动量计算天数
+    TOP_N = 20,             -- 选取的股票数量
+    MIN_AMOUNT = 50000000   -- 最近一日最小成交额（5000万）
+}
+
+-- 第一阶段：计算单只股票的动量评分，返回nil表示不参与排名
+function score_stock(stock)
+    local kdata = api.getKLineData(stock.code, "dh")
+    if not kdata or #kdata <= STRATEGY_PARAMS.MOMENTUM_DAYS then
+        return nil
+    end
+
+    local last = kdata[#kdata]
+    local base = kdata[#kdata - STRATEGY_PARAMS.MOMENTUM_DAYS]
+    if base.close <= 0 or last.amount < STRATEGY_PARAMS.MIN_AMOUNT then
+        return nil
+    end
+
+    local momentum = (last.close - base.close) / base.close * 100
+    return momentum, {
+        price = last.close,
+        turnover = last.turnover,
+        change = last.change
+    }
+end
+
+-- 第二阶段：在按评分降序排列的全部股票中选出前N只
+function select(ranked)
+    log(string.format("[INFO] 参与排名股票数: %d", #ranked))
+
+    for i = 1, math.min(STRATEGY_PARAMS.TOP_N, #ranked) do
+        local item = ranked[i]
+        api.emit{
+            code = item.code,
+            name = item.name,
+            price = item.data.price,
+            turnover = item.data.turnover,
+            change = item.data.change,
+            score = item.score,
+            tags = {"momentum"},
+            reason = string.format("%d日涨幅%.2f%%，排名第%d/%d",
+                STRATEGY_PARAMS.MOMENTUM_DAYS, item.score, item.rank, #ranked)
+        }
+    end
+end